@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"itab-backend/internal/store"
+)
+
+// runFsck 执行 fsck 子命令：检查所有备份的数据一致性，--fix 时就地修复
+func runFsck(args []string) {
+	fs := flag.NewFlagSet("fsck", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "就地修复发现的问题（修复前自动保存版本快照）")
+	fs.Parse(args)
+
+	reports, err := store.Fsck(*fix)
+	if err != nil {
+		log.Fatalf("检查备份失败: %v", err)
+	}
+
+	for _, r := range reports {
+		fmt.Printf("备份 #%d「%s」(用户 %d)\n", r.BackupID, r.Name, r.UserID)
+		if r.Error != "" {
+			fmt.Printf("  错误: %s\n", r.Error)
+		}
+		for _, issue := range r.Issues {
+			fmt.Printf("  问题 [%s] %s: %s\n", issue.Code, issue.Path, issue.Message)
+		}
+		for _, change := range r.Changes {
+			if r.Fixed {
+				fmt.Printf("  已修复: %s\n", change)
+			} else {
+				fmt.Printf("  可修复: %s\n", change)
+			}
+		}
+		if r.Fixed {
+			fmt.Printf("  已保存为版本 %d\n", r.Version)
+		}
+	}
+
+	if len(reports) == 0 {
+		log.Println("所有备份数据均正常")
+	} else if !*fix {
+		log.Printf("共 %d 个备份存在问题，使用 fsck --fix 进行修复", len(reports))
+	} else {
+		log.Printf("共 %d 个备份存在问题，已处理完成", len(reports))
+	}
+}
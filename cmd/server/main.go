@@ -10,7 +10,10 @@ import (
 	"itab-backend/internal/auth"
 	"itab-backend/internal/database"
 	"itab-backend/internal/logger"
+	"itab-backend/internal/retention"
 	"itab-backend/internal/router"
+	"itab-backend/internal/store"
+	"itab-backend/internal/trash"
 )
 
 // getEnvOrDefault 从环境变量获取值，如果不存在则返回默认值
@@ -39,6 +42,17 @@ func main() {
 	dbPath := flag.String("db", "", "数据库路径")
 	logDir := flag.String("log-dir", "", "日志目录")
 	logKeepDays := flag.Int("log-keep-days", -1, "日志保留天数，0表示永久保留")
+	compression := flag.String("compression", "", "备份数据压缩方式：gzip 或 none")
+	storageBackend := flag.String("storage", "", "备份数据存储后端：db、fs 或 s3")
+	storageDir := flag.String("storage-dir", "", "fs 存储后端的目录")
+	s3Endpoint := flag.String("s3-endpoint", "", "S3兼容存储服务地址，如 http://127.0.0.1:9000")
+	s3Bucket := flag.String("s3-bucket", "", "S3存储桶")
+	s3Region := flag.String("s3-region", "", "S3区域")
+	s3AccessKey := flag.String("s3-access-key", "", "S3访问密钥 Access Key")
+	s3SecretKey := flag.String("s3-secret-key", "", "S3访问密钥 Secret Key")
+	s3Prefix := flag.String("s3-prefix", "", "S3对象键前缀")
+	masterKeyFile := flag.String("master-key-file", "", "备份数据加密主密钥文件，未指定时读取 ITAB_MASTER_KEY_FILE 或 ITAB_MASTER_KEY 环境变量")
+	noEncryption := flag.Bool("no-encryption", false, "不加密备份数据（未提供主密钥时须显式指定）")
 	flag.Parse()
 
 	// 环境变量作为默认值，命令行参数优先
@@ -72,6 +86,52 @@ func main() {
 		finalLogKeepDays = getEnvIntOrDefault("ITAB_LOG_KEEP_DAYS", 3)
 	}
 
+	finalCompression := *compression
+	if finalCompression == "" {
+		finalCompression = getEnvOrDefault("ITAB_COMPRESSION", "gzip")
+	}
+
+	storageCfg := storageConfig{
+		Backend:     *storageBackend,
+		Dir:         *storageDir,
+		S3Endpoint:  *s3Endpoint,
+		S3Bucket:    *s3Bucket,
+		S3Region:    *s3Region,
+		S3AccessKey: *s3AccessKey,
+		S3SecretKey: *s3SecretKey,
+		S3Prefix:    *s3Prefix,
+	}
+	if storageCfg.Backend == "" {
+		storageCfg.Backend = getEnvOrDefault("ITAB_STORAGE", "db")
+	}
+	if storageCfg.Dir == "" {
+		storageCfg.Dir = getEnvOrDefault("ITAB_STORAGE_DIR", "./data/blobs")
+	}
+	if storageCfg.S3Endpoint == "" {
+		storageCfg.S3Endpoint = getEnvOrDefault("ITAB_S3_ENDPOINT", "")
+	}
+	if storageCfg.S3Bucket == "" {
+		storageCfg.S3Bucket = getEnvOrDefault("ITAB_S3_BUCKET", "")
+	}
+	if storageCfg.S3Region == "" {
+		storageCfg.S3Region = getEnvOrDefault("ITAB_S3_REGION", "us-east-1")
+	}
+	if storageCfg.S3AccessKey == "" {
+		storageCfg.S3AccessKey = getEnvOrDefault("ITAB_S3_ACCESS_KEY", "")
+	}
+	if storageCfg.S3SecretKey == "" {
+		storageCfg.S3SecretKey = getEnvOrDefault("ITAB_S3_SECRET_KEY", "")
+	}
+	if storageCfg.S3Prefix == "" {
+		storageCfg.S3Prefix = getEnvOrDefault("ITAB_S3_PREFIX", "")
+	}
+
+	finalMasterKeyFile := *masterKeyFile
+	if finalMasterKeyFile == "" {
+		finalMasterKeyFile = getEnvOrDefault("ITAB_MASTER_KEY_FILE", "")
+	}
+	finalNoEncryption := *noEncryption || getEnvOrDefault("ITAB_NO_ENCRYPTION", "") == "true"
+
 	// 初始化日志系统
 	if err := logger.InitLogger(finalLogDir, finalLogKeepDays); err != nil {
 		log.Fatalf("日志系统初始化失败: %v", err)
@@ -96,6 +156,51 @@ func main() {
 		log.Fatalf("数据库初始化失败: %v", err)
 	}
 
+	// 设置备份数据压缩方式
+	switch finalCompression {
+	case "gzip":
+		store.Compression = store.EncodingGzip
+	case "none":
+		store.Compression = store.EncodingPlain
+	default:
+		log.Fatalf("不支持的压缩方式: %s", finalCompression)
+	}
+
+	// 配置备份数据存储后端
+	if err := setupStorage(storageCfg); err != nil {
+		log.Fatalf("存储后端初始化失败: %v", err)
+	}
+
+	// 设置备份数据加密主密钥，未提供主密钥且未显式关闭加密、或已有加密数据但缺少或无法解密时拒绝启动
+	if err := setupEncryption(finalMasterKeyFile, finalNoEncryption, flag.Arg(0) != "rotate-key"); err != nil {
+		log.Fatalf("备份数据加密初始化失败: %v", err)
+	}
+
+	// 子命令：执行完成后直接退出，不启动服务
+	switch flag.Arg(0) {
+	case "":
+	case "fsck":
+		runFsck(flag.Args()[1:])
+		return
+	case "migrate-storage":
+		runMigrateStorage(flag.Args()[1:])
+		return
+	case "rotate-key":
+		runRotateKey(flag.Args()[1:])
+		return
+	default:
+		log.Fatalf("未知的子命令: %s", flag.Arg(0))
+	}
+
+	// 后台迁移早期存储的备份数据并定期回收无引用的数据块
+	store.StartMaintenance()
+
+	// 每天按保留策略清理备份历史版本
+	retention.StartRetention()
+
+	// 每天彻底删除回收站中过期的备份
+	trash.StartAutoPurge()
+
 	// 初始化管理员用户
 	if finalUser != "" && finalPwd != "" {
 		// 命令行指定了用户名密码，创建或更新用户
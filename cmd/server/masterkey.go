@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"itab-backend/internal/store"
+)
+
+// masterKeyHint 未提供或提供了错误的主密钥时的提示
+const masterKeyHint = "请通过 --master-key-file、ITAB_MASTER_KEY_FILE 或 ITAB_MASTER_KEY 指定加密这些数据时使用的主密钥"
+
+// noKeyHint 未提供主密钥时的提示
+const noKeyHint = "未提供备份数据加密主密钥，请通过 --master-key-file、ITAB_MASTER_KEY_FILE 或 ITAB_MASTER_KEY 指定（可用 openssl rand -hex 32 生成），" +
+	"确需不加密存储时使用 --no-encryption 或 ITAB_NO_ENCRYPTION=true"
+
+// loadMasterKey 读取主密钥：优先使用密钥文件，其次是 ITAB_MASTER_KEY 环境变量，均未配置时返回 nil
+func loadMasterKey(file string) ([]byte, error) {
+	if file != "" {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("读取主密钥文件失败: %v", err)
+		}
+		key, err := store.ParseMasterKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("主密钥文件 %s 无效: %v", file, err)
+		}
+		return key, nil
+	}
+	if env := os.Getenv("ITAB_MASTER_KEY"); env != "" {
+		key, err := store.ParseMasterKey([]byte(env))
+		if err != nil {
+			return nil, fmt.Errorf("ITAB_MASTER_KEY 无效: %v", err)
+		}
+		return key, nil
+	}
+	return nil, nil
+}
+
+// setupEncryption 设置主密钥并检查已加密的数据能否解密，rotate-key 子命令自行检查
+// 未提供主密钥时须显式指定 noEncryption，避免因漏配密钥而静默地以明文存储
+func setupEncryption(file string, noEncryption, checkKey bool) error {
+	key, err := loadMasterKey(file)
+	if err != nil {
+		return err
+	}
+	switch {
+	case key != nil && noEncryption:
+		return errors.New("已提供主密钥，不能同时指定 --no-encryption")
+	case key == nil && !noEncryption:
+		return errors.New(noKeyHint)
+	}
+	if err := store.UseMasterKey(key); err != nil {
+		return err
+	}
+	if !checkKey {
+		return nil
+	}
+	if err := store.CheckMasterKey(); err != nil {
+		if errors.Is(err, store.ErrMasterKeyMissing) || errors.Is(err, store.ErrMasterKeyMismatch) {
+			return fmt.Errorf("%v，%s", err, masterKeyHint)
+		}
+		return err
+	}
+	if key != nil {
+		log.Printf("备份数据加密已启用，主密钥标识 %s", store.MasterKeyID())
+	} else {
+		log.Printf("警告：备份数据加密已关闭（--no-encryption），新写入的数据将以明文存储")
+	}
+	return nil
+}
+
+// runRotateKey 执行 rotate-key 子命令：用新主密钥重新包装各备份的数据密钥，执行前应停止服务
+func runRotateKey(args []string) {
+	fs := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+	newKeyFile := fs.String("new-key-file", "", "新主密钥文件，未指定时读取 ITAB_NEW_MASTER_KEY 环境变量")
+	fs.Parse(args)
+
+	var raw []byte
+	if *newKeyFile != "" {
+		data, err := os.ReadFile(*newKeyFile)
+		if err != nil {
+			log.Fatalf("读取新主密钥文件失败: %v", err)
+		}
+		raw = data
+	} else if env := os.Getenv("ITAB_NEW_MASTER_KEY"); env != "" {
+		raw = []byte(env)
+	} else {
+		log.Fatalf("请通过 --new-key-file 或 ITAB_NEW_MASTER_KEY 指定新主密钥")
+	}
+	newKey, err := store.ParseMasterKey(raw)
+	if err != nil {
+		log.Fatalf("新主密钥无效: %v", err)
+	}
+	if store.MasterKeyID() == "" {
+		log.Fatalf("未提供当前主密钥，%s", masterKeyHint)
+	}
+
+	oldID := store.MasterKeyID()
+	log.Printf("开始轮换主密钥 %s -> %s", oldID, store.KeyID(newKey))
+	rotated, err := store.RotateMasterKey(newKey)
+	if err != nil {
+		log.Fatalf("轮换失败（已处理 %d 个备份，可使用原主密钥再次执行以继续）: %v", rotated, err)
+	}
+	log.Printf("轮换完成，共重新包装 %d 个备份的数据密钥，请将主密钥配置替换为新主密钥后再启动服务", rotated)
+}
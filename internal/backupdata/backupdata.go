@@ -0,0 +1,21 @@
+// Package backupdata 提供对备份数据（models.BackupData）的解析与结构化处理
+package backupdata
+
+import (
+	"encoding/json"
+	"strings"
+
+	"itab-backend/internal/models"
+)
+
+// Parse 将存储的JSON字符串解析为备份数据结构，空字符串视为空备份
+func Parse(data string) (*models.BackupData, error) {
+	var bd models.BackupData
+	if strings.TrimSpace(data) == "" {
+		return &bd, nil
+	}
+	if err := json.Unmarshal([]byte(data), &bd); err != nil {
+		return nil, err
+	}
+	return &bd, nil
+}
@@ -0,0 +1,280 @@
+package backupdata
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"itab-backend/internal/models"
+)
+
+// ItemRef 条目引用
+type ItemRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// RenameChange 条目重命名
+type RenameChange struct {
+	ID   int    `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// MoveChange 条目位置变化（所属文件夹、所属分区或排序）
+type MoveChange struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name"`
+	FromFolderID    *int   `json:"fromFolderId,omitempty"`
+	ToFolderID      *int   `json:"toFolderId,omitempty"`
+	FromPartitionID *int   `json:"fromPartitionId,omitempty"`
+	ToPartitionID   *int   `json:"toPartitionId,omitempty"`
+	FromOrder       int    `json:"fromOrder"`
+	ToOrder         int    `json:"toOrder"`
+}
+
+// UpdateChange 条目其它属性变化（如URL、图标、私密标记）
+type UpdateChange struct {
+	ID     int      `json:"id"`
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"`
+}
+
+// ListDiff 一类条目的差异
+type ListDiff struct {
+	Added   []ItemRef      `json:"added"`
+	Removed []ItemRef      `json:"removed"`
+	Renamed []RenameChange `json:"renamed"`
+	Moved   []MoveChange   `json:"moved"`
+	Updated []UpdateChange `json:"updated"`
+}
+
+// Empty 是否没有任何差异
+func (d ListDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Renamed) == 0 &&
+		len(d.Moved) == 0 && len(d.Updated) == 0
+}
+
+// FieldChange 设置字段变化
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// Diff 两份备份数据之间的结构化差异
+type Diff struct {
+	Shortcuts  ListDiff      `json:"shortcuts"`
+	Folders    ListDiff      `json:"folders"`
+	Partitions ListDiff      `json:"partitions"`
+	Settings   []FieldChange `json:"settings"`
+}
+
+// Empty 是否没有任何差异
+func (d *Diff) Empty() bool {
+	return d.Shortcuts.Empty() && d.Folders.Empty() && d.Partitions.Empty() && len(d.Settings) == 0
+}
+
+// entry 用于比较的通用条目
+type entry struct {
+	id          int
+	name        string
+	folderID    *int
+	partitionID *int
+	order       int
+	attrs       map[string]interface{} // 其它需要比较的属性
+}
+
+func shortcutEntries(items []models.Shortcut) []entry {
+	entries := make([]entry, 0, len(items))
+	for _, s := range items {
+		entries = append(entries, entry{
+			id:          s.ID,
+			name:        s.Name,
+			folderID:    s.FolderID,
+			partitionID: s.PartitionID,
+			order:       s.Order,
+			attrs: map[string]interface{}{
+				"url":         s.URL,
+				"icon":        s.Icon,
+				"iconUrl":     s.IconUrl,
+				"isPrivate":   s.IsPrivate,
+				"isPinned":    s.IsPinned,
+				"pinnedOrder": s.PinnedOrder,
+			},
+		})
+	}
+	return entries
+}
+
+func folderEntries(items []models.Folder) []entry {
+	entries := make([]entry, 0, len(items))
+	for _, f := range items {
+		entries = append(entries, entry{
+			id:          f.ID,
+			name:        f.Name,
+			partitionID: f.PartitionID,
+			order:       f.Order,
+			attrs: map[string]interface{}{
+				"collapsed": f.Collapsed,
+				"isPrivate": f.IsPrivate,
+			},
+		})
+	}
+	return entries
+}
+
+func partitionEntries(items []models.Partition) []entry {
+	entries := make([]entry, 0, len(items))
+	for _, p := range items {
+		entries = append(entries, entry{
+			id:    p.ID,
+			name:  p.Name,
+			order: p.Order,
+			attrs: map[string]interface{}{
+				"isPrivate": p.IsPrivate,
+			},
+		})
+	}
+	return entries
+}
+
+// Compare 比较两份备份数据，返回从 from 到 to 的差异
+func Compare(from, to *models.BackupData) *Diff {
+	return &Diff{
+		Shortcuts:  diffEntries(shortcutEntries(from.Shortcuts), shortcutEntries(to.Shortcuts)),
+		Folders:    diffEntries(folderEntries(from.Folders), folderEntries(to.Folders)),
+		Partitions: diffEntries(partitionEntries(from.Partitions), partitionEntries(to.Partitions)),
+		Settings:   diffSettings(from.Settings, to.Settings),
+	}
+}
+
+// diffEntries 按ID比较两组条目
+func diffEntries(from, to []entry) ListDiff {
+	diff := ListDiff{
+		Added:   []ItemRef{},
+		Removed: []ItemRef{},
+		Renamed: []RenameChange{},
+		Moved:   []MoveChange{},
+		Updated: []UpdateChange{},
+	}
+
+	fromMap := make(map[int]entry, len(from))
+	for _, e := range from {
+		fromMap[e.id] = e
+	}
+	toMap := make(map[int]entry, len(to))
+	for _, e := range to {
+		toMap[e.id] = e
+	}
+
+	for _, old := range from {
+		if _, ok := toMap[old.id]; !ok {
+			diff.Removed = append(diff.Removed, ItemRef{ID: old.id, Name: old.name})
+		}
+	}
+
+	for _, cur := range to {
+		old, ok := fromMap[cur.id]
+		if !ok {
+			diff.Added = append(diff.Added, ItemRef{ID: cur.id, Name: cur.name})
+			continue
+		}
+
+		if old.name != cur.name {
+			diff.Renamed = append(diff.Renamed, RenameChange{ID: cur.id, From: old.name, To: cur.name})
+		}
+
+		if !equalIntPtr(old.folderID, cur.folderID) || !equalIntPtr(old.partitionID, cur.partitionID) || old.order != cur.order {
+			diff.Moved = append(diff.Moved, MoveChange{
+				ID:              cur.id,
+				Name:            cur.name,
+				FromFolderID:    old.folderID,
+				ToFolderID:      cur.folderID,
+				FromPartitionID: old.partitionID,
+				ToPartitionID:   cur.partitionID,
+				FromOrder:       old.order,
+				ToOrder:         cur.order,
+			})
+		}
+
+		var fields []string
+		for key, val := range cur.attrs {
+			if old.attrs[key] != val {
+				fields = append(fields, key)
+			}
+		}
+		if len(fields) > 0 {
+			sort.Strings(fields)
+			diff.Updated = append(diff.Updated, UpdateChange{ID: cur.id, Name: cur.name, Fields: fields})
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].ID < diff.Added[j].ID })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].ID < diff.Removed[j].ID })
+	sort.Slice(diff.Renamed, func(i, j int) bool { return diff.Renamed[i].ID < diff.Renamed[j].ID })
+	sort.Slice(diff.Moved, func(i, j int) bool { return diff.Moved[i].ID < diff.Moved[j].ID })
+	sort.Slice(diff.Updated, func(i, j int) bool { return diff.Updated[i].ID < diff.Updated[j].ID })
+
+	return diff
+}
+
+// diffSettings 按JSON字段名比较外观设置
+func diffSettings(from, to models.Settings) []FieldChange {
+	changes := []FieldChange{}
+
+	fromVal := reflect.ValueOf(from)
+	toVal := reflect.ValueOf(to)
+	t := fromVal.Type()
+	for i := 0; i < t.NumField(); i++ {
+		a := fromVal.Field(i).Interface()
+		b := toVal.Field(i).Interface()
+		if a == b {
+			continue
+		}
+		changes = append(changes, FieldChange{
+			Field: jsonFieldName(t.Field(i)),
+			From:  summarizeValue(a),
+			To:    summarizeValue(b),
+		})
+	}
+
+	return changes
+}
+
+// jsonFieldName 获取结构体字段的JSON名称
+func jsonFieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// summarizeValue 过长的字符串（如base64背景图）只返回摘要，避免响应过大
+func summarizeValue(v interface{}) interface{} {
+	if s, ok := v.(string); ok && len(s) > 256 {
+		return fmt.Sprintf("%s...(%d bytes)", truncateUTF8(s, 64), len(s))
+	}
+	return v
+}
+
+// truncateUTF8 截取字符串的前 n 个字节，截断位置落在多字节字符中间时向前退到字符边界
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func equalIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
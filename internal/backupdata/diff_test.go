@@ -0,0 +1,120 @@
+package backupdata
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"itab-backend/internal/models"
+)
+
+func TestSummarizeValueKeepsRuneBoundary(t *testing.T) {
+	// 每个汉字 3 字节，64 字节处落在字符中间
+	s := strings.Repeat("书签标题", 30)
+	got, ok := summarizeValue(s).(string)
+	if !ok {
+		t.Fatalf("summarizeValue 返回 %T", summarizeValue(s))
+	}
+	if !utf8.ValidString(got) {
+		t.Fatalf("摘要不是合法的 UTF-8: %q", got)
+	}
+	prefix := strings.Repeat("书签标题", 30)[:63]
+	if !strings.HasPrefix(got, prefix+"...") {
+		t.Errorf("摘要 = %q，应以前 21 个字符开头", got)
+	}
+}
+
+func TestSummarizeValueShortString(t *testing.T) {
+	if got := summarizeValue("短标题"); got != "短标题" {
+		t.Errorf("summarizeValue = %v", got)
+	}
+	if got := summarizeValue(42); got != 42 {
+		t.Errorf("summarizeValue = %v", got)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	from := entityData()
+	to := entityData()
+	// 书签 2 删除，新增书签 6
+	to.Shortcuts = append(to.Shortcuts[:1], to.Shortcuts[2:]...)
+	to.Shortcuts = append(to.Shortcuts, models.Shortcut{ID: 6, Name: "新", PartitionID: intPtr(2), Order: 1})
+	// 书签 1 改名并修改地址，书签 3 移出文件夹，书签 4 只改变排序
+	to.Shortcuts[0].Name = "GitHub 主页"
+	to.Shortcuts[0].URL = "https://github.com/home"
+	to.Shortcuts[0].IsPrivate = true
+	to.Shortcuts[1].FolderID = nil
+	to.Shortcuts[1].Order = 1
+	to.Shortcuts[2].Order = 2
+	// 文件夹 2 移到分区 2 并折叠，新增分区 3
+	to.Folders[1].PartitionID = intPtr(2)
+	to.Folders[1].Order = 0
+	to.Folders[1].Collapsed = true
+	to.Partitions = append(to.Partitions, models.Partition{ID: 3, Name: "娱乐", Order: 2})
+	to.Settings.IconSize = 64
+
+	diff := Compare(from, to)
+	if diff.Empty() {
+		t.Fatal("Compare 应有差异")
+	}
+
+	shortcuts := diff.Shortcuts
+	if want := []ItemRef{{ID: 6, Name: "新"}}; !reflect.DeepEqual(shortcuts.Added, want) {
+		t.Errorf("新增 = %+v，应为 %+v", shortcuts.Added, want)
+	}
+	if want := []ItemRef{{ID: 2, Name: "Go"}}; !reflect.DeepEqual(shortcuts.Removed, want) {
+		t.Errorf("删除 = %+v，应为 %+v", shortcuts.Removed, want)
+	}
+	if want := []RenameChange{{ID: 1, From: "GitHub", To: "GitHub 主页"}}; !reflect.DeepEqual(shortcuts.Renamed, want) {
+		t.Errorf("重命名 = %+v，应为 %+v", shortcuts.Renamed, want)
+	}
+	if want := []UpdateChange{{ID: 1, Name: "GitHub 主页", Fields: []string{"isPrivate", "url"}}}; !reflect.DeepEqual(shortcuts.Updated, want) {
+		t.Errorf("修改 = %+v，应为 %+v", shortcuts.Updated, want)
+	}
+	wantMoved := []MoveChange{
+		{ID: 3, Name: "Docs", FromFolderID: intPtr(1), FromPartitionID: intPtr(1), ToPartitionID: intPtr(1), FromOrder: 0, ToOrder: 1},
+		{ID: 4, Name: "Jira", FromPartitionID: intPtr(2), ToPartitionID: intPtr(2), FromOrder: 0, ToOrder: 2},
+	}
+	if !reflect.DeepEqual(shortcuts.Moved, wantMoved) {
+		t.Errorf("移动 = %+v，应为 %+v", shortcuts.Moved, wantMoved)
+	}
+
+	folders := diff.Folders
+	if len(folders.Added) != 0 || len(folders.Removed) != 0 || len(folders.Renamed) != 0 {
+		t.Errorf("文件夹差异 = %+v", folders)
+	}
+	if len(folders.Moved) != 1 || folders.Moved[0].ID != 2 || *folders.Moved[0].ToPartitionID != 2 || folders.Moved[0].ToOrder != 0 {
+		t.Errorf("文件夹移动 = %+v", folders.Moved)
+	}
+	if want := []UpdateChange{{ID: 2, Name: "文档", Fields: []string{"collapsed"}}}; !reflect.DeepEqual(folders.Updated, want) {
+		t.Errorf("文件夹修改 = %+v，应为 %+v", folders.Updated, want)
+	}
+
+	if want := []ItemRef{{ID: 3, Name: "娱乐"}}; !reflect.DeepEqual(diff.Partitions.Added, want) {
+		t.Errorf("新增分区 = %+v，应为 %+v", diff.Partitions.Added, want)
+	}
+	if want := []FieldChange{{Field: "iconSize", From: 0, To: 64}}; !reflect.DeepEqual(diff.Settings, want) {
+		t.Errorf("设置变化 = %+v，应为 %+v", diff.Settings, want)
+	}
+}
+
+func TestCompareIdenticalAndEmpty(t *testing.T) {
+	if diff := Compare(entityData(), entityData()); !diff.Empty() {
+		t.Errorf("相同数据的差异 = %+v", diff)
+	}
+
+	// 与空数据比较时所有条目均为新增
+	diff := Compare(&models.BackupData{}, entityData())
+	if len(diff.Shortcuts.Added) != 5 || len(diff.Folders.Added) != 2 || len(diff.Partitions.Added) != 2 {
+		t.Errorf("与空数据比较 = %+v", diff)
+	}
+	if len(diff.Shortcuts.Moved) != 0 || len(diff.Shortcuts.Removed) != 0 {
+		t.Errorf("新增条目不应计为移动或删除: %+v", diff.Shortcuts)
+	}
+
+	diff = Compare(entityData(), &models.BackupData{})
+	if len(diff.Shortcuts.Removed) != 5 || len(diff.Shortcuts.Added) != 0 {
+		t.Errorf("与空数据比较 = %+v", diff.Shortcuts)
+	}
+}
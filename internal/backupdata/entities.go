@@ -0,0 +1,447 @@
+package backupdata
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"itab-backend/internal/models"
+)
+
+// 条目操作错误
+var (
+	ErrEntityNotFound   = errors.New("条目不存在")
+	ErrDuplicateID      = errors.New("ID已存在")
+	ErrInvalidReference = errors.New("引用的条目不存在")
+	ErrNotEmpty         = errors.New("条目下仍有内容")
+)
+
+// reorder 将分组内的条目按当前顺序重新编号为 0..n-1，排序值相同时按ID排列，结果与条目在数组中的位置无关
+// moved >= 0 时先把该下标的条目移到分组内的 position 位置，position 为 nil 或越界时移到末尾
+func reorder(n int, inGroup func(i int) bool, order func(i int) int, id func(i int) int, setOrder func(i, order int), moved int, position *int) {
+	var idx []int
+	for i := 0; i < n; i++ {
+		if inGroup(i) && i != moved {
+			idx = append(idx, i)
+		}
+	}
+	sort.Slice(idx, func(a, b int) bool {
+		if oa, ob := order(idx[a]), order(idx[b]); oa != ob {
+			return oa < ob
+		}
+		return id(idx[a]) < id(idx[b])
+	})
+
+	if moved >= 0 {
+		pos := len(idx)
+		if position != nil && *position >= 0 && *position < pos {
+			pos = *position
+		}
+		idx = append(idx, 0)
+		copy(idx[pos+1:], idx[pos:])
+		idx[pos] = moved
+	}
+
+	for i, k := range idx {
+		setOrder(k, i)
+	}
+}
+
+func intPtrKey(p *int) string {
+	if p == nil {
+		return "-"
+	}
+	return fmt.Sprint(*p)
+}
+
+// ---------- 书签 ----------
+
+// shortcutGroup 书签的排序分组：在文件夹内时按文件夹分组，否则按分区分组
+func shortcutGroup(s models.Shortcut) string {
+	if s.FolderID != nil {
+		return "folder:" + intPtrKey(s.FolderID)
+	}
+	return "partition:" + intPtrKey(s.PartitionID)
+}
+
+func reorderShortcuts(bd *models.BackupData, group string, moved int, position *int) {
+	reorder(len(bd.Shortcuts),
+		func(i int) bool { return shortcutGroup(bd.Shortcuts[i]) == group },
+		func(i int) int { return bd.Shortcuts[i].Order },
+		func(i int) int { return bd.Shortcuts[i].ID },
+		func(i, order int) { bd.Shortcuts[i].Order = order },
+		moved, position)
+}
+
+func reorderPinned(bd *models.BackupData, moved int, position *int) {
+	reorder(len(bd.Shortcuts),
+		func(i int) bool { return bd.Shortcuts[i].IsPinned },
+		func(i int) int { return bd.Shortcuts[i].PinnedOrder },
+		func(i int) int { return bd.Shortcuts[i].ID },
+		func(i, order int) { bd.Shortcuts[i].PinnedOrder = order },
+		moved, position)
+}
+
+// FindShortcut 按ID查找书签，返回下标，不存在时返回 -1
+func FindShortcut(bd *models.BackupData, id int) int {
+	for i, s := range bd.Shortcuts {
+		if s.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func checkShortcutRefs(bd *models.BackupData, s models.Shortcut) error {
+	if s.FolderID != nil && FindFolder(bd, *s.FolderID) < 0 {
+		return fmt.Errorf("%w: 文件夹 %d", ErrInvalidReference, *s.FolderID)
+	}
+	if s.PartitionID != nil && FindPartition(bd, *s.PartitionID) < 0 {
+		return fmt.Errorf("%w: 分区 %d", ErrInvalidReference, *s.PartitionID)
+	}
+	return nil
+}
+
+// AddShortcut 新增书签，ID为0时自动分配
+// position/pinnedPosition 为在分组内及置顶栏中的位置，nil 表示追加到末尾
+func AddShortcut(bd *models.BackupData, s models.Shortcut, position, pinnedPosition *int) (models.Shortcut, error) {
+	if s.ID == 0 {
+		for _, item := range bd.Shortcuts {
+			if item.ID > s.ID {
+				s.ID = item.ID
+			}
+		}
+		s.ID++
+	} else if FindShortcut(bd, s.ID) >= 0 {
+		return s, fmt.Errorf("%w: 书签 %d", ErrDuplicateID, s.ID)
+	}
+	if err := checkShortcutRefs(bd, s); err != nil {
+		return s, err
+	}
+
+	bd.Shortcuts = append(bd.Shortcuts, s)
+	i := len(bd.Shortcuts) - 1
+	reorderShortcuts(bd, shortcutGroup(s), i, position)
+	if s.IsPinned {
+		reorderPinned(bd, i, pinnedPosition)
+	} else {
+		bd.Shortcuts[i].PinnedOrder = 0
+	}
+
+	return bd.Shortcuts[i], nil
+}
+
+// UpdateShortcut 更新书签
+// position/pinnedPosition 为 nil 时保持原位置；移动到新分组时追加到末尾
+func UpdateShortcut(bd *models.BackupData, id int, s models.Shortcut, position, pinnedPosition *int) (models.Shortcut, error) {
+	i := FindShortcut(bd, id)
+	if i < 0 {
+		return s, fmt.Errorf("%w: 书签 %d", ErrEntityNotFound, id)
+	}
+	s.ID = id
+	if err := checkShortcutRefs(bd, s); err != nil {
+		return s, err
+	}
+
+	old := bd.Shortcuts[i]
+	s.Order = old.Order
+	s.PinnedOrder = old.PinnedOrder
+	bd.Shortcuts[i] = s
+
+	if shortcutGroup(old) != shortcutGroup(s) {
+		reorderShortcuts(bd, shortcutGroup(old), -1, nil)
+		reorderShortcuts(bd, shortcutGroup(s), i, position)
+	} else if position != nil {
+		reorderShortcuts(bd, shortcutGroup(s), i, position)
+	}
+
+	switch {
+	case s.IsPinned && (!old.IsPinned || pinnedPosition != nil):
+		reorderPinned(bd, i, pinnedPosition)
+	case !s.IsPinned:
+		bd.Shortcuts[i].PinnedOrder = 0
+		if old.IsPinned {
+			reorderPinned(bd, -1, nil)
+		}
+	}
+
+	return bd.Shortcuts[i], nil
+}
+
+// DeleteShortcut 删除书签
+func DeleteShortcut(bd *models.BackupData, id int) error {
+	i := FindShortcut(bd, id)
+	if i < 0 {
+		return fmt.Errorf("%w: 书签 %d", ErrEntityNotFound, id)
+	}
+
+	old := bd.Shortcuts[i]
+	bd.Shortcuts = append(bd.Shortcuts[:i], bd.Shortcuts[i+1:]...)
+	reorderShortcuts(bd, shortcutGroup(old), -1, nil)
+	if old.IsPinned {
+		reorderPinned(bd, -1, nil)
+	}
+	return nil
+}
+
+// ---------- 文件夹 ----------
+
+func reorderFolders(bd *models.BackupData, partition string, moved int, position *int) {
+	reorder(len(bd.Folders),
+		func(i int) bool { return intPtrKey(bd.Folders[i].PartitionID) == partition },
+		func(i int) int { return bd.Folders[i].Order },
+		func(i int) int { return bd.Folders[i].ID },
+		func(i, order int) { bd.Folders[i].Order = order },
+		moved, position)
+}
+
+// FindFolder 按ID查找文件夹，返回下标，不存在时返回 -1
+func FindFolder(bd *models.BackupData, id int) int {
+	for i, f := range bd.Folders {
+		if f.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddFolder 新增文件夹，ID为0时自动分配，position 为 nil 表示追加到分区末尾
+func AddFolder(bd *models.BackupData, f models.Folder, position *int) (models.Folder, error) {
+	if f.ID == 0 {
+		for _, item := range bd.Folders {
+			if item.ID > f.ID {
+				f.ID = item.ID
+			}
+		}
+		f.ID++
+	} else if FindFolder(bd, f.ID) >= 0 {
+		return f, fmt.Errorf("%w: 文件夹 %d", ErrDuplicateID, f.ID)
+	}
+	if f.PartitionID != nil && FindPartition(bd, *f.PartitionID) < 0 {
+		return f, fmt.Errorf("%w: 分区 %d", ErrInvalidReference, *f.PartitionID)
+	}
+
+	bd.Folders = append(bd.Folders, f)
+	i := len(bd.Folders) - 1
+	reorderFolders(bd, intPtrKey(f.PartitionID), i, position)
+	return bd.Folders[i], nil
+}
+
+// UpdateFolder 更新文件夹，position 为 nil 时保持原位置；移动到其它分区时追加到末尾
+// 文件夹内书签的分区随文件夹一起变更
+func UpdateFolder(bd *models.BackupData, id int, f models.Folder, position *int) (models.Folder, error) {
+	i := FindFolder(bd, id)
+	if i < 0 {
+		return f, fmt.Errorf("%w: 文件夹 %d", ErrEntityNotFound, id)
+	}
+	f.ID = id
+	if f.PartitionID != nil && FindPartition(bd, *f.PartitionID) < 0 {
+		return f, fmt.Errorf("%w: 分区 %d", ErrInvalidReference, *f.PartitionID)
+	}
+
+	old := bd.Folders[i]
+	f.Order = old.Order
+	bd.Folders[i] = f
+
+	if intPtrKey(old.PartitionID) != intPtrKey(f.PartitionID) {
+		reorderFolders(bd, intPtrKey(old.PartitionID), -1, nil)
+		reorderFolders(bd, intPtrKey(f.PartitionID), i, position)
+		for k := range bd.Shortcuts {
+			if bd.Shortcuts[k].FolderID != nil && *bd.Shortcuts[k].FolderID == id {
+				bd.Shortcuts[k].PartitionID = f.PartitionID
+			}
+		}
+	} else if position != nil {
+		reorderFolders(bd, intPtrKey(f.PartitionID), i, position)
+	}
+
+	return bd.Folders[i], nil
+}
+
+// DeleteFolder 删除文件夹，cascade 为 true 时同时删除其中的书签，否则文件夹非空时返回 ErrNotEmpty
+func DeleteFolder(bd *models.BackupData, id int, cascade bool) error {
+	i := FindFolder(bd, id)
+	if i < 0 {
+		return fmt.Errorf("%w: 文件夹 %d", ErrEntityNotFound, id)
+	}
+
+	inFolder := func(s models.Shortcut) bool { return s.FolderID != nil && *s.FolderID == id }
+	if err := removeShortcuts(bd, inFolder, cascade); err != nil {
+		return fmt.Errorf("%w: 文件夹 %d 中仍有书签", err, id)
+	}
+
+	old := bd.Folders[i]
+	bd.Folders = append(bd.Folders[:i], bd.Folders[i+1:]...)
+	reorderFolders(bd, intPtrKey(old.PartitionID), -1, nil)
+	return nil
+}
+
+// removeShortcuts 删除满足条件的书签，cascade 为 false 且存在匹配书签时返回 ErrNotEmpty
+func removeShortcuts(bd *models.BackupData, match func(models.Shortcut) bool, cascade bool) error {
+	kept := bd.Shortcuts[:0]
+	removedPinned := false
+	for _, s := range bd.Shortcuts {
+		if !match(s) {
+			kept = append(kept, s)
+			continue
+		}
+		if !cascade {
+			return ErrNotEmpty
+		}
+		removedPinned = removedPinned || s.IsPinned
+	}
+	bd.Shortcuts = kept
+	if removedPinned {
+		reorderPinned(bd, -1, nil)
+	}
+	return nil
+}
+
+// ---------- 分区 ----------
+
+func reorderPartitions(bd *models.BackupData, moved int, position *int) {
+	reorder(len(bd.Partitions),
+		func(i int) bool { return true },
+		func(i int) int { return bd.Partitions[i].Order },
+		func(i int) int { return bd.Partitions[i].ID },
+		func(i, order int) { bd.Partitions[i].Order = order },
+		moved, position)
+}
+
+// FindPartition 按ID查找分区，返回下标，不存在时返回 -1
+func FindPartition(bd *models.BackupData, id int) int {
+	for i, p := range bd.Partitions {
+		if p.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddPartition 新增分区，ID为0时自动分配，position 为 nil 表示追加到末尾
+func AddPartition(bd *models.BackupData, p models.Partition, position *int) (models.Partition, error) {
+	if p.ID == 0 {
+		for _, item := range bd.Partitions {
+			if item.ID > p.ID {
+				p.ID = item.ID
+			}
+		}
+		p.ID++
+	} else if FindPartition(bd, p.ID) >= 0 {
+		return p, fmt.Errorf("%w: 分区 %d", ErrDuplicateID, p.ID)
+	}
+
+	bd.Partitions = append(bd.Partitions, p)
+	i := len(bd.Partitions) - 1
+	reorderPartitions(bd, i, position)
+	return bd.Partitions[i], nil
+}
+
+// UpdatePartition 更新分区，position 为 nil 时保持原位置
+func UpdatePartition(bd *models.BackupData, id int, p models.Partition, position *int) (models.Partition, error) {
+	i := FindPartition(bd, id)
+	if i < 0 {
+		return p, fmt.Errorf("%w: 分区 %d", ErrEntityNotFound, id)
+	}
+	p.ID = id
+	p.Order = bd.Partitions[i].Order
+	bd.Partitions[i] = p
+
+	if position != nil {
+		reorderPartitions(bd, i, position)
+	}
+	return bd.Partitions[i], nil
+}
+
+// DeletePartition 删除分区，cascade 为 true 时同时删除其中的文件夹和书签，否则分区非空时返回 ErrNotEmpty
+func DeletePartition(bd *models.BackupData, id int, cascade bool) error {
+	i := FindPartition(bd, id)
+	if i < 0 {
+		return fmt.Errorf("%w: 分区 %d", ErrEntityNotFound, id)
+	}
+
+	inPartition := func(p *int) bool { return p != nil && *p == id }
+	folderIDs := make(map[int]bool)
+	for _, f := range bd.Folders {
+		if inPartition(f.PartitionID) {
+			if !cascade {
+				return fmt.Errorf("%w: 分区 %d 中仍有文件夹", ErrNotEmpty, id)
+			}
+			folderIDs[f.ID] = true
+		}
+	}
+	err := removeShortcuts(bd, func(s models.Shortcut) bool {
+		return inPartition(s.PartitionID) || (s.FolderID != nil && folderIDs[*s.FolderID])
+	}, cascade)
+	if err != nil {
+		return fmt.Errorf("%w: 分区 %d 中仍有书签", err, id)
+	}
+
+	folders := bd.Folders[:0]
+	for _, f := range bd.Folders {
+		if !folderIDs[f.ID] {
+			folders = append(folders, f)
+		}
+	}
+	bd.Folders = folders
+
+	bd.Partitions = append(bd.Partitions[:i], bd.Partitions[i+1:]...)
+	reorderPartitions(bd, -1, nil)
+	return nil
+}
+
+// ---------- 搜索引擎 ----------
+
+// FindSearchEngine 按ID查找搜索引擎，返回下标，不存在时返回 -1
+func FindSearchEngine(bd *models.BackupData, id int) int {
+	for i, e := range bd.SearchEngines {
+		if e.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddSearchEngine 新增搜索引擎，ID为0时自动分配，position 为 nil 表示追加到末尾
+func AddSearchEngine(bd *models.BackupData, e models.SearchEngine, position *int) (models.SearchEngine, error) {
+	if e.ID == 0 {
+		for _, item := range bd.SearchEngines {
+			if item.ID > e.ID {
+				e.ID = item.ID
+			}
+		}
+		e.ID++
+	} else if FindSearchEngine(bd, e.ID) >= 0 {
+		return e, fmt.Errorf("%w: 搜索引擎 %d", ErrDuplicateID, e.ID)
+	}
+
+	pos := len(bd.SearchEngines)
+	if position != nil && *position >= 0 && *position < pos {
+		pos = *position
+	}
+	bd.SearchEngines = append(bd.SearchEngines, models.SearchEngine{})
+	copy(bd.SearchEngines[pos+1:], bd.SearchEngines[pos:])
+	bd.SearchEngines[pos] = e
+	return e, nil
+}
+
+// UpdateSearchEngine 更新搜索引擎
+func UpdateSearchEngine(bd *models.BackupData, id int, e models.SearchEngine) (models.SearchEngine, error) {
+	i := FindSearchEngine(bd, id)
+	if i < 0 {
+		return e, fmt.Errorf("%w: 搜索引擎 %d", ErrEntityNotFound, id)
+	}
+	e.ID = id
+	bd.SearchEngines[i] = e
+	return e, nil
+}
+
+// DeleteSearchEngine 删除搜索引擎
+func DeleteSearchEngine(bd *models.BackupData, id int) error {
+	i := FindSearchEngine(bd, id)
+	if i < 0 {
+		return fmt.Errorf("%w: 搜索引擎 %d", ErrEntityNotFound, id)
+	}
+	bd.SearchEngines = append(bd.SearchEngines[:i], bd.SearchEngines[i+1:]...)
+	return nil
+}
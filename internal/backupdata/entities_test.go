@@ -0,0 +1,278 @@
+package backupdata
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"itab-backend/internal/models"
+)
+
+// entityData 排序从 0 开始的数据：分区 1 有文件夹 1、2，分区 2 只有书签
+// 书签 1、3 置顶，书签 3、5 在文件夹 1 中
+func entityData() *models.BackupData {
+	return &models.BackupData{
+		Partitions: []models.Partition{{ID: 1, Name: "主页", Order: 0}, {ID: 2, Name: "工作", Order: 1}},
+		Folders: []models.Folder{
+			{ID: 1, Name: "开发", PartitionID: intPtr(1), Order: 0},
+			{ID: 2, Name: "文档", PartitionID: intPtr(1), Order: 1},
+		},
+		Shortcuts: []models.Shortcut{
+			{ID: 1, Name: "GitHub", PartitionID: intPtr(1), Order: 0, IsPinned: true, PinnedOrder: 0},
+			{ID: 2, Name: "Go", PartitionID: intPtr(1), Order: 1},
+			{ID: 3, Name: "Docs", PartitionID: intPtr(1), FolderID: intPtr(1), Order: 0, IsPinned: true, PinnedOrder: 1},
+			{ID: 4, Name: "Jira", PartitionID: intPtr(2), Order: 0},
+			{ID: 5, Name: "Blog", PartitionID: intPtr(1), FolderID: intPtr(1), Order: 1},
+		},
+	}
+}
+
+func pinnedOrders(bd *models.BackupData) map[int]int {
+	orders := make(map[int]int)
+	for _, s := range bd.Shortcuts {
+		if s.IsPinned {
+			orders[s.ID] = s.PinnedOrder
+		}
+	}
+	return orders
+}
+
+func folderOrders(bd *models.BackupData) map[int]int {
+	orders := make(map[int]int)
+	for _, f := range bd.Folders {
+		orders[f.ID] = f.Order
+	}
+	return orders
+}
+
+func shortcutByID(t *testing.T, bd *models.BackupData, id int) models.Shortcut {
+	t.Helper()
+	i := FindShortcut(bd, id)
+	if i < 0 {
+		t.Fatalf("书签 %d 不存在", id)
+	}
+	return bd.Shortcuts[i]
+}
+
+func TestUpdateShortcutMovesAcrossGroups(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       int
+		change   func(s *models.Shortcut)
+		position *int
+		want     map[int]int
+	}{
+		{
+			name:   "移到其它分区末尾",
+			id:     1,
+			change: func(s *models.Shortcut) { s.PartitionID = intPtr(2) },
+			want:   map[int]int{1: 1, 2: 0, 3: 0, 4: 0, 5: 1},
+		},
+		{
+			name:     "移到其它分区指定位置",
+			id:       1,
+			change:   func(s *models.Shortcut) { s.PartitionID = intPtr(2) },
+			position: intPtr(0),
+			want:     map[int]int{1: 0, 2: 0, 3: 0, 4: 1, 5: 1},
+		},
+		{
+			name:     "移入文件夹",
+			id:       2,
+			change:   func(s *models.Shortcut) { s.FolderID = intPtr(1) },
+			position: intPtr(1),
+			want:     map[int]int{1: 0, 2: 1, 3: 0, 4: 0, 5: 2},
+		},
+		{
+			name:   "移出文件夹",
+			id:     3,
+			change: func(s *models.Shortcut) { s.FolderID = nil },
+			want:   map[int]int{1: 0, 2: 1, 3: 2, 4: 0, 5: 0},
+		},
+		{
+			name:     "同一分组内移动",
+			id:       5,
+			change:   func(s *models.Shortcut) {},
+			position: intPtr(0),
+			want:     map[int]int{1: 0, 2: 1, 3: 1, 4: 0, 5: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bd := entityData()
+			s := shortcutByID(t, bd, tt.id)
+			tt.change(&s)
+			if _, err := UpdateShortcut(bd, tt.id, s, tt.position, nil); err != nil {
+				t.Fatalf("UpdateShortcut: %v", err)
+			}
+			if got := shortcutOrders(bd); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("书签排序 = %v，应为 %v", got, tt.want)
+			}
+			// 移动不影响置顶排序
+			if got := pinnedOrders(bd); !reflect.DeepEqual(got, map[int]int{1: 0, 3: 1}) {
+				t.Errorf("置顶排序 = %v", got)
+			}
+		})
+	}
+}
+
+func TestUpdateShortcutRejectsMissingFolder(t *testing.T) {
+	bd := entityData()
+	s := shortcutByID(t, bd, 2)
+	s.FolderID = intPtr(9)
+	if _, err := UpdateShortcut(bd, 2, s, nil, nil); !errors.Is(err, ErrInvalidReference) {
+		t.Fatalf("UpdateShortcut = %v，应为 ErrInvalidReference", err)
+	}
+	if !reflect.DeepEqual(bd, entityData()) {
+		t.Error("失败时不应修改数据")
+	}
+}
+
+func TestUpdateFolderMovesAcrossPartitions(t *testing.T) {
+	bd := entityData()
+	f := bd.Folders[0]
+	f.PartitionID = intPtr(2)
+	if _, err := UpdateFolder(bd, 1, f, nil); err != nil {
+		t.Fatalf("UpdateFolder: %v", err)
+	}
+	if got := folderOrders(bd); !reflect.DeepEqual(got, map[int]int{1: 0, 2: 0}) {
+		t.Errorf("文件夹排序 = %v", got)
+	}
+	// 文件夹内的书签随文件夹移到新分区，文件夹内的排序不变
+	for _, id := range []int{3, 5} {
+		if s := shortcutByID(t, bd, id); intPtrKey(s.PartitionID) != "2" {
+			t.Errorf("书签 %d 的分区 = %s，应为 2", id, intPtrKey(s.PartitionID))
+		}
+	}
+	if got := shortcutOrders(bd); !reflect.DeepEqual(got, map[int]int{1: 0, 2: 1, 3: 0, 4: 0, 5: 1}) {
+		t.Errorf("书签排序 = %v", got)
+	}
+}
+
+func TestDeleteRenumbersRemaining(t *testing.T) {
+	t.Run("删除书签", func(t *testing.T) {
+		bd := entityData()
+		if err := DeleteShortcut(bd, 1); err != nil {
+			t.Fatalf("DeleteShortcut: %v", err)
+		}
+		if got := shortcutOrders(bd); !reflect.DeepEqual(got, map[int]int{2: 0, 3: 0, 4: 0, 5: 1}) {
+			t.Errorf("书签排序 = %v", got)
+		}
+		if got := pinnedOrders(bd); !reflect.DeepEqual(got, map[int]int{3: 0}) {
+			t.Errorf("置顶排序 = %v", got)
+		}
+	})
+
+	t.Run("删除非空文件夹", func(t *testing.T) {
+		bd := entityData()
+		if err := DeleteFolder(bd, 1, false); !errors.Is(err, ErrNotEmpty) {
+			t.Fatalf("DeleteFolder = %v，应为 ErrNotEmpty", err)
+		}
+		if !reflect.DeepEqual(bd, entityData()) {
+			t.Error("失败时不应修改数据")
+		}
+	})
+
+	t.Run("级联删除文件夹", func(t *testing.T) {
+		bd := entityData()
+		if err := DeleteFolder(bd, 1, true); err != nil {
+			t.Fatalf("DeleteFolder: %v", err)
+		}
+		if got := folderOrders(bd); !reflect.DeepEqual(got, map[int]int{2: 0}) {
+			t.Errorf("文件夹排序 = %v", got)
+		}
+		if got := shortcutOrders(bd); !reflect.DeepEqual(got, map[int]int{1: 0, 2: 1, 4: 0}) {
+			t.Errorf("书签排序 = %v", got)
+		}
+		if got := pinnedOrders(bd); !reflect.DeepEqual(got, map[int]int{1: 0}) {
+			t.Errorf("置顶排序 = %v", got)
+		}
+	})
+
+	t.Run("级联删除分区", func(t *testing.T) {
+		bd := entityData()
+		if err := DeletePartition(bd, 1, true); err != nil {
+			t.Fatalf("DeletePartition: %v", err)
+		}
+		if len(bd.Partitions) != 1 || bd.Partitions[0].ID != 2 || bd.Partitions[0].Order != 0 {
+			t.Errorf("分区 = %+v", bd.Partitions)
+		}
+		if len(bd.Folders) != 0 || len(pinnedOrders(bd)) != 0 {
+			t.Errorf("分区内的文件夹和置顶书签应一并删除: %+v %v", bd.Folders, pinnedOrders(bd))
+		}
+		if got := shortcutOrders(bd); !reflect.DeepEqual(got, map[int]int{4: 0}) {
+			t.Errorf("书签排序 = %v", got)
+		}
+	})
+}
+
+func TestPinnedOrder(t *testing.T) {
+	bd := entityData()
+
+	added, err := AddShortcut(bd, models.Shortcut{Name: "新", PartitionID: intPtr(2), IsPinned: true}, nil, intPtr(0))
+	if err != nil {
+		t.Fatalf("AddShortcut: %v", err)
+	}
+	if added.ID != 6 || added.Order != 1 || added.PinnedOrder != 0 {
+		t.Errorf("新增的书签 = %+v，应为 ID 6、分组内第 2 个、置顶第 1 个", added)
+	}
+	if got := pinnedOrders(bd); !reflect.DeepEqual(got, map[int]int{6: 0, 1: 1, 3: 2}) {
+		t.Errorf("置顶排序 = %v", got)
+	}
+
+	// 只调整置顶位置，分组内排序不变
+	s := shortcutByID(t, bd, 3)
+	if _, err := UpdateShortcut(bd, 3, s, nil, intPtr(0)); err != nil {
+		t.Fatalf("UpdateShortcut: %v", err)
+	}
+	if got := pinnedOrders(bd); !reflect.DeepEqual(got, map[int]int{3: 0, 6: 1, 1: 2}) {
+		t.Errorf("调整后的置顶排序 = %v", got)
+	}
+	if shortcutByID(t, bd, 3).Order != 0 {
+		t.Error("调整置顶位置不应改变分组内排序")
+	}
+
+	// 取消置顶后清零，其余置顶书签重新编号
+	s = shortcutByID(t, bd, 6)
+	s.IsPinned = false
+	if _, err := UpdateShortcut(bd, 6, s, nil, nil); err != nil {
+		t.Fatalf("UpdateShortcut: %v", err)
+	}
+	if got := shortcutByID(t, bd, 6).PinnedOrder; got != 0 {
+		t.Errorf("取消置顶后 PinnedOrder = %d，应为 0", got)
+	}
+	if got := pinnedOrders(bd); !reflect.DeepEqual(got, map[int]int{3: 0, 1: 1}) {
+		t.Errorf("取消置顶后的置顶排序 = %v", got)
+	}
+
+	// 重新置顶且未指定位置时追加到末尾
+	s.IsPinned = true
+	if _, err := UpdateShortcut(bd, 6, s, nil, nil); err != nil {
+		t.Fatalf("UpdateShortcut: %v", err)
+	}
+	if got := pinnedOrders(bd); !reflect.DeepEqual(got, map[int]int{3: 0, 1: 1, 6: 2}) {
+		t.Errorf("重新置顶后的置顶排序 = %v", got)
+	}
+}
+
+func TestReorderTieBreaksOnID(t *testing.T) {
+	// 排序值相同时按ID排列，与条目在数组中的位置无关
+	for _, ids := range [][]int{{3, 1, 2}, {2, 3, 1}} {
+		bd := &models.BackupData{Partitions: []models.Partition{{ID: 1}}}
+		for _, id := range ids {
+			bd.Shortcuts = append(bd.Shortcuts, models.Shortcut{ID: id, PartitionID: intPtr(1), IsPinned: true})
+		}
+		if _, err := AddShortcut(bd, models.Shortcut{ID: 9, PartitionID: intPtr(1)}, intPtr(1), nil); err != nil {
+			t.Fatalf("AddShortcut: %v", err)
+		}
+		if got, want := shortcutOrders(bd), map[int]int{1: 0, 9: 1, 2: 2, 3: 3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("数组顺序 %v 的书签排序 = %v，应为 %v", ids, got, want)
+		}
+
+		if err := DeleteShortcut(bd, 2); err != nil {
+			t.Fatalf("DeleteShortcut: %v", err)
+		}
+		if got, want := pinnedOrders(bd), map[int]int{1: 0, 3: 1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("数组顺序 %v 的置顶排序 = %v，应为 %v", ids, got, want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package backupdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"itab-backend/internal/models"
+)
+
+// 冲突原因
+const (
+	ConflictBothModified    = "both_modified"    // 双方修改了同一字段且结果不同
+	ConflictDeletedLocally  = "deleted_locally"  // 客户端删除，服务端修改
+	ConflictDeletedRemotely = "deleted_remotely" // 服务端删除，客户端修改
+	ConflictBothAdded       = "both_added"       // 双方新增了相同ID但内容不同的条目
+)
+
+// ErrMergeDuplicateID 参与合并的数据中存在重复ID，条目无法按ID一一对应
+var ErrMergeDuplicateID = errors.New("存在重复ID，无法按ID合并")
+
+// Conflict 三方合并冲突
+type Conflict struct {
+	Type   string      `json:"type"` // shortcut/folder/partition/searchEngine/password/settings
+	ID     int64       `json:"id,omitempty"`
+	Field  string      `json:"field,omitempty"`
+	Reason string      `json:"reason"`
+	Base   interface{} `json:"base"`
+	Local  interface{} `json:"local"`  // 客户端的值
+	Remote interface{} `json:"remote"` // 服务端当前的值
+}
+
+// Merge 对备份数据做三方合并
+// base 为客户端修改前所基于的版本，local 为客户端上传的数据，remote 为服务端当前数据。
+// 条目按ID逐字段合并；只要存在冲突，返回的冲突列表非空且合并结果不应被保存。
+// 任一方存在重复ID时条目无法按ID对应，返回包装 ErrMergeDuplicateID 的错误
+func Merge(base, local, remote *models.BackupData) (*models.BackupData, []Conflict, error) {
+	for _, side := range []struct {
+		name string
+		data *models.BackupData
+	}{{"基础版本", base}, {"上传数据", local}, {"服务端数据", remote}} {
+		if dup := firstDuplicate(side.data); dup != "" {
+			return nil, nil, fmt.Errorf("%w: %s中的%s", ErrMergeDuplicateID, side.name, dup)
+		}
+	}
+
+	var conflicts []Conflict
+	merged := *local
+
+	merged.Partitions, conflicts = mergeList(base.Partitions, local.Partitions, remote.Partitions,
+		func(p models.Partition) int64 { return int64(p.ID) }, "partition", conflicts)
+	merged.Folders, conflicts = mergeList(base.Folders, local.Folders, remote.Folders,
+		func(f models.Folder) int64 { return int64(f.ID) }, "folder", conflicts)
+	merged.Shortcuts, conflicts = mergeList(base.Shortcuts, local.Shortcuts, remote.Shortcuts,
+		func(s models.Shortcut) int64 { return int64(s.ID) }, "shortcut", conflicts)
+	merged.SearchEngines, conflicts = mergeList(base.SearchEngines, local.SearchEngines, remote.SearchEngines,
+		func(e models.SearchEngine) int64 { return int64(e.ID) }, "searchEngine", conflicts)
+	merged.Passwords, conflicts = mergeList(base.Passwords, local.Passwords, remote.Passwords,
+		func(p models.Password) int64 { return p.ID }, "password", conflicts)
+
+	settings, fieldConflicts := mergeFields(toMap(base.Settings), toMap(local.Settings), toMap(remote.Settings))
+	for _, fc := range fieldConflicts {
+		fc.Type = "settings"
+		conflicts = append(conflicts, fc)
+	}
+	fromMap(settings, &merged.Settings)
+
+	return &merged, conflicts, nil
+}
+
+// firstDuplicate 返回第一个ID重复的条目描述，如「书签 3」，ID均唯一时返回空字符串
+func firstDuplicate(bd *models.BackupData) string {
+	if id, ok := duplicateID(bd.Partitions, func(p models.Partition) int64 { return int64(p.ID) }); ok {
+		return fmt.Sprintf("分区 %d", id)
+	}
+	if id, ok := duplicateID(bd.Folders, func(f models.Folder) int64 { return int64(f.ID) }); ok {
+		return fmt.Sprintf("文件夹 %d", id)
+	}
+	if id, ok := duplicateID(bd.Shortcuts, func(s models.Shortcut) int64 { return int64(s.ID) }); ok {
+		return fmt.Sprintf("书签 %d", id)
+	}
+	if id, ok := duplicateID(bd.SearchEngines, func(e models.SearchEngine) int64 { return int64(e.ID) }); ok {
+		return fmt.Sprintf("搜索引擎 %d", id)
+	}
+	if id, ok := duplicateID(bd.Passwords, func(p models.Password) int64 { return p.ID }); ok {
+		return fmt.Sprintf("密码 %d", id)
+	}
+	return ""
+}
+
+// duplicateID 返回第一个重复的ID
+func duplicateID[T any](items []T, id func(T) int64) (int64, bool) {
+	seen := make(map[int64]bool, len(items))
+	for _, item := range items {
+		key := id(item)
+		if seen[key] {
+			return key, true
+		}
+		seen[key] = true
+	}
+	return 0, false
+}
+
+// mergeList 按ID对一组条目做三方合并
+// 结果顺序以服务端为准，客户端新增的条目追加在后面
+func mergeList[T any](base, local, remote []T, id func(T) int64, kind string, conflicts []Conflict) ([]T, []Conflict) {
+	baseMap := indexByID(base, id)
+	localMap := indexByID(local, id)
+	remoteMap := indexByID(remote, id)
+
+	result := make([]T, 0, len(remote)+len(local))
+	var pending []Conflict
+
+	resolve := func(key int64) {
+		b, inBase := baseMap[key]
+		l, inLocal := localMap[key]
+		r, inRemote := remoteMap[key]
+
+		switch {
+		case inLocal && inRemote:
+			if !inBase {
+				if reflect.DeepEqual(toMap(l), toMap(r)) {
+					result = append(result, r)
+				} else {
+					pending = append(pending, Conflict{Type: kind, ID: key, Reason: ConflictBothAdded, Local: summarizeItem(l), Remote: summarizeItem(r)})
+				}
+				return
+			}
+			fields, fieldConflicts := mergeFields(toMap(b), toMap(l), toMap(r))
+			for _, fc := range fieldConflicts {
+				fc.Type = kind
+				fc.ID = key
+				pending = append(pending, fc)
+			}
+			var item T
+			fromMap(fields, &item)
+			result = append(result, item)
+		case inLocal && !inRemote:
+			if !inBase {
+				result = append(result, l) // 客户端新增
+			} else if !reflect.DeepEqual(toMap(b), toMap(l)) {
+				pending = append(pending, Conflict{Type: kind, ID: key, Reason: ConflictDeletedRemotely, Base: summarizeItem(b), Local: summarizeItem(l)})
+			}
+		case !inLocal && inRemote:
+			if !inBase {
+				result = append(result, r) // 服务端新增
+			} else if !reflect.DeepEqual(toMap(b), toMap(r)) {
+				pending = append(pending, Conflict{Type: kind, ID: key, Reason: ConflictDeletedLocally, Base: summarizeItem(b), Remote: summarizeItem(r)})
+			}
+		}
+	}
+
+	seen := make(map[int64]bool)
+	for _, item := range remote {
+		key := id(item)
+		if !seen[key] {
+			seen[key] = true
+			resolve(key)
+		}
+	}
+	for _, item := range local {
+		key := id(item)
+		if !seen[key] {
+			seen[key] = true
+			resolve(key)
+		}
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+	return result, append(conflicts, pending...)
+}
+
+// mergeFields 逐字段三方合并，返回合并结果和冲突字段
+func mergeFields(base, local, remote map[string]interface{}) (map[string]interface{}, []Conflict) {
+	merged := make(map[string]interface{}, len(remote))
+	var conflicts []Conflict
+
+	keys := make(map[string]bool)
+	for k := range local {
+		keys[k] = true
+	}
+	for k := range remote {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		b, l, r := base[k], local[k], remote[k]
+		switch {
+		case reflect.DeepEqual(l, r):
+			merged[k] = l
+		case reflect.DeepEqual(b, l):
+			merged[k] = r
+		case reflect.DeepEqual(b, r):
+			merged[k] = l
+		default:
+			merged[k] = r
+			conflicts = append(conflicts, Conflict{
+				Field:  k,
+				Reason: ConflictBothModified,
+				Base:   summarizeValue(b),
+				Local:  summarizeValue(l),
+				Remote: summarizeValue(r),
+			})
+		}
+	}
+
+	return merged, conflicts
+}
+
+// indexByID 按ID索引条目，调用前已由 firstDuplicate 保证ID唯一
+func indexByID[T any](items []T, id func(T) int64) map[int64]T {
+	m := make(map[int64]T, len(items))
+	for _, item := range items {
+		m[id(item)] = item
+	}
+	return m
+}
+
+// toMap 将结构体转换为按JSON字段名索引的map，便于逐字段比较
+func toMap(v interface{}) map[string]interface{} {
+	m := make(map[string]interface{})
+	data, err := json.Marshal(v)
+	if err != nil {
+		return m
+	}
+	json.Unmarshal(data, &m)
+	return m
+}
+
+// fromMap 将toMap的结果还原为结构体
+func fromMap(m map[string]interface{}, v interface{}) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	json.Unmarshal(data, v)
+}
+
+// summarizeItem 冲突中展示的条目内容，过长的字段（如base64图标）只保留摘要
+func summarizeItem(v interface{}) map[string]interface{} {
+	m := toMap(v)
+	for k, val := range m {
+		m[k] = summarizeValue(val)
+	}
+	return m
+}
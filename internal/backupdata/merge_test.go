@@ -0,0 +1,63 @@
+package backupdata
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"itab-backend/internal/models"
+)
+
+func TestMergeAddsFromBothSides(t *testing.T) {
+	base := entityData()
+	local := entityData()
+	local.Shortcuts = append(local.Shortcuts, models.Shortcut{ID: 6, Name: "本地新增", PartitionID: intPtr(2), Order: 1})
+	remote := entityData()
+	remote.Shortcuts = append(remote.Shortcuts, models.Shortcut{ID: 7, Name: "远端新增", PartitionID: intPtr(2), Order: 1})
+
+	merged, conflicts, err := Merge(base, local, remote)
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("Merge = %v, %v", conflicts, err)
+	}
+	if len(merged.Shortcuts) != 7 || FindShortcut(merged, 6) < 0 || FindShortcut(merged, 7) < 0 {
+		t.Errorf("合并后的书签 = %+v，应包含双方新增的书签", merged.Shortcuts)
+	}
+}
+
+func TestMergeRejectsDuplicateIDs(t *testing.T) {
+	withDuplicate := func() *models.BackupData {
+		bd := entityData()
+		bd.Shortcuts = append(bd.Shortcuts, models.Shortcut{ID: 2, Name: "同ID", PartitionID: intPtr(1), Order: 2})
+		return bd
+	}
+	tests := []struct {
+		name                string
+		base, local, remote *models.BackupData
+		side                string
+	}{
+		{"上传数据", entityData(), withDuplicate(), entityData(), "上传数据中的书签 2"},
+		{"服务端数据", entityData(), entityData(), withDuplicate(), "服务端数据中的书签 2"},
+		{"基础版本", withDuplicate(), entityData(), entityData(), "基础版本中的书签 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, conflicts, err := Merge(tt.base, tt.local, tt.remote)
+			if !errors.Is(err, ErrMergeDuplicateID) {
+				t.Fatalf("Merge 错误 = %v，应为 ErrMergeDuplicateID", err)
+			}
+			if !strings.Contains(err.Error(), tt.side) {
+				t.Errorf("错误信息 = %q，应指出%s", err, tt.side)
+			}
+			if merged != nil || conflicts != nil {
+				t.Errorf("出错时不应返回合并结果: %+v %+v", merged, conflicts)
+			}
+		})
+	}
+
+	// 不同类型的条目ID相同不算重复
+	bd := entityData()
+	bd.Folders[0].ID = 3
+	if _, _, err := Merge(bd, bd, bd); err != nil {
+		t.Errorf("不同类型的相同ID: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package backupdata
+
+import (
+	"fmt"
+
+	"itab-backend/internal/models"
+)
+
+// RecoveredName 修复时用于收容孤立条目的分区/文件夹名称
+const RecoveredName = "Recovered"
+
+// Repair 修复备份数据中的不一致：重新编号重复ID、将孤立的文件夹和书签移入 Recovered 分区/文件夹、
+// 为排序值冲突或有条目移入移出的分组重建排序；排序值不连续（如从 1 开始）但没有冲突的分组保持不变
+// 返回所做修改的说明，数据无需修复时返回空
+func Repair(bd *models.BackupData) []string {
+	var changes []string
+	note := func(format string, args ...interface{}) {
+		changes = append(changes, fmt.Sprintf(format, args...))
+	}
+	// dirty 有条目移入、移出或新建的排序分组，修复后重建排序
+	dirty := make(map[string]bool)
+
+	// 重复ID：保留第一个，后续条目分配新ID
+	renumberDuplicates(bd.Partitions, "分区",
+		func(p *models.Partition) (int64, string) { return int64(p.ID), p.Name },
+		func(p *models.Partition, id int64) { p.ID = int(id) }, note)
+	renumberDuplicates(bd.Folders, "文件夹",
+		func(f *models.Folder) (int64, string) { return int64(f.ID), f.Name },
+		func(f *models.Folder, id int64) { f.ID = int(id) }, note)
+	renumberDuplicates(bd.Shortcuts, "书签",
+		func(s *models.Shortcut) (int64, string) { return int64(s.ID), s.Name },
+		func(s *models.Shortcut, id int64) { s.ID = int(id) }, note)
+	renumberDuplicates(bd.SearchEngines, "搜索引擎",
+		func(e *models.SearchEngine) (int64, string) { return int64(e.ID), e.Name },
+		func(e *models.SearchEngine, id int64) { e.ID = int(id) }, note)
+	renumberDuplicates(bd.Passwords, "密码",
+		func(p *models.Password) (int64, string) { return p.ID, p.Name },
+		func(p *models.Password, id int64) { p.ID = id }, note)
+
+	// 孤立条目
+	maxPartition, maxFolder := 0, 0
+	for _, p := range bd.Partitions {
+		maxPartition = max(maxPartition, p.ID)
+	}
+	for _, f := range bd.Folders {
+		maxFolder = max(maxFolder, f.ID)
+	}
+	recoveredPartition := func() *int {
+		for _, p := range bd.Partitions {
+			if p.Name == RecoveredName {
+				id := p.ID
+				return &id
+			}
+		}
+		maxPartition++
+		bd.Partitions = append(bd.Partitions, models.Partition{ID: maxPartition, Name: RecoveredName, Order: len(bd.Partitions)})
+		dirty[partitionContainer] = true
+		note("新建分区「%s」(ID %d) 收容孤立条目", RecoveredName, maxPartition)
+		id := maxPartition
+		return &id
+	}
+	recoveredFolder := func(partitionID *int) *int {
+		for _, f := range bd.Folders {
+			if f.Name == RecoveredName && intPtrKey(f.PartitionID) == intPtrKey(partitionID) {
+				id := f.ID
+				return &id
+			}
+		}
+		maxFolder++
+		bd.Folders = append(bd.Folders, models.Folder{ID: maxFolder, Name: RecoveredName, PartitionID: partitionID, Order: len(bd.Folders)})
+		dirty[folderContainer(partitionID)] = true
+		note("新建文件夹「%s」(ID %d) 收容孤立书签", RecoveredName, maxFolder)
+		id := maxFolder
+		return &id
+	}
+
+	for i := range bd.Folders {
+		f := &bd.Folders[i]
+		if f.PartitionID != nil && FindPartition(bd, *f.PartitionID) < 0 {
+			old := *f.PartitionID
+			dirty[folderContainer(f.PartitionID)] = true
+			f.PartitionID = recoveredPartition()
+			dirty[folderContainer(f.PartitionID)] = true
+			note("文件夹「%s」引用的分区 %d 不存在，移入分区「%s」", f.Name, old, RecoveredName)
+		}
+	}
+
+	for i := range bd.Shortcuts {
+		s := &bd.Shortcuts[i]
+		folderMissing := s.FolderID != nil && FindFolder(bd, *s.FolderID) < 0
+		partitionMissing := s.PartitionID != nil && FindPartition(bd, *s.PartitionID) < 0
+		if folderMissing || partitionMissing {
+			dirty[shortcutContainer(*s)] = true
+		}
+
+		switch {
+		case folderMissing:
+			partitionID := s.PartitionID
+			if partitionMissing {
+				partitionID = recoveredPartition()
+			}
+			folderID := recoveredFolder(partitionID)
+			note("书签「%s」引用的文件夹 %d 不存在，移入文件夹「%s」", s.Name, *s.FolderID, RecoveredName)
+			s.PartitionID = partitionID
+			s.FolderID = folderID
+		case partitionMissing && s.FolderID != nil:
+			// 以所在文件夹的分区为准
+			note("书签「%s」引用的分区 %d 不存在，改为所在文件夹的分区", s.Name, *s.PartitionID)
+			s.PartitionID = bd.Folders[FindFolder(bd, *s.FolderID)].PartitionID
+		case partitionMissing:
+			partitionID := recoveredPartition()
+			folderID := recoveredFolder(partitionID)
+			note("书签「%s」引用的分区 %d 不存在，移入文件夹「%s」", s.Name, *s.PartitionID, RecoveredName)
+			s.PartitionID = partitionID
+			s.FolderID = folderID
+		}
+		if folderMissing || partitionMissing {
+			dirty[shortcutContainer(*s)] = true
+		}
+	}
+
+	// 重建排序；重新编号ID不改变条目所在的分组和排序值，不需要重建
+	if rebuildOrder(bd, dirty) {
+		note("重建排序")
+	}
+
+	return changes
+}
+
+// renumberDuplicates 为重复ID的条目分配新ID（当前最大ID之后递增）
+func renumberDuplicates[T any](items []T, kind string, get func(*T) (int64, string), set func(*T, int64), note func(string, ...interface{})) {
+	var maxID int64
+	for i := range items {
+		id, _ := get(&items[i])
+		maxID = max(maxID, id)
+	}
+
+	seen := make(map[int64]bool)
+	for i := range items {
+		id, name := get(&items[i])
+		if seen[id] {
+			maxID++
+			note("%s「%s」的重复ID %d 重新编号为 %d", kind, name, id, maxID)
+			set(&items[i], maxID)
+			id = maxID
+		}
+		seen[id] = true
+	}
+}
+
+// 排序分组的键：全部分区、各分区内的文件夹、各分组内的书签、置顶书签分别排序
+const (
+	partitionContainer = "partitions"
+	pinnedContainer    = "pinned"
+)
+
+func folderContainer(partitionID *int) string {
+	return "folders:" + intPtrKey(partitionID)
+}
+
+func shortcutContainer(s models.Shortcut) string {
+	return "shortcuts:" + shortcutGroup(s)
+}
+
+// rebuildOrder 将排序值冲突的分组以及 dirty 中的分组重新编号为连续值，返回是否有变化
+func rebuildOrder(bd *models.BackupData, dirty map[string]bool) bool {
+	type orders struct{ partitions, folders, shortcuts, pinned []int }
+	snapshot := func() orders {
+		var o orders
+		for _, p := range bd.Partitions {
+			o.partitions = append(o.partitions, p.Order)
+		}
+		for _, f := range bd.Folders {
+			o.folders = append(o.folders, f.Order)
+		}
+		for _, s := range bd.Shortcuts {
+			o.shortcuts = append(o.shortcuts, s.Order)
+			o.pinned = append(o.pinned, s.PinnedOrder)
+		}
+		return o
+	}
+	before := snapshot()
+
+	rebuild := make(map[string]bool, len(dirty))
+	for k := range dirty {
+		rebuild[k] = true
+	}
+	// 同一分组内排序值重复时无法确定先后，需要重建
+	seen := make(map[string]map[int]bool)
+	collide := func(container string, order int) {
+		if seen[container] == nil {
+			seen[container] = make(map[int]bool)
+		}
+		if seen[container][order] {
+			rebuild[container] = true
+		}
+		seen[container][order] = true
+	}
+	for _, p := range bd.Partitions {
+		collide(partitionContainer, p.Order)
+	}
+	for _, f := range bd.Folders {
+		collide(folderContainer(f.PartitionID), f.Order)
+	}
+	for _, s := range bd.Shortcuts {
+		collide(shortcutContainer(s), s.Order)
+		if s.IsPinned {
+			collide(pinnedContainer, s.PinnedOrder)
+		}
+	}
+
+	if rebuild[partitionContainer] {
+		reorderPartitions(bd, -1, nil)
+	}
+	for i := range bd.Folders {
+		if container := folderContainer(bd.Folders[i].PartitionID); rebuild[container] {
+			reorderFolders(bd, intPtrKey(bd.Folders[i].PartitionID), -1, nil)
+			delete(rebuild, container)
+		}
+	}
+	for i := range bd.Shortcuts {
+		if container := shortcutContainer(bd.Shortcuts[i]); rebuild[container] {
+			reorderShortcuts(bd, shortcutGroup(bd.Shortcuts[i]), -1, nil)
+			delete(rebuild, container)
+		}
+	}
+	if rebuild[pinnedContainer] {
+		reorderPinned(bd, -1, nil)
+	}
+
+	after := snapshot()
+	return fmt.Sprint(before) != fmt.Sprint(after)
+}
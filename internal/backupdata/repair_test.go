@@ -0,0 +1,181 @@
+package backupdata
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"itab-backend/internal/models"
+)
+
+func intPtr(v int) *int { return &v }
+
+// healthyData 排序从 1 开始、没有冲突的一致数据
+func healthyData() *models.BackupData {
+	return &models.BackupData{
+		Partitions: []models.Partition{{ID: 1, Name: "主页", Order: 1}, {ID: 2, Name: "工作", Order: 2}},
+		Folders:    []models.Folder{{ID: 1, Name: "开发", PartitionID: intPtr(1), Order: 1}},
+		Shortcuts: []models.Shortcut{
+			{ID: 1, Name: "GitHub", URL: "https://github.com", PartitionID: intPtr(1), Order: 1, IsPinned: true, PinnedOrder: 1},
+			{ID: 2, Name: "Go", URL: "https://go.dev", PartitionID: intPtr(1), Order: 2},
+			{ID: 3, Name: "Docs", URL: "https://pkg.go.dev", PartitionID: intPtr(1), FolderID: intPtr(1), Order: 5},
+		},
+	}
+}
+
+func shortcutOrders(bd *models.BackupData) map[int]int {
+	orders := make(map[int]int)
+	for _, s := range bd.Shortcuts {
+		orders[s.ID] = s.Order
+	}
+	return orders
+}
+
+func TestRepairKeepsConsistentOrders(t *testing.T) {
+	bd := healthyData()
+	if issues := Check(bd); len(issues) != 0 {
+		t.Fatalf("Check = %v，应无问题", issues)
+	}
+	if changes := Repair(bd); len(changes) != 0 {
+		t.Errorf("Repair = %v，一致的数据不应修改", changes)
+	}
+	if !reflect.DeepEqual(bd, healthyData()) {
+		t.Errorf("数据被修改: %+v", bd)
+	}
+}
+
+func TestRepairRebuildsCollidingGroupOnly(t *testing.T) {
+	bd := healthyData()
+	// 分区 1 的书签排序冲突，文件夹内的书签不受影响
+	bd.Shortcuts = append(bd.Shortcuts, models.Shortcut{ID: 4, Name: "Blog", URL: "https://go.dev/blog", PartitionID: intPtr(1), Order: 2})
+
+	changes := Repair(bd)
+	if !reflect.DeepEqual(changes, []string{"重建排序"}) {
+		t.Fatalf("Repair = %v，应只重建排序", changes)
+	}
+	want := map[int]int{1: 0, 2: 1, 4: 2, 3: 5}
+	if got := shortcutOrders(bd); !reflect.DeepEqual(got, want) {
+		t.Errorf("书签排序 = %v，应为 %v", got, want)
+	}
+	if bd.Partitions[0].Order != 1 || bd.Folders[0].Order != 1 || bd.Shortcuts[0].PinnedOrder != 1 {
+		t.Errorf("没有冲突的分组不应重建排序: %+v %+v", bd.Partitions, bd.Folders)
+	}
+}
+
+func TestRepairMovesOrphansAndReordersAffectedGroups(t *testing.T) {
+	bd := healthyData()
+	bd.Shortcuts = append(bd.Shortcuts,
+		models.Shortcut{ID: 4, Name: "孤立", URL: "https://example.com", PartitionID: intPtr(1), FolderID: intPtr(9), Order: 7},
+		models.Shortcut{ID: 5, Name: "孤立2", URL: "https://example.org", PartitionID: intPtr(1), FolderID: intPtr(9), Order: 3},
+	)
+
+	changes := Repair(bd)
+	if len(Check(bd)) != 0 {
+		t.Fatalf("修复后仍有问题: %v", Check(bd))
+	}
+	joined := strings.Join(changes, "\n")
+	if !strings.Contains(joined, "移入文件夹「Recovered」") || !strings.Contains(joined, "重建排序") {
+		t.Errorf("Repair = %v，应移入 Recovered 并重建排序", changes)
+	}
+
+	recovered := bd.Folders[FindFolder(bd, *bd.Shortcuts[3].FolderID)]
+	if recovered.Name != RecoveredName || intPtrKey(recovered.PartitionID) != "1" {
+		t.Errorf("孤立书签所在文件夹 = %+v", recovered)
+	}
+	// 移入的分组重新编号，保持原有先后
+	if bd.Shortcuts[4].Order != 0 || bd.Shortcuts[3].Order != 1 {
+		t.Errorf("Recovered 中的排序 = %d, %d，应为 1, 0", bd.Shortcuts[3].Order, bd.Shortcuts[4].Order)
+	}
+	// 新建的文件夹与原文件夹排序不冲突
+	if bd.Folders[0].Order == recovered.Order {
+		t.Errorf("文件夹排序冲突: %+v", bd.Folders)
+	}
+	// 没有条目移入移出的分组保持不变
+	if bd.Shortcuts[0].Order != 1 || bd.Shortcuts[1].Order != 2 || bd.Partitions[0].Order != 1 {
+		t.Errorf("无关分组的排序被修改: %v", shortcutOrders(bd))
+	}
+}
+
+func TestRepairRenumbersDuplicateIDsWithoutReordering(t *testing.T) {
+	bd := healthyData()
+	bd.Shortcuts[1].ID = 1
+
+	changes := Repair(bd)
+	if len(changes) != 1 || !strings.Contains(changes[0], "重复ID 1 重新编号为 4") {
+		t.Errorf("Repair = %v，应只重新编号重复ID", changes)
+	}
+	if bd.Shortcuts[1].ID != 4 || bd.Shortcuts[1].Order != 2 {
+		t.Errorf("重新编号后的书签 = %+v", bd.Shortcuts[1])
+	}
+}
+
+func TestRebuildOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(bd *models.BackupData)
+		dirty   []string
+		changed bool
+		check   func(t *testing.T, bd *models.BackupData)
+	}{
+		{
+			name:    "无冲突且无变动",
+			prepare: func(bd *models.BackupData) {},
+			changed: false,
+		},
+		{
+			name:    "指定的分组重新编号",
+			prepare: func(bd *models.BackupData) {},
+			dirty:   []string{partitionContainer},
+			changed: true,
+			check: func(t *testing.T, bd *models.BackupData) {
+				if bd.Partitions[0].Order != 0 || bd.Partitions[1].Order != 1 {
+					t.Errorf("分区排序 = %+v", bd.Partitions)
+				}
+			},
+		},
+		{
+			name:    "已连续的分组重新编号无变化",
+			prepare: func(bd *models.BackupData) { bd.Partitions[0].Order, bd.Partitions[1].Order = 0, 1 },
+			dirty:   []string{partitionContainer},
+			changed: false,
+		},
+		{
+			name: "置顶排序冲突",
+			prepare: func(bd *models.BackupData) {
+				bd.Shortcuts[1].IsPinned, bd.Shortcuts[1].PinnedOrder = true, 1
+			},
+			changed: true,
+			check: func(t *testing.T, bd *models.BackupData) {
+				if bd.Shortcuts[0].PinnedOrder != 0 || bd.Shortcuts[1].PinnedOrder != 1 {
+					t.Errorf("置顶排序 = %d, %d", bd.Shortcuts[0].PinnedOrder, bd.Shortcuts[1].PinnedOrder)
+				}
+				if bd.Shortcuts[0].Order != 1 {
+					t.Errorf("分组排序不应变化: %v", shortcutOrders(bd))
+				}
+			},
+		},
+		{
+			name: "不同分区的文件夹排序相同不算冲突",
+			prepare: func(bd *models.BackupData) {
+				bd.Folders = append(bd.Folders, models.Folder{ID: 2, Name: "文档", PartitionID: intPtr(2), Order: 1})
+			},
+			changed: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bd := healthyData()
+			tt.prepare(bd)
+			dirty := make(map[string]bool)
+			for _, k := range tt.dirty {
+				dirty[k] = true
+			}
+			if got := rebuildOrder(bd, dirty); got != tt.changed {
+				t.Errorf("rebuildOrder = %v，应为 %v", got, tt.changed)
+			}
+			if tt.check != nil {
+				tt.check(t, bd)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package backupdata
+
+import (
+	"errors"
+	"sort"
+
+	"itab-backend/internal/models"
+)
+
+// ErrPrivatePartition 私密分区不能公开分享
+var ErrPrivatePartition = errors.New("私密分区不能分享")
+
+// SharedShortcut 公开分享中的书签，只包含展示所需的字段
+type SharedShortcut struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Icon    string `json:"icon,omitempty"`
+	IconURL string `json:"iconUrl,omitempty"`
+}
+
+// SharedFolder 公开分享中的文件夹
+type SharedFolder struct {
+	Name      string           `json:"name"`
+	Shortcuts []SharedShortcut `json:"shortcuts"`
+}
+
+// SharedPartition 公开分享的分区内容，私密的文件夹和书签已被排除
+type SharedPartition struct {
+	Name      string           `json:"name"`
+	Folders   []SharedFolder   `json:"folders"`
+	Shortcuts []SharedShortcut `json:"shortcuts"` // 不在文件夹中的书签
+}
+
+// SharePartition 提取分区中可公开的文件夹和书签，分区不存在时返回 ErrEntityNotFound，私密分区返回 ErrPrivatePartition
+func SharePartition(bd *models.BackupData, partitionID int) (*SharedPartition, error) {
+	i := FindPartition(bd, partitionID)
+	if i < 0 {
+		return nil, ErrEntityNotFound
+	}
+	partition := bd.Partitions[i]
+	if partition.IsPrivate {
+		return nil, ErrPrivatePartition
+	}
+
+	folders := make([]models.Folder, 0)
+	for _, f := range bd.Folders {
+		if f.PartitionID != nil && *f.PartitionID == partitionID && !f.IsPrivate {
+			folders = append(folders, f)
+		}
+	}
+	sort.SliceStable(folders, func(a, b int) bool { return folders[a].Order < folders[b].Order })
+
+	shortcuts := make([]models.Shortcut, 0)
+	for _, s := range bd.Shortcuts {
+		if !s.IsPrivate {
+			shortcuts = append(shortcuts, s)
+		}
+	}
+	sort.SliceStable(shortcuts, func(a, b int) bool { return shortcuts[a].Order < shortcuts[b].Order })
+
+	shared := &SharedPartition{
+		Name:      partition.Name,
+		Folders:   make([]SharedFolder, 0, len(folders)),
+		Shortcuts: make([]SharedShortcut, 0),
+	}
+	folderIndex := make(map[int]int, len(folders))
+	for _, f := range folders {
+		folderIndex[f.ID] = len(shared.Folders)
+		shared.Folders = append(shared.Folders, SharedFolder{Name: f.Name, Shortcuts: make([]SharedShortcut, 0)})
+	}
+	for _, s := range shortcuts {
+		item := SharedShortcut{Name: s.Name, URL: s.URL, Icon: s.Icon, IconURL: s.IconUrl}
+		if s.FolderID != nil {
+			// 私密文件夹或其他分区文件夹中的书签不会出现在 folderIndex 中
+			if k, ok := folderIndex[*s.FolderID]; ok {
+				shared.Folders[k].Shortcuts = append(shared.Folders[k].Shortcuts, item)
+			}
+			continue
+		}
+		if s.PartitionID != nil && *s.PartitionID == partitionID {
+			shared.Shortcuts = append(shared.Shortcuts, item)
+		}
+	}
+	return shared, nil
+}
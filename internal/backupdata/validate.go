@@ -0,0 +1,254 @@
+package backupdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"itab-backend/internal/models"
+)
+
+// 校验问题代码
+const (
+	IssueInvalidJSON        = "invalid_json"
+	IssueInvalidType        = "invalid_type"
+	IssueRequired           = "required"
+	IssueDuplicateID        = "duplicate_id"
+	IssueDanglingReference  = "dangling_reference"
+	IssueInvalidURL         = "invalid_url"
+	IssueOutOfRange         = "out_of_range"
+	IssueInvalidEnumeration = "invalid_value"
+)
+
+// Issue 校验发现的问题
+type Issue struct {
+	Path    string `json:"path"` // 问题位置（JSON Pointer）
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	key string // 比较修改前后的问题时使用：条目问题为集合、条目ID和字段，不随下标或名称变化
+}
+
+// intRange 整数设置项的取值范围，AllowZero 表示 0 代表未设置（使用默认值）
+type intRange struct {
+	Min, Max  int
+	AllowZero bool
+}
+
+// settingRanges 外观设置中整数字段的合法范围
+var settingRanges = map[string]intRange{
+	"gradientAngle":    {Min: 0, Max: 360},
+	"iconSize":         {Min: 16, Max: 256, AllowZero: true},
+	"folderSize":       {Min: 16, Max: 512, AllowZero: true},
+	"iconGap":          {Min: 0, Max: 200},
+	"folderGap":        {Min: 0, Max: 200},
+	"iconRadius":       {Min: 0, Max: 256},
+	"searchRadius":     {Min: 0, Max: 256},
+	"btnRadius":        {Min: 0, Max: 256},
+	"barColumns":       {Min: 0, Max: 50},
+	"barGap":           {Min: 0, Max: 200},
+	"barHeight":        {Min: 0, Max: 1000},
+	"barWidth":         {Min: 0, Max: 4000},
+	"folderBarColumns": {Min: 0, Max: 50},
+	"folderBarHeight":  {Min: 0, Max: 1000},
+}
+
+// bgTypes 合法的背景类型
+var bgTypes = map[string]bool{"": true, "gradient": true, "solid": true, "image": true}
+
+// Validate 校验上传的原始JSON数据，包括结构、类型和引用完整性
+// 结构或类型错误时无法继续校验，返回的备份数据为 nil
+func Validate(data []byte) (*models.BackupData, []Issue) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, []Issue{{Path: "", Code: IssueInvalidJSON, Message: "备份数据必须是JSON对象"}}
+	}
+
+	var bd models.BackupData
+	if err := json.Unmarshal(data, &bd); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, []Issue{{
+				Path:    "/" + strings.ReplaceAll(typeErr.Field, ".", "/"),
+				Code:    IssueInvalidType,
+				Message: fmt.Sprintf("类型错误：期望 %s，实际为 %s", typeErr.Type, typeErr.Value),
+			}}
+		}
+		return nil, []Issue{{Path: "", Code: IssueInvalidJSON, Message: err.Error()}}
+	}
+
+	var issues []Issue
+	if settings, ok := raw["settings"]; !ok || string(settings) == "null" {
+		issues = append(issues, Issue{Path: "/settings", Code: IssueRequired, Message: "缺少外观设置 settings"})
+	}
+
+	return &bd, append(issues, Check(&bd)...)
+}
+
+// Check 校验备份数据的引用完整性与取值范围
+func Check(bd *models.BackupData) []Issue {
+	var issues []Issue
+	add := func(path, code, format string, args ...interface{}) {
+		issues = append(issues, Issue{Path: path, Code: code, Message: fmt.Sprintf(format, args...), key: path})
+	}
+	// addItem 记录集合中第 i 个条目（ID 为 id）字段 field 的问题
+	addItem := func(collection string, i int, id interface{}, field, code, format string, args ...interface{}) {
+		issues = append(issues, Issue{
+			Path:    fmt.Sprintf("/%s/%d/%s", collection, i, field),
+			Code:    code,
+			Message: fmt.Sprintf(format, args...),
+			key:     fmt.Sprintf("/%s#%v/%s", collection, id, field),
+		})
+	}
+
+	// 重复ID
+	partitionIDs := make(map[int]bool)
+	for i, p := range bd.Partitions {
+		if partitionIDs[p.ID] {
+			addItem("partitions", i, p.ID, "id", IssueDuplicateID, "分区ID %d 重复", p.ID)
+		}
+		partitionIDs[p.ID] = true
+	}
+	folderIDs := make(map[int]bool)
+	for i, f := range bd.Folders {
+		if folderIDs[f.ID] {
+			addItem("folders", i, f.ID, "id", IssueDuplicateID, "文件夹ID %d 重复", f.ID)
+		}
+		folderIDs[f.ID] = true
+	}
+	shortcutIDs := make(map[int]bool)
+	for i, s := range bd.Shortcuts {
+		if shortcutIDs[s.ID] {
+			addItem("shortcuts", i, s.ID, "id", IssueDuplicateID, "书签ID %d 重复", s.ID)
+		}
+		shortcutIDs[s.ID] = true
+	}
+	engineIDs := make(map[int]bool)
+	for i, e := range bd.SearchEngines {
+		if engineIDs[e.ID] {
+			addItem("searchEngines", i, e.ID, "id", IssueDuplicateID, "搜索引擎ID %d 重复", e.ID)
+		}
+		engineIDs[e.ID] = true
+	}
+	passwordIDs := make(map[int64]bool)
+	for i, p := range bd.Passwords {
+		if passwordIDs[p.ID] {
+			addItem("passwords", i, p.ID, "id", IssueDuplicateID, "密码ID %d 重复", p.ID)
+		}
+		passwordIDs[p.ID] = true
+	}
+
+	// 引用完整性
+	for i, f := range bd.Folders {
+		if f.PartitionID != nil && !partitionIDs[*f.PartitionID] {
+			addItem("folders", i, f.ID, "partitionId", IssueDanglingReference, "文件夹「%s」引用的分区 %d 不存在", f.Name, *f.PartitionID)
+		}
+	}
+	for i, s := range bd.Shortcuts {
+		if s.FolderID != nil && !folderIDs[*s.FolderID] {
+			addItem("shortcuts", i, s.ID, "folderId", IssueDanglingReference, "书签「%s」引用的文件夹 %d 不存在", s.Name, *s.FolderID)
+		}
+		if s.PartitionID != nil && !partitionIDs[*s.PartitionID] {
+			addItem("shortcuts", i, s.ID, "partitionId", IssueDanglingReference, "书签「%s」引用的分区 %d 不存在", s.Name, *s.PartitionID)
+		}
+	}
+
+	// URL格式
+	for i, s := range bd.Shortcuts {
+		if msg := checkURL(s.URL); msg != "" {
+			addItem("shortcuts", i, s.ID, "url", IssueInvalidURL, "书签「%s」的URL%s", s.Name, msg)
+		}
+	}
+	for i, e := range bd.SearchEngines {
+		if msg := checkURL(e.URL); msg != "" {
+			addItem("searchEngines", i, e.ID, "url", IssueInvalidURL, "搜索引擎「%s」的URL%s", e.Name, msg)
+		}
+	}
+
+	// 外观设置
+	if !bgTypes[bd.Settings.BgType] {
+		add("/settings/bgType", IssueInvalidEnumeration, "不支持的背景类型 %q", bd.Settings.BgType)
+	}
+	values := toMap(bd.Settings)
+	for field, r := range settingRanges {
+		v, ok := values[field].(float64)
+		if !ok {
+			continue
+		}
+		n := int(v)
+		if (n == 0 && r.AllowZero) || (n >= r.Min && n <= r.Max) {
+			continue
+		}
+		add("/settings/"+field, IssueOutOfRange, "%s 取值 %d 超出范围 [%d, %d]", field, n, r.Min, r.Max)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return pathLess(issues[i].Path, issues[j].Path) })
+	return issues
+}
+
+// pathLess 逐段比较 JSON Pointer，数字下标按数值比较，使 /shortcuts/2 排在 /shortcuts/10 之前
+func pathLess(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for k := 0; k < len(as) && k < len(bs); k++ {
+		if as[k] == bs[k] {
+			continue
+		}
+		an, aErr := strconv.Atoi(as[k])
+		bn, bErr := strconv.Atoi(bs[k])
+		if aErr == nil && bErr == nil {
+			return an < bn
+		}
+		return as[k] < bs[k]
+	}
+	return len(as) < len(bs)
+}
+
+// NewIssues 返回 after 中 before 没有的问题，用于只拒绝修改新引入的问题
+// 删除或插入条目会改变后续条目的下标，因此条目问题按集合、条目ID、字段和问题代码比较而不是按位置；
+// 相同的问题出现次数增加时（如再添加一个重复ID的条目）多出的部分视为新问题
+func NewIssues(before, after []Issue) []Issue {
+	existing := make(map[string]int, len(before))
+	for _, issue := range before {
+		existing[issue.issueKey()]++
+	}
+	var added []Issue
+	for _, issue := range after {
+		key := issue.issueKey()
+		if existing[key] > 0 {
+			existing[key]--
+			continue
+		}
+		added = append(added, issue)
+	}
+	return added
+}
+
+func (i Issue) issueKey() string {
+	key := i.key
+	if key == "" {
+		key = i.Path
+	}
+	return key + "\x00" + i.Code
+}
+
+// checkURL 检查URL是否为带协议的绝对地址，合法时返回空字符串
+func checkURL(raw string) string {
+	if strings.TrimSpace(raw) == "" {
+		return "不能为空"
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "格式错误"
+	}
+	if u.Scheme == "" {
+		return "缺少协议（如 https://）"
+	}
+	if u.Host == "" && u.Opaque == "" && u.Path == "" {
+		return "缺少地址"
+	}
+	return ""
+}
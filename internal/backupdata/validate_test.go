@@ -0,0 +1,55 @@
+package backupdata
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"itab-backend/internal/models"
+)
+
+func TestCheckSortsIssuesByCollectionAndIndex(t *testing.T) {
+	bd := &models.BackupData{Folders: []models.Folder{{ID: 1, Name: "孤立", PartitionID: intPtr(9)}}}
+	for i := 0; i < 12; i++ {
+		url := "https://example.com"
+		if i == 2 || i == 10 {
+			url = "bad"
+		}
+		bd.Shortcuts = append(bd.Shortcuts, models.Shortcut{ID: i + 1, Name: fmt.Sprint(i), URL: url})
+	}
+	bd.Shortcuts[10].FolderID = intPtr(9)
+
+	var paths []string
+	for _, issue := range Check(bd) {
+		paths = append(paths, issue.Path)
+	}
+	want := []string{
+		"/folders/0/partitionId",
+		"/shortcuts/2/url",
+		"/shortcuts/10/folderId",
+		"/shortcuts/10/url",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("问题顺序 = %v，应为 %v", paths, want)
+	}
+}
+
+func TestPathLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"/shortcuts/2/url", "/shortcuts/10/url", true},
+		{"/shortcuts/10/url", "/shortcuts/2/url", false},
+		{"/folders/10/partitionId", "/shortcuts/0/url", true},
+		{"/shortcuts/1/folderId", "/shortcuts/1/url", true},
+		{"/settings", "/settings/iconSize", true},
+		{"", "/settings", true},
+		{"/shortcuts/1/url", "/shortcuts/1/url", false},
+	}
+	for _, tt := range tests {
+		if got := pathLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("pathLess(%q, %q) = %v，应为 %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
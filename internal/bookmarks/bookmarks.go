@@ -0,0 +1,380 @@
+// Package bookmarks 提供浏览器书签文件与备份数据（models.BackupData）之间的转换
+package bookmarks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"itab-backend/internal/models"
+)
+
+// 支持的书签格式
+const (
+	FormatHTML     = "html"     // Netscape 书签文件（bookmarks.html），各浏览器均可导入导出
+	FormatChrome   = "chrome"   // Chrome/Edge 用户目录中的 Bookmarks 文件（JSON）
+	FormatFirefox  = "firefox"  // Firefox 用户目录中的 places.sqlite
+	FormatMarkdown = "markdown" // Markdown 嵌套列表，仅导出
+	FormatCSV      = "csv"      // 每个书签一行的 CSV，仅导出
+	FormatOPML     = "opml"     // OPML 大纲，仅导出
+)
+
+// sqliteMagic SQLite 数据库文件头
+const sqliteMagic = "SQLite format 3\x00"
+
+// DefaultPartitionName 不在任何文件夹中的书签导入到的分区
+const DefaultPartitionName = "导入的书签"
+
+// folderPathSeparator 多层文件夹展开为一层时名称之间的分隔符
+const folderPathSeparator = " / "
+
+// maxFolderDepth 书签树的最大层数：解析时更深的内容被忽略，导入时更深的文件夹不再展开，其中的书签放入上一层文件夹
+const maxFolderDepth = 64
+
+// 解析错误
+var (
+	ErrUnsupportedFormat = errors.New("不支持的书签格式")
+	ErrInvalidFile       = errors.New("无法识别的书签文件")
+)
+
+// Node 书签树节点，Folder 为 true 时是文件夹
+type Node struct {
+	Title    string
+	URL      string
+	Icon     string // 图标数据（通常为 data:image 地址）
+	IconURL  string // 图标地址
+	AddDate  int64  // 添加时间（Unix 秒），0 表示未知
+	Folder   bool
+	Children []*Node
+}
+
+// Stats 导入统计
+type Stats struct {
+	Partitions int `json:"partitions"` // 新建的分区数
+	Folders    int `json:"folders"`    // 新建的文件夹数
+	Shortcuts  int `json:"shortcuts"`  // 导入的书签数
+	Duplicates int `json:"duplicates"` // 目标位置已有相同URL而跳过的书签数
+	Skipped    int `json:"skipped"`    // URL 无法导入（如 javascript: 书签小程序）而跳过的书签数
+}
+
+// Duplicate 因目标位置已有相同URL而跳过的书签
+type Duplicate struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Location string `json:"location"` // 目标位置：分区名称，或「分区 / 文件夹」
+}
+
+// Preview 导入预览
+type Preview struct {
+	Stats
+	DuplicateItems []Duplicate `json:"duplicate_items"`
+}
+
+// Detect 根据文件内容判断书签文件格式
+func Detect(data []byte) string {
+	if bytes.HasPrefix(data, []byte(sqliteMagic)) {
+		return FormatFirefox
+	}
+	if trimmed := bytes.TrimLeft(data, " \t\r\n\xef\xbb\xbf"); len(trimmed) > 0 && trimmed[0] == '{' {
+		return FormatChrome
+	}
+	return FormatHTML
+}
+
+// Parse 按格式解析书签文件，返回顶层节点，format 为空时根据文件内容自动判断
+func Parse(format string, data []byte) ([]*Node, error) {
+	if format == "" {
+		format = Detect(data)
+	}
+	switch format {
+	case FormatHTML:
+		return ParseHTML(data)
+	case FormatChrome:
+		return ParseChrome(data)
+	case FormatFirefox:
+		return ParseFirefox(data)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+}
+
+// Import 将书签树合并到备份数据中
+// 顶层文件夹成为分区，第二层文件夹成为分区中的文件夹，更深的文件夹以「父 / 子」的名称展开到同一分区，
+// 顶层的零散书签放入「导入的书签」分区；已存在的同名分区和文件夹会被复用，同一位置已有相同URL的书签会被跳过
+func Import(bd *models.BackupData, roots []*Node) Stats {
+	return newImporter(bd).run(roots)
+}
+
+// PreviewImport 预览将书签树合并到备份数据的结果，bd 不会被修改
+func PreviewImport(bd *models.BackupData, roots []*Node) Preview {
+	clone := *bd
+	clone.Partitions = append([]models.Partition(nil), bd.Partitions...)
+	clone.Folders = append([]models.Folder(nil), bd.Folders...)
+	clone.Shortcuts = append([]models.Shortcut(nil), bd.Shortcuts...)
+
+	im := newImporter(&clone)
+	im.duplicates = []Duplicate{}
+	stats := im.run(roots)
+	return Preview{Stats: stats, DuplicateItems: im.duplicates}
+}
+
+func (im *importer) run(roots []*Node) Stats {
+	var loose []*Node
+	for _, n := range roots {
+		if n.Folder {
+			im.importPartition(n.Title, n.Children)
+		} else {
+			loose = append(loose, n)
+		}
+	}
+	if len(loose) > 0 {
+		im.importPartition(DefaultPartitionName, loose)
+	}
+	return im.stats
+}
+
+// partitionTarget 书签要导入的分区，第一次放入书签时才查找或创建，避免导入空分区
+type partitionTarget struct {
+	name string
+	id   *int
+}
+
+// folderTarget 书签要导入的文件夹，第一次放入书签时才查找或创建
+type folderTarget struct {
+	partition *partitionTarget
+	name      string
+	id        *int
+}
+
+// importer 向备份数据中追加条目，ID 和排序一次性计算，避免逐条追加时反复扫描
+type importer struct {
+	bd    *models.BackupData
+	stats Stats
+
+	nextPartitionID int
+	nextFolderID    int
+	nextShortcutID  int
+	partitionOrder  int
+	folderOrder     map[int]int                // 分区ID -> 下一个文件夹序号
+	shortcutOrder   map[string]int             // 书签分组 -> 下一个书签序号
+	urls            map[string]map[string]bool // 书签分组 -> 已有的URL
+	duplicates      []Duplicate                // 跳过的重复书签，仅预览时记录（非 nil 时）
+}
+
+func newImporter(bd *models.BackupData) *importer {
+	im := &importer{
+		bd:            bd,
+		folderOrder:   make(map[int]int),
+		shortcutOrder: make(map[string]int),
+		urls:          make(map[string]map[string]bool),
+	}
+	for _, p := range bd.Partitions {
+		im.nextPartitionID = max(im.nextPartitionID, p.ID)
+		im.partitionOrder = max(im.partitionOrder, p.Order+1)
+	}
+	for _, f := range bd.Folders {
+		im.nextFolderID = max(im.nextFolderID, f.ID)
+		if f.PartitionID != nil {
+			im.folderOrder[*f.PartitionID] = max(im.folderOrder[*f.PartitionID], f.Order+1)
+		}
+	}
+	for _, s := range bd.Shortcuts {
+		im.nextShortcutID = max(im.nextShortcutID, s.ID)
+		group := shortcutGroup(s.PartitionID, s.FolderID)
+		im.shortcutOrder[group] = max(im.shortcutOrder[group], s.Order+1)
+		im.addURL(group, s.URL)
+	}
+	return im
+}
+
+// shortcutGroup 书签的排序分组：在文件夹内时按文件夹分组，否则按分区分组
+func shortcutGroup(partitionID, folderID *int) string {
+	if folderID != nil {
+		return fmt.Sprintf("folder:%d", *folderID)
+	}
+	if partitionID != nil {
+		return fmt.Sprintf("partition:%d", *partitionID)
+	}
+	return "partition:-"
+}
+
+func (im *importer) addURL(group, u string) {
+	if im.urls[group] == nil {
+		im.urls[group] = make(map[string]bool)
+	}
+	im.urls[group][u] = true
+}
+
+func (im *importer) importPartition(name string, nodes []*Node) {
+	p := &partitionTarget{name: folderName(name, "未命名分区")}
+	for _, n := range nodes {
+		if n.Folder {
+			im.importFolder(p, folderName(n.Title, "未命名文件夹"), n, 1)
+		} else {
+			im.addShortcut(p, nil, n)
+		}
+	}
+}
+
+func (im *importer) importFolder(p *partitionTarget, name string, node *Node, depth int) {
+	f := &folderTarget{partition: p, name: name}
+	for _, n := range node.Children {
+		switch {
+		case !n.Folder:
+			im.addShortcut(p, f, n)
+		case depth < maxFolderDepth:
+			im.importFolder(p, name+folderPathSeparator+folderName(n.Title, "未命名文件夹"), n, depth+1)
+		default:
+			im.flattenFolder(p, f, n)
+		}
+	}
+}
+
+// flattenFolder 将超过最大层数的文件夹中的全部书签放入 f，按节点去重，节点树中存在环时也能结束
+func (im *importer) flattenFolder(p *partitionTarget, f *folderTarget, node *Node) {
+	visited := map[*Node]bool{node: true}
+	queue := []*Node{node}
+	for len(queue) > 0 {
+		folder := queue[0]
+		queue = queue[1:]
+		for _, n := range folder.Children {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			if n.Folder {
+				queue = append(queue, n)
+			} else {
+				im.addShortcut(p, f, n)
+			}
+		}
+	}
+}
+
+// partition 查找同名分区，不存在时创建
+func (im *importer) partition(t *partitionTarget) int {
+	if t.id != nil {
+		return *t.id
+	}
+	for _, p := range im.bd.Partitions {
+		if p.Name == t.name {
+			t.id = &p.ID
+			return p.ID
+		}
+	}
+
+	im.nextPartitionID++
+	id := im.nextPartitionID
+	im.bd.Partitions = append(im.bd.Partitions, models.Partition{ID: id, Name: t.name, Order: im.partitionOrder})
+	im.partitionOrder++
+	im.stats.Partitions++
+	t.id = &id
+	return id
+}
+
+// folder 查找分区中的同名文件夹，不存在时创建
+func (im *importer) folder(t *folderTarget) int {
+	if t.id != nil {
+		return *t.id
+	}
+	partitionID := im.partition(t.partition)
+	for _, f := range im.bd.Folders {
+		if f.PartitionID != nil && *f.PartitionID == partitionID && f.Name == t.name {
+			t.id = &f.ID
+			return f.ID
+		}
+	}
+
+	im.nextFolderID++
+	id := im.nextFolderID
+	im.bd.Folders = append(im.bd.Folders, models.Folder{
+		ID:          id,
+		Name:        t.name,
+		PartitionID: &partitionID,
+		Order:       im.folderOrder[partitionID],
+	})
+	im.folderOrder[partitionID]++
+	im.stats.Folders++
+	t.id = &id
+	return id
+}
+
+func (im *importer) addShortcut(p *partitionTarget, f *folderTarget, n *Node) {
+	u := strings.TrimSpace(n.URL)
+	if !importableURL(u) {
+		im.stats.Skipped++
+		return
+	}
+
+	partitionID := im.partition(p)
+	var folderID *int
+	if f != nil {
+		id := im.folder(f)
+		folderID = &id
+	}
+	group := shortcutGroup(&partitionID, folderID)
+	if im.urls[group][u] {
+		im.stats.Duplicates++
+		if im.duplicates != nil {
+			location := p.name
+			if f != nil {
+				location += folderPathSeparator + f.name
+			}
+			im.duplicates = append(im.duplicates, Duplicate{Name: n.Title, URL: u, Location: location})
+		}
+		return
+	}
+
+	name := strings.TrimSpace(n.Title)
+	if name == "" {
+		name = u
+	}
+	im.nextShortcutID++
+	im.bd.Shortcuts = append(im.bd.Shortcuts, models.Shortcut{
+		ID:          im.nextShortcutID,
+		Name:        name,
+		URL:         u,
+		Icon:        n.Icon,
+		IconUrl:     n.IconURL,
+		FolderID:    folderID,
+		PartitionID: &partitionID,
+		Order:       im.shortcutOrder[group],
+	})
+	im.shortcutOrder[group]++
+	im.addURL(group, u)
+	im.stats.Shortcuts++
+}
+
+// folderName 去除名称首尾空白，为空时使用默认名称
+func folderName(name, fallback string) string {
+	if name = strings.TrimSpace(name); name == "" {
+		return fallback
+	}
+	return name
+}
+
+// importableURL 判断书签URL能否导入：需为带协议的绝对地址，排除书签小程序和浏览器内部的查询地址
+func importableURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "javascript", "place", "data":
+		return false
+	}
+	return u.Host != "" || u.Opaque != "" || u.Path != ""
+}
+
+// isImageData 判断图标是否为 data:image 地址
+func isImageData(s string) bool {
+	return strings.HasPrefix(strings.ToLower(s), "data:image/")
+}
+
+// isImageURL 判断图标是否为 http(s) 地址
+func isImageURL(s string) bool {
+	lower := strings.ToLower(s)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
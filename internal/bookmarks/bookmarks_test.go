@@ -0,0 +1,51 @@
+package bookmarks
+
+import (
+	"strings"
+	"testing"
+
+	"itab-backend/internal/models"
+)
+
+func emptyData() *models.BackupData {
+	return &models.BackupData{
+		Partitions:    []models.Partition{},
+		Folders:       []models.Folder{},
+		Shortcuts:     []models.Shortcut{},
+		SearchEngines: []models.SearchEngine{},
+	}
+}
+
+func TestImportFlattensDeepFolders(t *testing.T) {
+	// 分区下嵌套 maxFolderDepth+10 层文件夹，最深处有一个书签
+	leaf := &Node{Title: "Deep", URL: "https://example.com/deep"}
+	node := &Node{Title: "f", Folder: true, Children: []*Node{leaf}}
+	for i := 0; i < maxFolderDepth+10; i++ {
+		node = &Node{Title: "f", Folder: true, Children: []*Node{node}}
+	}
+	root := &Node{Title: "分区", Folder: true, Children: []*Node{node}}
+
+	bd := emptyData()
+	stats := Import(bd, []*Node{root})
+	if stats.Shortcuts != 1 || stats.Folders != 1 {
+		t.Fatalf("导入统计 = %+v，深层书签应放入最深一层允许的文件夹", stats)
+	}
+	if levels := strings.Count(bd.Folders[0].Name, folderPathSeparator) + 1; levels != maxFolderDepth {
+		t.Errorf("文件夹名称层数 = %d，应为 %d", levels, maxFolderDepth)
+	}
+	if s := bd.Shortcuts[0]; s.FolderID == nil || *s.FolderID != bd.Folders[0].ID {
+		t.Errorf("书签 = %+v", s)
+	}
+}
+
+func TestImportTerminatesOnCyclicTree(t *testing.T) {
+	folder := &Node{Title: "loop", Folder: true}
+	folder.Children = []*Node{{Title: "Go", URL: "https://go.dev"}, folder}
+	root := &Node{Title: "分区", Folder: true, Children: []*Node{folder}}
+
+	bd := emptyData()
+	stats := Import(bd, []*Node{root})
+	if stats.Shortcuts == 0 {
+		t.Errorf("导入统计 = %+v", stats)
+	}
+}
@@ -0,0 +1,50 @@
+package bookmarks
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// csvHeader CSV 导出的列：分区、文件夹、名称、地址
+var csvHeader = []string{"partition", "folder", "name", "url"}
+
+// utf8BOM 写在 CSV 开头，使 Excel 能正确识别 UTF-8 编码
+const utf8BOM = "\ufeff"
+
+// WriteCSV 将书签树写出为 CSV，每个书签一行，顺序与书签树一致
+// partition 为顶层文件夹的名称，folder 为其中的文件夹名称（多层时以「 / 」连接），不在分区中的书签两列均为空
+// 以 = + - @ 开头的单元格（包括地址）会加上「'」前缀，避免在电子表格中被当作公式执行
+func WriteCSV(w io.Writer, roots []*Node, title string) error {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	writeCSVRows(cw, roots, nil)
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeCSVRows(w *csv.Writer, nodes []*Node, path []string) {
+	for _, n := range nodes {
+		if n.Folder {
+			writeCSVRows(w, n.Children, append(path[:len(path):len(path)], n.Title))
+			continue
+		}
+		var partition, folder string
+		if len(path) > 0 {
+			partition = path[0]
+			folder = strings.Join(path[1:], folderPathSeparator)
+		}
+		w.Write([]string{csvCell(partition), csvCell(folder), csvCell(n.Title), csvCell(n.URL)})
+	}
+}
+
+// csvCell 防止单元格内容被电子表格当作公式
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
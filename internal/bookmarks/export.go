@@ -0,0 +1,124 @@
+package bookmarks
+
+import (
+	"io"
+	"sort"
+
+	"itab-backend/internal/models"
+)
+
+// ExportOptions 导出选项
+type ExportOptions struct {
+	Title          string // 导出文件的标题，通常为备份名称
+	IncludePrivate bool   // 是否包含私密的分区、文件夹和书签
+}
+
+// ExportFormat 导出格式
+type ExportFormat struct {
+	Ext         string // 文件扩展名
+	ContentType string
+	write       func(w io.Writer, roots []*Node, title string) error
+}
+
+// exportFormats 支持的导出格式
+var exportFormats = map[string]*ExportFormat{
+	FormatHTML:     {Ext: ".html", ContentType: "text/html; charset=utf-8", write: WriteHTML},
+	FormatMarkdown: {Ext: ".md", ContentType: "text/markdown; charset=utf-8", write: WriteMarkdown},
+	FormatCSV:      {Ext: ".csv", ContentType: "text/csv; charset=utf-8", write: WriteCSV},
+	FormatOPML:     {Ext: ".opml", ContentType: "text/x-opml; charset=utf-8", write: WriteOPML},
+}
+
+// LookupExport 查找导出格式，不支持时返回 nil 和 false
+func LookupExport(format string) (*ExportFormat, bool) {
+	f, ok := exportFormats[format]
+	return f, ok
+}
+
+// Write 将备份数据按此格式写出
+func (f *ExportFormat) Write(w io.Writer, bd *models.BackupData, opts ExportOptions) error {
+	return f.write(w, Tree(bd, opts.IncludePrivate), opts.Title)
+}
+
+// Tree 将备份数据转换为书签树：分区为顶层文件夹，分区中的文件夹在前、独立书签在后，均按排序号排列
+// 不属于任何分区的文件夹和书签放在顶层；includePrivate 为 false 时排除私密的分区、文件夹和书签及其中的内容
+func Tree(bd *models.BackupData, includePrivate bool) []*Node {
+	partitions := append([]models.Partition(nil), bd.Partitions...)
+	sort.SliceStable(partitions, func(a, b int) bool { return partitions[a].Order < partitions[b].Order })
+	folders := append([]models.Folder(nil), bd.Folders...)
+	sort.SliceStable(folders, func(a, b int) bool { return folders[a].Order < folders[b].Order })
+	shortcuts := append([]models.Shortcut(nil), bd.Shortcuts...)
+	sort.SliceStable(shortcuts, func(a, b int) bool { return shortcuts[a].Order < shortcuts[b].Order })
+
+	partitionIDs := make(map[int]bool, len(bd.Partitions))
+	for _, p := range bd.Partitions {
+		partitionIDs[p.ID] = true
+	}
+	folderIDs := make(map[int]bool, len(bd.Folders))
+	for _, f := range bd.Folders {
+		folderIDs[f.ID] = true
+	}
+
+	root := &Node{Folder: true}
+	partitionNodes := make(map[int]*Node)
+	for _, p := range partitions {
+		if p.IsPrivate && !includePrivate {
+			continue
+		}
+		n := &Node{Title: p.Name, Folder: true}
+		partitionNodes[p.ID] = n
+		root.Children = append(root.Children, n)
+	}
+	// parent 查找条目所属分区的节点，分区被排除时返回 nil
+	parent := func(partitionID *int) *Node {
+		if partitionID == nil {
+			return root
+		}
+		if n, ok := partitionNodes[*partitionID]; ok {
+			return n
+		}
+		if !partitionIDs[*partitionID] {
+			return root // 引用的分区不存在时按不属于任何分区处理
+		}
+		return nil
+	}
+
+	folderNodes := make(map[int]*Node)
+	for _, f := range folders {
+		if f.IsPrivate && !includePrivate {
+			continue
+		}
+		p := parent(f.PartitionID)
+		if p == nil {
+			continue
+		}
+		n := &Node{Title: f.Name, Folder: true}
+		folderNodes[f.ID] = n
+		p.Children = append(p.Children, n)
+	}
+
+	for _, s := range shortcuts {
+		if s.IsPrivate && !includePrivate {
+			continue
+		}
+		var p *Node
+		if s.FolderID != nil && folderIDs[*s.FolderID] {
+			p = folderNodes[*s.FolderID]
+		} else {
+			p = parent(s.PartitionID)
+		}
+		if p == nil {
+			continue
+		}
+		n := &Node{Title: s.Name, URL: s.URL}
+		if isImageData(s.Icon) {
+			n.Icon = s.Icon
+		}
+		if isImageURL(s.IconUrl) {
+			n.IconURL = s.IconUrl
+		} else if isImageURL(s.Icon) {
+			n.IconURL = s.Icon
+		}
+		p.Children = append(p.Children, n)
+	}
+	return root.Children
+}
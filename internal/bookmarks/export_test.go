@@ -0,0 +1,162 @@
+package bookmarks
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+
+	"itab-backend/internal/models"
+)
+
+func intPtr(i int) *int { return &i }
+
+// exportData 覆盖导出顺序规则的备份数据：条目在切片中的顺序与排序号不同，
+// 含私密条目、不属于任何分区的条目以及需要转义的名称和地址
+func exportData() *models.BackupData {
+	return &models.BackupData{
+		Partitions: []models.Partition{
+			{ID: 2, Name: "工作", Order: 1},
+			{ID: 1, Name: "常用", Order: 0},
+			{ID: 3, Name: "私密", Order: 2, IsPrivate: true},
+		},
+		Folders: []models.Folder{
+			{ID: 11, Name: "文档 [Go]", PartitionID: intPtr(1), Order: 1},
+			{ID: 10, Name: "开发", PartitionID: intPtr(1), Order: 0},
+			{ID: 12, Name: "散落", Order: 0},
+		},
+		Shortcuts: []models.Shortcut{
+			{ID: 1, Name: "=SUM(A1)", URL: "=HYPERLINK(\"x\")", PartitionID: intPtr(1), Order: 1},
+			{ID: 2, Name: "GitHub", URL: "https://github.com", FolderID: intPtr(10), PartitionID: intPtr(1), Order: 0},
+			{ID: 3, Name: "Go *spec*", URL: "https://go.dev/ref/spec (1)", FolderID: intPtr(11), PartitionID: intPtr(1), Order: 0},
+			{ID: 4, Name: "首页", URL: "https://example.com", PartitionID: intPtr(1), Order: 0},
+			{ID: 5, Name: "Jira", URL: "https://jira.example.com", PartitionID: intPtr(2), Order: 0},
+			{ID: 6, Name: "秘密", URL: "https://secret.example.com", PartitionID: intPtr(3), Order: 0},
+			{ID: 7, Name: "隐藏", URL: "https://hidden.example.com", PartitionID: intPtr(2), Order: 1, IsPrivate: true},
+			{ID: 8, Name: "无分区", URL: "https://loose.example.com", Order: 0},
+			{ID: 9, Name: "文件夹内", URL: "https://in-folder.example.com", FolderID: intPtr(12), Order: 0},
+		},
+	}
+}
+
+func export(t *testing.T, format string) string {
+	t.Helper()
+	f, ok := LookupExport(format)
+	if !ok {
+		t.Fatalf("不支持的导出格式 %s", format)
+	}
+	var buf bytes.Buffer
+	if err := f.Write(&buf, exportData(), ExportOptions{Title: "我的书签"}); err != nil {
+		t.Fatalf("导出 %s 失败: %v", format, err)
+	}
+	return buf.String()
+}
+
+func TestWriteCSVGolden(t *testing.T) {
+	want := utf8BOM + `partition,folder,name,url
+常用,开发,GitHub,https://github.com
+常用,文档 [Go],Go *spec*,https://go.dev/ref/spec (1)
+常用,,首页,https://example.com
+常用,,'=SUM(A1),"'=HYPERLINK(""x"")"
+工作,,Jira,https://jira.example.com
+散落,,文件夹内,https://in-folder.example.com
+,,无分区,https://loose.example.com
+`
+	if got := export(t, FormatCSV); got != want {
+		t.Errorf("CSV 导出 =\n%s\n应为\n%s", got, want)
+	}
+}
+
+func TestWriteMarkdownGolden(t *testing.T) {
+	want := `# 我的书签
+
+- 常用
+  - 开发
+    - [GitHub](https://github.com)
+  - 文档 \[Go\]
+    - [Go \*spec\*](https://go.dev/ref/spec%20%281%29)
+  - [首页](https://example.com)
+  - [=SUM(A1)](=HYPERLINK%28"x"%29)
+- 工作
+  - [Jira](https://jira.example.com)
+- 散落
+  - [文件夹内](https://in-folder.example.com)
+- [无分区](https://loose.example.com)
+`
+	if got := export(t, FormatMarkdown); got != want {
+		t.Errorf("Markdown 导出 =\n%s\n应为\n%s", got, want)
+	}
+}
+
+// parseOPML 将 OPML 大纲还原为书签树
+func parseOPML(t *testing.T, data string) (string, []*Node) {
+	t.Helper()
+	var doc opmlDocument
+	if err := xml.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatalf("解析 OPML 失败: %v", err)
+	}
+	if doc.Version != "2.0" {
+		t.Errorf("OPML 版本 = %q", doc.Version)
+	}
+	var convert func([]opmlOutline) []*Node
+	convert = func(outlines []opmlOutline) []*Node {
+		nodes := make([]*Node, 0, len(outlines))
+		for _, o := range outlines {
+			if o.Type == "link" {
+				nodes = append(nodes, &Node{Title: o.Text, URL: o.URL})
+			} else {
+				nodes = append(nodes, &Node{Title: o.Text, Folder: true, Children: convert(o.Children)})
+			}
+		}
+		return nodes
+	}
+	return doc.Title, convert(doc.Body)
+}
+
+func TestWriteOPMLRoundTrip(t *testing.T) {
+	tree := Tree(exportData(), false)
+	// 图标不写入 OPML
+	var stripIcons func([]*Node)
+	stripIcons = func(nodes []*Node) {
+		for _, n := range nodes {
+			n.Icon, n.IconURL = "", ""
+			stripIcons(n.Children)
+		}
+	}
+	stripIcons(tree)
+
+	out := export(t, FormatOPML)
+	if !strings.HasPrefix(out, xml.Header) {
+		t.Errorf("OPML 缺少 XML 声明")
+	}
+	title, got := parseOPML(t, out)
+	if title != "我的书签" {
+		t.Errorf("标题 = %q", title)
+	}
+	if !reflect.DeepEqual(normalize(got), normalize(tree)) {
+		t.Errorf("OPML 往返结果与书签树不一致:\n%s", out)
+	}
+}
+
+// normalize 将空的子节点列表统一为 nil，便于比较
+func normalize(nodes []*Node) []*Node {
+	if len(nodes) == 0 {
+		return nil
+	}
+	for _, n := range nodes {
+		n.Children = normalize(n.Children)
+	}
+	return nodes
+}
+
+func TestExportExcludesPrivate(t *testing.T) {
+	for _, format := range []string{FormatHTML, FormatMarkdown, FormatCSV, FormatOPML} {
+		out := export(t, format)
+		for _, hidden := range []string{"私密", "秘密", "隐藏"} {
+			if strings.Contains(out, hidden) {
+				t.Errorf("%s 导出包含私密条目 %q", format, hidden)
+			}
+		}
+	}
+}
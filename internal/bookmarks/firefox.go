@@ -0,0 +1,187 @@
+package bookmarks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Firefox 书签类型（moz_bookmarks.type）
+const (
+	firefoxTypeBookmark = 1
+	firefoxTypeFolder   = 2
+)
+
+// firefoxRoots Firefox 的书签根文件夹，按此顺序排列；数据库中根文件夹的标题为内部名称（如 toolbar）或为空，统一使用这里的名称
+// 标签（tags________）不是书签文件夹，不导入
+var firefoxRoots = []struct {
+	GUID string
+	Name string
+}{
+	{"toolbar_____", "书签工具栏"},
+	{"menu________", "书签菜单"},
+	{"unfiled_____", "其他书签"},
+	{"mobile______", "移动设备书签"},
+}
+
+// firefoxBookmark moz_bookmarks 与 moz_places 关联查询的一行
+type firefoxBookmark struct {
+	ID        int64
+	Type      int
+	Parent    int64
+	Position  int
+	Title     *string
+	DateAdded *int64 // 微秒
+	GUID      *string
+	URL       *string
+}
+
+// ParseFirefox 解析 Firefox 用户目录中的 places.sqlite，使用项目内置的 SQLite 驱动读取
+// 各根文件夹（书签工具栏、书签菜单、其他书签、移动设备书签）作为顶层文件夹，空的根文件夹被忽略
+func ParseFirefox(data []byte) ([]*Node, error) {
+	// SQLite 只能打开文件，复制到临时文件中读取，避免修改上传的原始数据
+	file, err := os.CreateTemp("", "places-*.sqlite")
+	if err != nil {
+		return nil, err
+	}
+	path := file.Name()
+	defer func() {
+		for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+			os.Remove(path + suffix)
+		}
+	}()
+	_, err = file.Write(data)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 以只读方式打开，并禁止上传文件的表结构（视图、触发器、生成列等）调用有副作用的函数
+	dsn := "file:" + filepath.ToSlash(path) + "?mode=ro&_pragma=trusted_schema(0)&_pragma=query_only(1)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, ErrInvalidFile
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	if err := checkFirefoxSchema(db); err != nil {
+		return nil, err
+	}
+
+	var rows []firefoxBookmark
+	err = db.Raw(`SELECT b.id, b.type, b.parent, b.position, b.title, b.dateAdded AS date_added, b.guid, p.url
+		FROM moz_bookmarks b LEFT JOIN moz_places p ON p.id = b.fk
+		ORDER BY b.parent, b.position`).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("%w: 不是 Firefox 的 places.sqlite", ErrInvalidFile)
+	}
+	return firefoxTree(rows), nil
+}
+
+// checkFirefoxSchema 确认要查询的 moz_bookmarks、moz_places 是普通表，而不是构造的视图或虚拟表
+func checkFirefoxSchema(db *gorm.DB) error {
+	var tables []struct {
+		Name string
+		Type string
+		SQL  string
+	}
+	err := db.Raw(`SELECT name, type, sql FROM sqlite_master WHERE name IN ('moz_bookmarks', 'moz_places')`).Scan(&tables).Error
+	if err != nil {
+		return fmt.Errorf("%w: 不是 Firefox 的 places.sqlite", ErrInvalidFile)
+	}
+	found := 0
+	for _, t := range tables {
+		if t.Type != "table" || strings.HasPrefix(strings.ToUpper(strings.TrimSpace(t.SQL)), "CREATE VIRTUAL") {
+			return fmt.Errorf("%w: %s 不是普通的表", ErrInvalidFile, t.Name)
+		}
+		found++
+	}
+	if found != 2 {
+		return fmt.Errorf("%w: 不是 Firefox 的 places.sqlite", ErrInvalidFile)
+	}
+	return nil
+}
+
+// firefoxTree 由查询结果构建书签树
+// 只从根文件夹向下构建，每个条目至多出现一次：ID 重复时取第一行，父节点为自身的条目、
+// 形成环的条目以及超过 maxFolderDepth 层的内容都会被忽略，构造的数据库无法让构建陷入死循环
+func firefoxTree(rows []firefoxBookmark) []*Node {
+	items := make(map[int64]firefoxBookmark, len(rows))
+	children := make(map[int64][]int64)
+	guids := make(map[string]int64)
+	for _, r := range rows {
+		if _, dup := items[r.ID]; dup {
+			continue
+		}
+		if r.Type != firefoxTypeFolder && r.Type != firefoxTypeBookmark {
+			continue // 分隔符
+		}
+		items[r.ID] = r
+		if r.GUID != nil {
+			if _, ok := guids[*r.GUID]; !ok {
+				guids[*r.GUID] = r.ID
+			}
+		}
+		// rows 已按 parent、position 排序，依次追加即保持原顺序
+		if r.Parent != r.ID {
+			children[r.Parent] = append(children[r.Parent], r.ID)
+		}
+	}
+
+	visited := make(map[int64]bool)
+	var build func(id int64, depth int) *Node
+	build = func(id int64, depth int) *Node {
+		visited[id] = true
+		r := items[id]
+		if r.Type == firefoxTypeBookmark {
+			return &Node{Title: deref(r.Title), URL: deref(r.URL), AddDate: r.addDate()}
+		}
+		n := &Node{Title: deref(r.Title), AddDate: r.addDate(), Folder: true}
+		if depth >= maxFolderDepth {
+			return n
+		}
+		for _, child := range children[id] {
+			if !visited[child] {
+				n.Children = append(n.Children, build(child, depth+1))
+			}
+		}
+		return n
+	}
+
+	var roots []*Node
+	for _, root := range firefoxRoots {
+		id, ok := guids[root.GUID]
+		if !ok || visited[id] || items[id].Type != firefoxTypeFolder {
+			continue
+		}
+		n := build(id, 0)
+		if len(n.Children) == 0 {
+			continue
+		}
+		n.Title = root.Name
+		roots = append(roots, n)
+	}
+	return roots
+}
+
+func (r firefoxBookmark) addDate() int64 {
+	if r.DateAdded == nil || *r.DateAdded <= 0 {
+		return 0
+	}
+	return *r.DateAdded / 1000000
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,173 @@
+package bookmarks
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func strPtr(s string) *string { return &s }
+
+func folderRow(id, parent int64, title string) firefoxBookmark {
+	return firefoxBookmark{ID: id, Type: firefoxTypeFolder, Parent: parent, Title: strPtr(title)}
+}
+
+func bookmarkRow(id, parent int64, title, url string) firefoxBookmark {
+	return firefoxBookmark{ID: id, Type: firefoxTypeBookmark, Parent: parent, Title: strPtr(title), URL: strPtr(url)}
+}
+
+func rootRow(id int64, guid string) firefoxBookmark {
+	r := folderRow(id, 1, "")
+	r.GUID = strPtr(guid)
+	return r
+}
+
+func TestFirefoxTreeIgnoresCycles(t *testing.T) {
+	rows := []firefoxBookmark{
+		folderRow(1, 0, ""),
+		rootRow(2, "toolbar_____"),
+		bookmarkRow(10, 2, "Go", "https://go.dev"),
+		// 父节点为自身
+		folderRow(11, 11, "self"),
+		// 环 20 -> 21 -> 20，从根文件夹不可达
+		folderRow(20, 21, "A"),
+		folderRow(21, 20, "B"),
+		// 30 在工具栏下，31 在 30 下
+		folderRow(30, 2, "loop"),
+		folderRow(31, 30, "inner"),
+		bookmarkRow(32, 31, "Deep", "https://example.com/deep"),
+		// 重复的 ID 只取第一行
+		bookmarkRow(10, 31, "重复", "https://example.com/dup"),
+	}
+	// 把 30 再挂到 31 下形成环
+	loop := folderRow(30, 31, "loop-again")
+	rows = append(rows, loop)
+
+	roots := firefoxTree(rows)
+	if len(roots) != 1 || roots[0].Title != "书签工具栏" {
+		t.Fatalf("根文件夹 = %+v", roots)
+	}
+	toolbar := roots[0]
+	if len(toolbar.Children) != 2 {
+		t.Fatalf("工具栏子节点数 = %d，应为 2", len(toolbar.Children))
+	}
+	if toolbar.Children[0].Title != "Go" {
+		t.Errorf("第一个书签 = %q", toolbar.Children[0].Title)
+	}
+	folder := toolbar.Children[1]
+	if folder.Title != "loop" || len(folder.Children) != 1 {
+		t.Fatalf("文件夹 = %+v", folder)
+	}
+	inner := folder.Children[0]
+	if len(inner.Children) != 1 || inner.Children[0].URL != "https://example.com/deep" {
+		t.Errorf("内层文件夹 = %+v，环上的节点不应重复出现", inner.Children)
+	}
+}
+
+func TestFirefoxTreeDepthLimit(t *testing.T) {
+	rows := []firefoxBookmark{folderRow(1, 0, ""), rootRow(2, "menu________")}
+	parent := int64(2)
+	for i := int64(100); i < 100+2*maxFolderDepth; i++ {
+		rows = append(rows, folderRow(i, parent, "f"))
+		parent = i
+	}
+	rows = append(rows, bookmarkRow(1000, parent, "too deep", "https://example.com"))
+
+	roots := firefoxTree(rows)
+	if len(roots) != 1 {
+		t.Fatalf("根文件夹数 = %d", len(roots))
+	}
+	depth := 0
+	for n := roots[0]; len(n.Children) > 0; n = n.Children[0] {
+		depth++
+	}
+	if depth != maxFolderDepth {
+		t.Errorf("层数 = %d，应截断为 %d", depth, maxFolderDepth)
+	}
+}
+
+// writePlaces 用给定的建表语句生成 places.sqlite 文件内容
+func writePlaces(t *testing.T, statements ...string) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "places.sqlite")
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("执行 %q 失败: %v", stmt, err)
+		}
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取数据库失败: %v", err)
+	}
+	return data
+}
+
+const (
+	createPlaces    = `CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url TEXT)`
+	createBookmarks = `CREATE TABLE moz_bookmarks (id INTEGER PRIMARY KEY, type INTEGER, fk INTEGER, parent INTEGER, position INTEGER, title TEXT, dateAdded INTEGER, guid TEXT)`
+)
+
+func TestParseFirefox(t *testing.T) {
+	data := writePlaces(t, createPlaces, createBookmarks,
+		`INSERT INTO moz_places VALUES (1, 'https://go.dev'), (2, 'https://example.com')`,
+		`INSERT INTO moz_bookmarks VALUES
+			(1, 2, NULL, 0, 0, '', 0, 'root________'),
+			(2, 2, NULL, 1, 0, 'toolbar', 0, 'toolbar_____'),
+			(3, 2, NULL, 1, 1, 'menu', 0, 'menu________'),
+			(4, 1, 1, 2, 0, 'Go', 1700000000000000, 'a'),
+			(5, 2, NULL, 2, 1, '工具', 0, 'b'),
+			(6, 1, 2, 5, 0, 'Example', 0, 'c'),
+			(7, 2, NULL, 7, 0, 'self', 0, 'd')`,
+	)
+	if Detect(data) != FormatFirefox {
+		t.Fatalf("Detect = %s", Detect(data))
+	}
+
+	roots, err := ParseFirefox(data)
+	if err != nil {
+		t.Fatalf("ParseFirefox: %v", err)
+	}
+	if len(roots) != 1 || roots[0].Title != "书签工具栏" || len(roots[0].Children) != 2 {
+		t.Fatalf("解析结果 = %+v", roots)
+	}
+	if c := roots[0].Children[0]; c.URL != "https://go.dev" || c.AddDate != 1700000000 {
+		t.Errorf("书签 = %+v", c)
+	}
+	if f := roots[0].Children[1]; f.Title != "工具" || len(f.Children) != 1 || f.Children[0].Title != "Example" {
+		t.Errorf("文件夹 = %+v", f)
+	}
+}
+
+func TestParseFirefoxRejectsViews(t *testing.T) {
+	tests := []struct {
+		name       string
+		statements []string
+	}{
+		{"moz_bookmarks 为视图", []string{
+			createPlaces,
+			`CREATE TABLE real_bookmarks (id INTEGER PRIMARY KEY, type INTEGER, fk INTEGER, parent INTEGER, position INTEGER, title TEXT, dateAdded INTEGER, guid TEXT)`,
+			`CREATE VIEW moz_bookmarks AS SELECT * FROM real_bookmarks`,
+		}},
+		{"缺少 moz_places", []string{createBookmarks}},
+		{"空数据库", []string{`CREATE TABLE t (id INTEGER)`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseFirefox(writePlaces(t, tt.statements...)); !errors.Is(err, ErrInvalidFile) {
+				t.Errorf("err = %v，应为 ErrInvalidFile", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package bookmarks
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// markdownEscaper 转义 Markdown 文本中有特殊含义的字符，换行替换为空格以免打断列表
+var markdownEscaper = strings.NewReplacer(
+	"\r\n", " ", "\n", " ", "\r", " ",
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+)
+
+// markdownURLEscaper 转义链接地址中会截断 Markdown 链接的字符
+var markdownURLEscaper = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E")
+
+// WriteMarkdown 将书签树写出为 Markdown 嵌套列表
+// 第一行为「# 标题」，随后每个节点一行：文件夹为「- 名称」，书签为「- [名称](地址)」，每深一层缩进两个空格，顺序与书签树一致
+func WriteMarkdown(w io.Writer, roots []*Node, title string) error {
+	bw := bufio.NewWriter(w)
+	if title != "" {
+		bw.WriteString("# " + markdownEscaper.Replace(title) + "\n\n")
+	}
+	writeMarkdownList(bw, roots, 0)
+	return bw.Flush()
+}
+
+func writeMarkdownList(w *bufio.Writer, nodes []*Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, n := range nodes {
+		if n.Folder {
+			w.WriteString(indent + "- " + markdownEscaper.Replace(n.Title) + "\n")
+			writeMarkdownList(w, n.Children, depth+1)
+			continue
+		}
+		w.WriteString(indent + "- [" + markdownEscaper.Replace(n.Title) + "](" + markdownURLEscaper.Replace(n.URL) + ")\n")
+	}
+}
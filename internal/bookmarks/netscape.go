@@ -0,0 +1,326 @@
+package bookmarks
+
+import (
+	"bufio"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// netscapeHeader Netscape 书签文件的固定文件头，浏览器据此识别文件格式
+const netscapeHeader = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+`
+
+// WriteHTML 将书签树写出为 Netscape 书签文件，可被 Chrome、Firefox、Safari 等浏览器导入
+func WriteHTML(w io.Writer, roots []*Node, title string) error {
+	if title == "" {
+		title = "Bookmarks"
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString(netscapeHeader)
+	bw.WriteString("<TITLE>" + html.EscapeString(title) + "</TITLE>\n")
+	bw.WriteString("<H1>" + html.EscapeString(title) + "</H1>\n")
+	writeHTMLList(bw, roots, 0)
+	return bw.Flush()
+}
+
+func writeHTMLList(w *bufio.Writer, nodes []*Node, depth int) {
+	indent := strings.Repeat("    ", depth)
+	w.WriteString(indent + "<DL><p>\n")
+	for _, n := range nodes {
+		w.WriteString(indent + "    <DT>")
+		if n.Folder {
+			w.WriteString("<H3" + dateAttr(n.AddDate) + ">" + html.EscapeString(n.Title) + "</H3>\n")
+			writeHTMLList(w, n.Children, depth+1)
+			continue
+		}
+		w.WriteString(`<A HREF="` + html.EscapeString(n.URL) + `"` + dateAttr(n.AddDate))
+		if n.IconURL != "" {
+			w.WriteString(` ICON_URI="` + html.EscapeString(n.IconURL) + `"`)
+		}
+		if n.Icon != "" {
+			w.WriteString(` ICON="` + html.EscapeString(n.Icon) + `"`)
+		}
+		w.WriteString(">" + html.EscapeString(n.Title) + "</A>\n")
+	}
+	w.WriteString(indent + "</DL><p>\n")
+}
+
+// dateAttr 生成 ADD_DATE 属性，时间未知时不输出
+func dateAttr(t int64) string {
+	if t <= 0 {
+		return ""
+	}
+	return ` ADD_DATE="` + strconv.FormatInt(t, 10) + `"`
+}
+
+// ParseHTML 解析 Netscape 书签文件（浏览器导出的 bookmarks.html）
+// 该格式并不是规范的 HTML：<DT> 和 <p> 通常不闭合，文件夹由 <H3> 标题后紧跟的 <DL> 列表表示，
+// 因此这里只识别 H3/A/DL 标签，其余内容忽略
+func ParseHTML(data []byte) ([]*Node, error) {
+	p := &netscapeParser{src: string(data)}
+	root := &Node{Folder: true}
+	stack := []*Node{root}
+	var pending *Node // 最近的文件夹标题，等待其后的 <DL>
+	found := false
+
+	for {
+		name, attrs, closing, ok := p.nextTag()
+		if !ok {
+			break
+		}
+		top := stack[len(stack)-1]
+
+		switch {
+		case name == "dl" && !closing:
+			found = true
+			// 第一个 <DL> 是根列表；没有标题的 <DL> 仍入栈，保持与 </DL> 配对
+			switch {
+			case pending != nil:
+				stack = append(stack, pending)
+			case len(stack) == 1 && len(root.Children) == 0:
+				stack = append(stack, root)
+			default:
+				stack = append(stack, top)
+			}
+			pending = nil
+		case name == "dl" && closing:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			pending = nil
+		case name == "h3" && !closing:
+			folder := &Node{
+				Title:   p.textUntil("h3"),
+				AddDate: parseUnix(attrs["add_date"]),
+				Folder:  true,
+			}
+			top.Children = append(top.Children, folder)
+			pending = folder
+		case name == "a" && !closing:
+			found = true
+			top.Children = append(top.Children, &Node{
+				Title:   p.textUntil("a"),
+				URL:     attrs["href"],
+				Icon:    attrs["icon"],
+				IconURL: attrs["icon_uri"],
+				AddDate: parseUnix(attrs["add_date"]),
+			})
+			pending = nil
+		}
+	}
+
+	if !found {
+		return nil, ErrInvalidFile
+	}
+	return root.Children, nil
+}
+
+// netscapeParser 简单的标签扫描器，足以处理各浏览器导出的书签文件
+type netscapeParser struct {
+	src string
+	pos int
+}
+
+// nextTag 读取下一个标签，返回小写的标签名、属性（小写键名、已反转义的值）以及是否为闭合标签
+// 注释、DOCTYPE 等声明会被跳过
+func (p *netscapeParser) nextTag() (name string, attrs map[string]string, closing, ok bool) {
+	for {
+		i := strings.IndexByte(p.src[p.pos:], '<')
+		if i < 0 {
+			p.pos = len(p.src)
+			return "", nil, false, false
+		}
+		p.pos += i + 1
+
+		if strings.HasPrefix(p.src[p.pos:], "!--") {
+			end := strings.Index(p.src[p.pos:], "-->")
+			if end < 0 {
+				p.pos = len(p.src)
+				return "", nil, false, false
+			}
+			p.pos += end + 3
+			continue
+		}
+		if strings.HasPrefix(p.src[p.pos:], "!") || strings.HasPrefix(p.src[p.pos:], "?") {
+			p.skipPast('>')
+			continue
+		}
+
+		if strings.HasPrefix(p.src[p.pos:], "/") {
+			closing = true
+			p.pos++
+		}
+		start := p.pos
+		for p.pos < len(p.src) && isNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+		if p.pos == start {
+			// 不是标签，例如文本中的「<」
+			continue
+		}
+		name = strings.ToLower(p.src[start:p.pos])
+		attrs = p.attributes()
+		return name, attrs, closing, true
+	}
+}
+
+// attributes 读取标签的属性直到「>」
+func (p *netscapeParser) attributes() map[string]string {
+	attrs := make(map[string]string)
+	for p.pos < len(p.src) {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			break
+		}
+		if c := p.src[p.pos]; c == '>' {
+			p.pos++
+			break
+		} else if c == '/' {
+			p.pos++
+			continue
+		}
+
+		start := p.pos
+		for p.pos < len(p.src) && !isSpace(p.src[p.pos]) && p.src[p.pos] != '=' && p.src[p.pos] != '>' {
+			p.pos++
+		}
+		key := strings.ToLower(p.src[start:p.pos])
+		if p.pos == start {
+			p.pos++
+			continue
+		}
+
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] != '=' {
+			attrs[key] = ""
+			continue
+		}
+		p.pos++
+		p.skipSpace()
+
+		var value string
+		if p.pos < len(p.src) && (p.src[p.pos] == '"' || p.src[p.pos] == '\'') {
+			quote := p.src[p.pos]
+			p.pos++
+			end := strings.IndexByte(p.src[p.pos:], quote)
+			if end < 0 {
+				end = len(p.src) - p.pos
+			}
+			value = p.src[p.pos : p.pos+end]
+			p.pos = min(p.pos+end+1, len(p.src))
+		} else {
+			start := p.pos
+			for p.pos < len(p.src) && !isSpace(p.src[p.pos]) && p.src[p.pos] != '>' {
+				p.pos++
+			}
+			value = p.src[start:p.pos]
+		}
+		attrs[key] = html.UnescapeString(value)
+	}
+	return attrs
+}
+
+// textUntil 读取到指定闭合标签为止的文本，去除其中的标签并反转义
+// 只在原文中逐个检查「<」处的标签名，遇到闭合标签或书签结构标签（说明闭合标签缺失）即停止，
+// 因此每段文本只扫描到下一个结构标签为止
+func (p *netscapeParser) textUntil(tag string) string {
+	start := p.pos
+	for i := start; ; i++ {
+		j := strings.IndexByte(p.src[i:], '<')
+		if j < 0 {
+			p.pos = len(p.src)
+			break
+		}
+		i += j
+		name, closing := tagNameAt(p.src, i)
+		if closing && name == tag {
+			p.pos = i
+			p.skipPast('>')
+			return cleanText(p.src[start:i])
+		}
+		if structuralTags[name] {
+			p.pos = i
+			break
+		}
+	}
+	return cleanText(p.src[start:p.pos])
+}
+
+// structuralTags 书签文件的结构标签，出现在标题文本中说明标题的闭合标签缺失
+var structuralTags = map[string]bool{"a": true, "dd": true, "dl": true, "dt": true, "h3": true}
+
+// tagNameAt 读取 s[i]（「<」）处标签的小写名称以及是否为闭合标签，不是标签时名称为空
+func tagNameAt(s string, i int) (name string, closing bool) {
+	i++
+	if i < len(s) && s[i] == '/' {
+		closing = true
+		i++
+	}
+	start := i
+	for i < len(s) && isNameChar(s[i]) {
+		i++
+	}
+	return strings.ToLower(s[start:i]), closing
+}
+
+// cleanText 去除文本中的标签、反转义并去除首尾空白
+func cleanText(s string) string {
+	return strings.TrimSpace(html.UnescapeString(stripTags(s)))
+}
+
+func (p *netscapeParser) skipPast(c byte) {
+	if i := strings.IndexByte(p.src[p.pos:], c); i >= 0 {
+		p.pos += i + 1
+	} else {
+		p.pos = len(p.src)
+	}
+}
+
+func (p *netscapeParser) skipSpace() {
+	for p.pos < len(p.src) && isSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// stripTags 去除文本中的标签
+func stripTags(s string) string {
+	if !strings.Contains(s, "<") {
+		return s
+	}
+	var b strings.Builder
+	inTag := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '<':
+			inTag = true
+		case c == '>' && inTag:
+			inTag = false
+		case !inTag:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// parseUnix 解析 Unix 秒时间戳，无效时返回 0
+func parseUnix(s string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
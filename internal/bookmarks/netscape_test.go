@@ -0,0 +1,103 @@
+package bookmarks
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHTMLTitles(t *testing.T) {
+	src := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<DL><p>
+    <DT><h3 ADD_DATE="1700000000">İstanbul <b>Gezi</b></H3>
+    <DL><p>
+        <DT><A HREF="https://example.com/i">İİİ &amp; ı</a>
+        <DT><A HREF="https://example.com/unclosed">没有闭合标签
+        <DT><A HREF="https://example.com/lt">a &lt; b &gt; c</A>
+    </DL><p>
+    <DT><H3>Ünclosed
+    <DL><p>
+        <DT><A HREF="https://example.com/x">X</A>
+    </DL><p>
+</DL><p>`
+	roots, err := ParseHTML([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseHTML: %v", err)
+	}
+	if len(roots) != 2 {
+		t.Fatalf("顶层节点数 = %d，应为 2", len(roots))
+	}
+
+	folder := roots[0]
+	if folder.Title != "İstanbul Gezi" || folder.AddDate != 1700000000 {
+		t.Errorf("文件夹 = %q (%d)", folder.Title, folder.AddDate)
+	}
+	want := []string{"İİİ & ı", "没有闭合标签", "a < b > c"}
+	if len(folder.Children) != len(want) {
+		t.Fatalf("书签数 = %d，应为 %d", len(folder.Children), len(want))
+	}
+	for i, w := range want {
+		if got := folder.Children[i].Title; got != w {
+			t.Errorf("书签 %d 标题 = %q，应为 %q", i, got, w)
+		}
+	}
+
+	if roots[1].Title != "Ünclosed" || len(roots[1].Children) != 1 || roots[1].Children[0].Title != "X" {
+		t.Errorf("缺少闭合标签的文件夹解析错误: %q %+v", roots[1].Title, roots[1].Children)
+	}
+}
+
+func TestParseHTMLRoundTrip(t *testing.T) {
+	roots := []*Node{
+		{Title: "工具 <栏>", Folder: true, AddDate: 1700000000, Children: []*Node{
+			{Title: "İ & \"quote\"", URL: "https://example.com/?a=1&b=2", AddDate: 1700000001},
+		}},
+		{Title: "Go", URL: "https://go.dev"},
+	}
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, roots, "Bookmarks"); err != nil {
+		t.Fatalf("WriteHTML: %v", err)
+	}
+	got, err := ParseHTML(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ParseHTML: %v", err)
+	}
+	if len(got) != 2 || got[0].Title != "工具 <栏>" || len(got[0].Children) != 1 {
+		t.Fatalf("解析结果 = %+v", got)
+	}
+	if c := got[0].Children[0]; c.Title != "İ & \"quote\"" || c.URL != "https://example.com/?a=1&b=2" || c.AddDate != 1700000001 {
+		t.Errorf("书签 = %+v", c)
+	}
+	if got[1].URL != "https://go.dev" {
+		t.Errorf("书签 = %+v", got[1])
+	}
+}
+
+// 大文件的解析时间应随文件大小线性增长
+func TestParseHTMLLargeFile(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("<DL><p>\n")
+	for i := 0; b.Len() < 2<<20; i++ {
+		// 一半书签缺少闭合标签
+		if i%2 == 0 {
+			fmt.Fprintf(&b, "<DT><A HREF=\"https://example.com/%d\">书签 İ %d</A>\n", i, i)
+		} else {
+			fmt.Fprintf(&b, "<DT><A HREF=\"https://example.com/%d\">书签 İ %d\n", i, i)
+		}
+	}
+	b.WriteString("</DL><p>\n")
+
+	start := time.Now()
+	roots, err := ParseHTML([]byte(b.String()))
+	if err != nil {
+		t.Fatalf("ParseHTML: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("解析 %d 字节耗时 %v", b.Len(), elapsed)
+	}
+	if len(roots) == 0 || roots[1].Title != "书签 İ 1" {
+		t.Errorf("解析结果错误: %d 个书签", len(roots))
+	}
+}
@@ -0,0 +1,49 @@
+package bookmarks
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// opmlDocument OPML 2.0 文档
+type opmlDocument struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Title   string        `xml:"head>title"`
+	Body    []opmlOutline `xml:"body>outline"`
+}
+
+// opmlOutline OPML 大纲节点：文件夹只有 text，书签另有 type="link" 和 url
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Type     string        `xml:"type,attr,omitempty"`
+	URL      string        `xml:"url,attr,omitempty"`
+	Children []opmlOutline `xml:"outline"`
+}
+
+// WriteOPML 将书签树写出为 OPML 2.0 大纲，节点顺序与书签树一致
+func WriteOPML(w io.Writer, roots []*Node, title string) error {
+	doc := opmlDocument{Version: "2.0", Title: title, Body: opmlOutlines(roots)}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func opmlOutlines(nodes []*Node) []opmlOutline {
+	outlines := make([]opmlOutline, 0, len(nodes))
+	for _, n := range nodes {
+		if n.Folder {
+			outlines = append(outlines, opmlOutline{Text: n.Title, Children: opmlOutlines(n.Children)})
+			continue
+		}
+		outlines = append(outlines, opmlOutline{Text: n.Title, Type: "link", URL: n.URL})
+	}
+	return outlines
+}
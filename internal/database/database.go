@@ -16,17 +16,28 @@ var DB *gorm.DB
 func InitDB(dbPath string) error {
 	var err error
 	DB, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Silent),
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true, // 唯一约束冲突等错误转换为 gorm.ErrDuplicatedKey
 	})
 	if err != nil {
 		return err
 	}
 
+	if err := migrateBackupNameIndex(); err != nil {
+		return err
+	}
+
 	// 自动迁移
 	err = DB.AutoMigrate(
 		&models.User{},
 		&models.AccessKey{},
 		&models.Backup{},
+		&models.BackupVersion{},
+		&models.Blob{},
+		&models.Setting{},
+		&models.RetentionPolicy{},
+		&models.BackupGrant{},
+		&models.ShareLink{},
 		&models.SyncRecord{},
 	)
 	if err != nil {
@@ -37,6 +48,20 @@ func InitDB(dbPath string) error {
 	return nil
 }
 
+// migrateBackupNameIndex 备份名称由全局唯一改为同一用户下唯一：在 AutoMigrate 之前删除早期版本的全局唯一索引，
+// 随后由 AutoMigrate 创建 (user_id, name) 联合唯一索引；已有数据全局唯一，必然满足新索引
+func migrateBackupNameIndex() error {
+	migrator := DB.Migrator()
+	if !migrator.HasIndex(&models.Backup{}, "idx_backups_name") {
+		return nil
+	}
+	if err := migrator.DropIndex(&models.Backup{}, "idx_backups_name"); err != nil {
+		return err
+	}
+	log.Println("备份名称唯一索引已改为按用户唯一")
+	return nil
+}
+
 // GetDB 获取数据库实例
 func GetDB() *gorm.DB {
 	return DB
@@ -6,6 +6,7 @@ import (
 	"itab-backend/internal/auth"
 	"itab-backend/internal/database"
 	"itab-backend/internal/models"
+	"itab-backend/internal/quota"
 
 	"github.com/gin-gonic/gin"
 )
@@ -92,6 +93,12 @@ func GetCurrentUser(c *gin.Context) {
 		return
 	}
 
+	status, err := quota.StatusOf(&user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取存储配额失败"})
+		return
+	}
+
 	user.Password = "" // 不返回密码
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, userWithQuota{User: user, Quota: status})
 }
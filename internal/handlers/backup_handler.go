@@ -2,13 +2,19 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"itab-backend/internal/database"
 	"itab-backend/internal/models"
+	"itab-backend/internal/quota"
+	"itab-backend/internal/store"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // ListBackups 获取备份列表
@@ -17,7 +23,7 @@ func ListBackups(c *gin.Context) {
 	isAdmin := c.GetBool("is_admin")
 
 	var backups []models.Backup
-	query := database.DB.Preload("User").Select("id, name, size, sync_count, user_id, created_at, updated_at")
+	query := database.DB.Preload("User").Select("id, name, size, stored_size, encoding, sync_count, version, user_id, created_at, updated_at")
 
 	if !isAdmin {
 		query = query.Where("user_id = ?", userID)
@@ -53,11 +59,15 @@ func GetBackup(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "无权查看此备份"})
 		return
 	}
+	if err := store.Load(&backup); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取备份数据失败"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"data": backup})
 }
 
-// DeleteBackup 删除备份
+// DeleteBackup 将备份移至回收站
 func DeleteBackup(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -80,12 +90,12 @@ func DeleteBackup(c *gin.Context) {
 		return
 	}
 
-	if err := database.DB.Delete(&backup).Error; err != nil {
+	if err := store.Trash(database.DB, &backup); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除备份失败"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "备份删除成功"})
+	c.JSON(http.StatusOK, gin.H{"message": "备份已移至回收站"})
 }
 
 // DownloadBackup 下载备份数据
@@ -95,6 +105,10 @@ func DownloadBackup(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的备份ID"})
 		return
 	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
 
 	userID := c.GetUint("user_id")
 	isAdmin := c.GetBool("is_admin")
@@ -110,6 +124,14 @@ func DownloadBackup(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "无权下载此备份"})
 		return
 	}
+	if err := store.Load(&backup); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取备份数据失败"})
+		return
+	}
+	if format != nil {
+		writeBookmarkExport(c, &backup, format)
+		return
+	}
 
 	// 解析data为对象
 	var backupData interface{}
@@ -131,3 +153,261 @@ func DownloadBackup(c *gin.Context) {
 		"data":               backupData,
 	})
 }
+
+// maxBackupNameLength 备份名称的最大长度
+const maxBackupNameLength = 255
+
+// RenameBackupRequest 重命名备份请求
+type RenameBackupRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// DuplicateBackupRequest 复制备份请求
+type DuplicateBackupRequest struct {
+	Name        string `json:"name" binding:"required"`
+	WithHistory bool   `json:"with_history"` // 是否同时复制历史版本
+}
+
+// MoveBackupRequest 移动备份到其他用户请求
+type MoveBackupRequest struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
+
+// backupNameExists 用户下是否已存在同名备份，回收站中的备份同样占用名称
+func backupNameExists(userID uint, name string) (bool, error) {
+	var count int64
+	err := database.DB.Unscoped().Model(&models.Backup{}).Where("user_id = ? AND name = ?", userID, name).Count(&count).Error
+	return count > 0, err
+}
+
+// respondBackupNameConflict 返回备份名称冲突（409）
+func respondBackupNameConflict(c *gin.Context, name string) {
+	c.JSON(http.StatusConflict, gin.H{"error": "备份名称「" + name + "」已存在", "name": name})
+}
+
+// checkBackupName 去除首尾空白后校验用户下的备份名称，名称无效或已被占用时写入响应并返回 false
+func checkBackupName(c *gin.Context, userID uint, name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "备份名称不能为空"})
+		return "", false
+	}
+	if len(name) > maxBackupNameLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "备份名称过长"})
+		return "", false
+	}
+
+	exists, err := backupNameExists(userID, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查备份名称失败"})
+		return "", false
+	}
+	if exists {
+		respondBackupNameConflict(c, name)
+		return "", false
+	}
+	return name, true
+}
+
+// RenameBackup 重命名备份
+func RenameBackup(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	var req RenameBackupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if strings.TrimSpace(req.Name) == backup.Name {
+		c.JSON(http.StatusOK, gin.H{"message": "备份名称未变化", "data": gin.H{"backup_id": backup.ID, "name": backup.Name}})
+		return
+	}
+	name, ok := checkBackupName(c, backup.UserID, req.Name)
+	if !ok {
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	username, _ := c.Get("username")
+	oldName := backup.Name
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(backup).Update("name", name).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.SyncRecord{
+			BackupName: name,
+			TransType:  "rename",
+			UserID:     userID,
+			Detail:     "原名称「" + oldName + "」",
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		respondBackupNameConflict(c, name)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重命名备份失败"})
+		return
+	}
+
+	log.Printf("[备份] 用户 %s 将备份「%s」重命名为「%s」", username, oldName, name)
+	c.JSON(http.StatusOK, gin.H{"message": "备份重命名成功", "data": gin.H{"backup_id": backup.ID, "name": name}})
+}
+
+// DuplicateBackup 将备份复制为同一用户下的新备份，可选择同时复制历史版本
+func DuplicateBackup(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	var req DuplicateBackupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	name, ok := checkBackupName(c, backup.UserID, req.Name)
+	if !ok {
+		return
+	}
+
+	size := backup.Size
+	if req.WithHistory {
+		usage, err := quota.BackupUsage(backup)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "统计备份用量失败"})
+			return
+		}
+		size = usage
+	}
+	if !enforceQuota(c, backup.UserID, true, size) {
+		return
+	}
+
+	if !loadBackupData(c, backup) {
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	username, _ := c.Get("username")
+
+	var copied *models.Backup
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if copied, err = store.Copy(tx, backup, name, userID, req.WithHistory); err != nil {
+			return err
+		}
+		return tx.Create(&models.SyncRecord{
+			BackupName: name,
+			TransType:  "duplicate",
+			UserID:     userID,
+			Detail:     "复制自「" + backup.Name + "」",
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		respondBackupNameConflict(c, name)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "复制备份失败"})
+		return
+	}
+
+	log.Printf("[备份] 用户 %s 将备份「%s」复制为「%s」", username, backup.Name, name)
+	c.JSON(http.StatusOK, gin.H{"message": "备份复制成功", "data": gin.H{
+		"backup_id": copied.ID,
+		"name":      copied.Name,
+		"revision":  copied.Version,
+	}})
+}
+
+// MoveBackup 将备份连同历史版本移动到其他用户（管理员）
+func MoveBackup(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	var req MoveBackupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if req.UserID == backup.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "备份已属于该用户"})
+		return
+	}
+
+	var target models.User
+	if err := database.DB.First(&target, req.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "目标用户不存在"})
+		return
+	}
+
+	exists, err := backupNameExists(target.ID, backup.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查备份名称失败"})
+		return
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "目标用户已存在名为「" + backup.Name + "」的备份，请先重命名", "name": backup.Name})
+		return
+	}
+
+	usage, err := quota.BackupUsage(backup)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "统计备份用量失败"})
+		return
+	}
+	if !enforceQuota(c, target.ID, true, usage) {
+		return
+	}
+
+	var source models.User
+	database.DB.Select("id, username").First(&source, backup.UserID)
+	userID := c.GetUint("user_id")
+	username, _ := c.Get("username")
+
+	var revokedLinks int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(backup).Update("user_id", target.ID).Error; err != nil {
+			return err
+		}
+		// 新所有者不再需要共享授权
+		if err := tx.Where("backup_id = ? AND user_id = ?", backup.ID, target.ID).Delete(&models.BackupGrant{}).Error; err != nil {
+			return err
+		}
+		// 原所有者创建的分享链接不应继续公开新所有者的数据，一并撤销
+		result := tx.Where("backup_id = ?", backup.ID).Delete(&models.ShareLink{})
+		if result.Error != nil {
+			return result.Error
+		}
+		revokedLinks = result.RowsAffected
+		return tx.Create(&models.SyncRecord{
+			BackupName: backup.Name,
+			TransType:  "move",
+			UserID:     userID,
+			Detail:     "从用户「" + source.Username + "」移动到「" + target.Username + "」",
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		c.JSON(http.StatusConflict, gin.H{"error": "目标用户已存在名为「" + backup.Name + "」的备份，请先重命名", "name": backup.Name})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "移动备份失败"})
+		return
+	}
+
+	log.Printf("[备份] 管理员 %s 将备份「%s」从用户 %s 移动到 %s（撤销 %d 个分享链接）", username, backup.Name, source.Username, target.Username, revokedLinks)
+	c.JSON(http.StatusOK, gin.H{"message": "备份移动成功", "data": gin.H{
+		"backup_id":     backup.ID,
+		"name":          backup.Name,
+		"user_id":       target.ID,
+		"revoked_links": revokedLinks,
+	}})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMoveBackupRevokesShareLinks(t *testing.T) {
+	setupTestDB(t)
+	admin := createTestUser(t, "admin")
+	admin.IsAdmin = true
+	alice := createTestUser(t, "alice")
+	bob := createTestUser(t, "bob")
+	backup := createTestBackup(t, alice.ID, "home", testData(shortcut(1, "GitHub", "https://github.com")))
+	other := createTestBackup(t, alice.ID, "work", testData())
+
+	links := []models.ShareLink{
+		{Token: "moved-1", BackupID: backup.ID, UserID: alice.ID},
+		{Token: "moved-2", BackupID: backup.ID, UserID: alice.ID, Password: "secret"},
+		{Token: "kept", BackupID: other.ID, UserID: alice.ID},
+	}
+	if err := database.DB.Create(&links).Error; err != nil {
+		t.Fatalf("创建分享链接失败: %v", err)
+	}
+
+	id := strconv.FormatUint(uint64(backup.ID), 10)
+	c, w := newTestContext(admin, http.MethodPost, "/api/backups/"+id+"/move", MoveBackupRequest{UserID: bob.ID})
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	MoveBackup(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d，应为 200: %s", w.Code, w.Body.String())
+	}
+	data, _ := decodeResponse(t, w)["data"].(map[string]interface{})
+	if data["revoked_links"] != float64(2) {
+		t.Errorf("revoked_links = %v，应为 2", data["revoked_links"])
+	}
+
+	var tokens []string
+	database.DB.Model(&models.ShareLink{}).Order("token").Pluck("token", &tokens)
+	if len(tokens) != 1 || tokens[0] != "kept" {
+		t.Errorf("剩余分享链接 = %v，只应保留其他备份的链接", tokens)
+	}
+
+	var moved models.Backup
+	database.DB.First(&moved, backup.ID)
+	if moved.UserID != bob.ID {
+		t.Errorf("备份所有者 = %d，应为 %d", moved.UserID, bob.ID)
+	}
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"itab-backend/internal/backupdata"
+	"itab-backend/internal/bookmarks"
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxImportFileSize 导入的书签文件最大大小
+const maxImportFileSize = 64 << 20
+
+// readImportFile 读取表单中上传的书签文件（字段 file）并按 format 参数解析，失败时已写入响应
+// format 可为 html/chrome/firefox，为空时根据文件内容自动判断
+func readImportFile(c *gin.Context) ([]*bookmarks.Node, string, bool) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请上传书签文件"})
+		return nil, "", false
+	}
+	if header.Size > maxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "书签文件过大"})
+		return nil, "", false
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取书签文件失败"})
+		return nil, "", false
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取书签文件失败"})
+		return nil, "", false
+	}
+
+	format := c.DefaultPostForm("format", c.Query("format"))
+	nodes, err := bookmarks.Parse(strings.ToLower(format), data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, "", false
+	}
+	return nodes, header.Filename, true
+}
+
+// ImportBookmarks 将浏览器导出的书签文件导入为新备份
+// 表单字段：file 书签文件，name 新备份的名称，format 文件格式（默认 html）
+func ImportBookmarks(c *gin.Context) {
+	nodes, filename, ok := readImportFile(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	username, _ := c.Get("username")
+	name, ok := checkBackupName(c, userID, c.PostForm("name"))
+	if !ok {
+		return
+	}
+
+	bd := &models.BackupData{
+		Partitions:    []models.Partition{},
+		Folders:       []models.Folder{},
+		Shortcuts:     []models.Shortcut{},
+		SearchEngines: []models.SearchEngine{},
+	}
+	stats := bookmarks.Import(bd, nodes)
+	if stats.Shortcuts == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "书签文件中没有可导入的书签", "stats": stats})
+		return
+	}
+
+	data, err := json.Marshal(bd)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "序列化备份数据失败"})
+		return
+	}
+	if !enforceQuota(c, userID, true, int64(len(data))) {
+		return
+	}
+
+	backup := &models.Backup{
+		Name:   name,
+		Data:   string(data),
+		UserID: userID,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := store.Create(tx, backup, userID, 0, ""); err != nil {
+			return err
+		}
+		return tx.Create(&models.SyncRecord{
+			BackupName: name,
+			TransType:  "import",
+			UserID:     userID,
+			Detail:     "从「" + filename + "」导入",
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		respondBackupNameConflict(c, name)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建备份失败"})
+		return
+	}
+
+	log.Printf("[备份] 用户 %s 从「%s」导入了 %d 个书签，创建备份「%s」", username, filename, stats.Shortcuts, name)
+	c.JSON(http.StatusOK, gin.H{"message": "书签导入成功", "data": gin.H{
+		"backup_id": backup.ID,
+		"name":      backup.Name,
+		"revision":  backup.Version,
+		"stats":     stats,
+	}})
+}
+
+// ImportBookmarksInto 将浏览器导出的书签文件合并到已有备份，生成新版本
+// 同名的分区和文件夹会被复用，同一位置已有相同URL的书签会被跳过
+func ImportBookmarksInto(c *gin.Context) {
+	nodes, filename, ok := readImportFile(c)
+	if !ok {
+		return
+	}
+
+	editBackupDataAs(c, "import", "从「"+filename+"」导入", "导入书签", func(_ *models.Backup, bd *models.BackupData) (interface{}, error) {
+		return bookmarks.Import(bd, nodes), nil
+	})
+}
+
+// PreviewImportBookmarks 预览导入为新备份的结果：将创建的分区、文件夹、书签数量及跳过的书签，不写入任何数据
+func PreviewImportBookmarks(c *gin.Context) {
+	nodes, _, ok := readImportFile(c)
+	if !ok {
+		return
+	}
+	preview := bookmarks.PreviewImport(&models.BackupData{}, nodes)
+	c.JSON(http.StatusOK, gin.H{"data": preview})
+}
+
+// PreviewImportBookmarksInto 预览合并到已有备份的结果，包括因已存在而跳过的重复书签，不写入任何数据
+func PreviewImportBookmarksInto(c *gin.Context) {
+	nodes, _, ok := readImportFile(c)
+	if !ok {
+		return
+	}
+	backup, bd, ok := readBackupData(c)
+	if !ok {
+		return
+	}
+	preview := bookmarks.PreviewImport(bd, nodes)
+	c.JSON(http.StatusOK, gin.H{"data": preview, "revision": backup.Version})
+}
+
+// exportFormat 解析下载请求的 format 参数，为空或 json 时返回 nil 表示原始的备份格式，不支持时已写入响应
+func exportFormat(c *gin.Context) (*bookmarks.ExportFormat, bool) {
+	format := strings.ToLower(c.Query("format"))
+	if format == "" || format == "json" {
+		return nil, true
+	}
+	f, ok := bookmarks.LookupExport(format)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的导出格式: " + format})
+		return nil, false
+	}
+	return f, true
+}
+
+// writeBookmarkExport 将已读取数据的备份按书签格式输出为附件，include_private=true 时包含私密条目
+func writeBookmarkExport(c *gin.Context, backup *models.Backup, format *bookmarks.ExportFormat) {
+	bd, err := backupdata.Parse(backup.Data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "解析备份数据失败"})
+		return
+	}
+
+	var buf bytes.Buffer
+	opts := bookmarks.ExportOptions{
+		Title:          backup.Name,
+		IncludePrivate: c.Query("include_private") == "true",
+	}
+	if err := format.Write(&buf, bd, opts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出备份失败"})
+		return
+	}
+
+	filename := backup.Name + format.Ext
+	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	c.Data(http.StatusOK, format.ContentType, buf.Bytes())
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"itab-backend/internal/backupdata"
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// diffSide 差异比较的一侧
+type diffSide struct {
+	BackupID uint   `json:"backup_id"`
+	Name     string `json:"name"`
+	Version  int    `json:"version"`
+	data     string
+}
+
+// DiffBackup 比较备份的两个版本，或比较两个备份的当前数据
+// 查询参数：
+//   - from/to: 版本号，to 默认为当前数据，from 默认为 to 的上一个版本，to 为第一个版本时与空数据比较
+//   - compare: 另一个备份ID，指定后比较本备份与该备份的当前数据
+func DiffBackup(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	var from, to *diffSide
+
+	if compareParam := c.Query("compare"); compareParam != "" {
+		compareID, err := strconv.ParseUint(compareParam, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的对比备份ID"})
+			return
+		}
+
+		var other models.Backup
+		if err := database.DB.First(&other, compareID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "对比备份不存在"})
+			return
+		}
+		if !c.GetBool("is_admin") && other.UserID != c.GetUint("user_id") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "无权访问对比备份"})
+			return
+		}
+		if err := store.Load(&other); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取备份数据失败"})
+			return
+		}
+
+		if !loadBackupData(c, backup) {
+			return
+		}
+
+		from = &diffSide{BackupID: backup.ID, Name: backup.Name, Version: backup.Version, data: backup.Data}
+		to = &diffSide{BackupID: other.ID, Name: other.Name, Version: other.Version, data: other.Data}
+	} else {
+		if toParam := c.Query("to"); toParam != "" {
+			side, ok := loadVersionSide(c, backup, toParam)
+			if !ok {
+				return
+			}
+			to = side
+		} else {
+			// 只比较历史版本时不需要读取当前数据
+			if !loadBackupData(c, backup) {
+				return
+			}
+			to = &diffSide{BackupID: backup.ID, Name: backup.Name, Version: backup.Version, data: backup.Data}
+		}
+
+		fromParam := c.Query("from")
+		if fromParam == "" && to.Version <= 1 {
+			// 第一个版本没有上一个版本，与空数据比较，所有条目均为新增
+			from = &diffSide{BackupID: backup.ID, Name: backup.Name}
+		} else {
+			if fromParam == "" {
+				fromParam = strconv.Itoa(to.Version - 1)
+			}
+			side, ok := loadVersionSide(c, backup, fromParam)
+			if !ok {
+				return
+			}
+			from = side
+		}
+	}
+
+	fromData, err := backupdata.Parse(from.data)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "解析备份数据失败: " + err.Error()})
+		return
+	}
+	toData, err := backupdata.Parse(to.data)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "解析备份数据失败: " + err.Error()})
+		return
+	}
+
+	diff := backupdata.Compare(fromData, toData)
+	c.JSON(http.StatusOK, gin.H{
+		"from":  from,
+		"to":    to,
+		"empty": diff.Empty(),
+		"data":  diff,
+	})
+}
+
+// loadVersionSide 加载备份指定版本作为比较的一侧
+func loadVersionSide(c *gin.Context, backup *models.Backup, versionParam string) (*diffSide, bool) {
+	version, ok := findBackupVersion(c, backup.ID, versionParam)
+	if !ok {
+		return nil, false
+	}
+
+	return &diffSide{BackupID: backup.ID, Name: backup.Name, Version: version.Version, data: version.Data}, true
+}
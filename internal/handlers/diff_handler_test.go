@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 第一个版本没有上一个版本，省略 from 时与空数据比较
+func TestDiffFirstVersionAgainstEmpty(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestUser(t, "alice")
+	backup := createTestBackup(t, alice.ID, "home", testData(shortcut(1, "GitHub", "https://github.com"), shortcut(2, "Go", "https://go.dev")))
+	id := strconv.FormatUint(uint64(backup.ID), 10)
+
+	diff := func(query string) (int, map[string]interface{}) {
+		c, w := newTestContext(alice, http.MethodGet, "/api/backups/"+id+"/diff"+query, nil)
+		c.Params = gin.Params{{Key: "id", Value: id}}
+		DiffBackup(c)
+		if w.Code != http.StatusOK {
+			return w.Code, nil
+		}
+		return w.Code, decodeResponse(t, w)
+	}
+	added := func(resp map[string]interface{}) int {
+		shortcuts := resp["data"].(map[string]interface{})["shortcuts"].(map[string]interface{})
+		return len(shortcuts["added"].([]interface{}))
+	}
+
+	for _, query := range []string{"", "?to=1"} {
+		code, resp := diff(query)
+		if code != http.StatusOK {
+			t.Fatalf("diff%s 状态码 = %d，应为 200", query, code)
+		}
+		if v := resp["from"].(map[string]interface{})["version"]; v != float64(0) {
+			t.Errorf("diff%s from.version = %v，应为 0", query, v)
+		}
+		if v := resp["to"].(map[string]interface{})["version"]; v != float64(1) {
+			t.Errorf("diff%s to.version = %v，应为 1", query, v)
+		}
+		if n := added(resp); n != 2 || resp["empty"] != false {
+			t.Errorf("diff%s 新增书签数 = %d，应为 2", query, n)
+		}
+	}
+
+	// 指定不存在的版本仍返回 404
+	if code, _ := diff("?from=0"); code != http.StatusNotFound {
+		t.Errorf("from=0 状态码 = %d，应为 404", code)
+	}
+
+	saveTestRevision(t, backup, testData(shortcut(1, "GitHub", "https://github.com"), shortcut(2, "Go", "https://go.dev"), shortcut(3, "Docs", "https://pkg.go.dev")))
+	code, resp := diff("")
+	if code != http.StatusOK {
+		t.Fatalf("diff 状态码 = %d，应为 200", code)
+	}
+	if v := resp["from"].(map[string]interface{})["version"]; v != float64(1) {
+		t.Errorf("from.version = %v，应为上一版本 1", v)
+	}
+	if n := added(resp); n != 1 {
+		t.Errorf("新增书签数 = %d，应为 1", n)
+	}
+}
@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"itab-backend/internal/backupdata"
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/store"
+	"itab-backend/internal/vault"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// readBackupData 查找当前用户可访问的备份并解析其数据
+func readBackupData(c *gin.Context) (*models.Backup, *models.BackupData, bool) {
+	backup, ok := findBackupForUser(c)
+	if !ok || !loadBackupData(c, backup) {
+		return nil, nil, false
+	}
+
+	bd, err := backupdata.Parse(backup.Data)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "解析备份数据失败: " + err.Error()})
+		return nil, nil, false
+	}
+
+	return backup, bd, true
+}
+
+// editBackupData 在备份数据上执行一次条目修改，并像同步上传一样保存为新版本、记录同步记录
+// 支持可选的 If-Match 头做并发校验；edit 返回的结果会作为响应的 data 字段
+func editBackupData(c *gin.Context, action string, edit func(bd *models.BackupData) (interface{}, error)) {
+	editBackupDataAs(c, "edit", "", action, func(_ *models.Backup, bd *models.BackupData) (interface{}, error) {
+		return edit(bd)
+	})
+}
+
+// editBackupDataAs 同 editBackupData，可指定同步记录的类型和详情，edit 可读取备份本身的属性
+func editBackupDataAs(c *gin.Context, transType, detail, action string, edit func(backup *models.Backup, bd *models.BackupData) (interface{}, error)) {
+	backup, bd, ok := readBackupData(c)
+	if !ok {
+		return
+	}
+
+	if status := checkUploadPrecondition(backup, c.GetHeader("If-Match"), nil); status != 0 {
+		respondRevisionConflict(c, status, backup)
+		return
+	}
+
+	// 早期数据可能已有问题，修改只需不引入新问题
+	_, baseline := backupdata.Validate([]byte(backup.Data))
+
+	result, err := edit(backup, bd)
+	if err != nil {
+		respondEntityError(c, err)
+		return
+	}
+
+	data, err := json.Marshal(bd)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "序列化备份数据失败"})
+		return
+	}
+
+	// 修改结果与上传走同样的校验，避免条目修改和导入写入无效数据
+	warnings, ok := validateBackupData(c, data, http.StatusBadRequest, baseline)
+	if !ok {
+		return
+	}
+
+	if !enforceQuota(c, backup.UserID, false, int64(len(data))) {
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	username, _ := c.Get("username")
+
+	backup.Data = string(data)
+	backup.Size = int64(len(backup.Data))
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := store.SaveRevision(tx, backup, userID, 0, ""); err != nil {
+			return err
+		}
+		return tx.Create(&models.SyncRecord{
+			BackupName: backup.Name,
+			TransType:  transType,
+			Detail:     detail,
+			UserID:     userID,
+		}).Error
+	})
+	if errors.Is(err, store.ErrRevisionConflict) {
+		database.DB.First(backup, backup.ID)
+		respondRevisionConflict(c, http.StatusConflict, backup)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新备份失败"})
+		return
+	}
+
+	// 打印操作日志
+	log.Printf("[备份] 用户 %s %s（备份「%s」，新版本 %d）", username, action, backup.Name, backup.Version)
+
+	c.Header("ETag", backupETag(backup))
+	resp := gin.H{
+		"message":  "操作成功",
+		"revision": backup.Version,
+		"data":     result,
+	}
+	if len(warnings) > 0 {
+		resp["warnings"] = warnings
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// respondEntityError 将条目操作错误转换为HTTP应答
+func respondEntityError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, backupdata.ErrEntityNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, backupdata.ErrDuplicateID), errors.Is(err, backupdata.ErrNotEmpty):
+		status = http.StatusConflict
+	case errors.Is(err, backupdata.ErrInvalidReference), errors.Is(err, vault.ErrEncrypted):
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// entityID 解析路径中的条目ID
+func entityID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("eid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的条目ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// bindEntity 解析条目请求体，并返回 order/pinnedOrder 字段（未提供时为 nil，表示不指定位置）
+func bindEntity(c *gin.Context, v interface{}) (order, pinnedOrder *int, ok bool) {
+	body, err := c.GetRawData()
+	if err == nil {
+		err = json.Unmarshal(body, v)
+	}
+	var fields struct {
+		Order       *int `json:"order"`
+		PinnedOrder *int `json:"pinnedOrder"`
+	}
+	if err == nil {
+		err = json.Unmarshal(body, &fields)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return nil, nil, false
+	}
+	return fields.Order, fields.PinnedOrder, true
+}
+
+// ---------- 书签 ----------
+
+// ListShortcuts 获取备份中的书签列表
+func ListShortcuts(c *gin.Context) {
+	if _, bd, ok := readBackupData(c); ok {
+		c.JSON(http.StatusOK, gin.H{"data": bd.Shortcuts})
+	}
+}
+
+// GetShortcut 获取备份中的单个书签
+func GetShortcut(c *gin.Context) {
+	_, bd, ok := readBackupData(c)
+	if !ok {
+		return
+	}
+	id, ok := entityID(c)
+	if !ok {
+		return
+	}
+	i := backupdata.FindShortcut(bd, id)
+	if i < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "书签不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": bd.Shortcuts[i]})
+}
+
+// CreateShortcut 在备份中新增书签
+func CreateShortcut(c *gin.Context) {
+	var s models.Shortcut
+	order, pinnedOrder, ok := bindEntity(c, &s)
+	if !ok {
+		return
+	}
+	editBackupData(c, "新增了书签「"+s.Name+"」", func(bd *models.BackupData) (interface{}, error) {
+		return backupdata.AddShortcut(bd, s, order, pinnedOrder)
+	})
+}
+
+// UpdateShortcut 更新备份中的书签
+func UpdateShortcut(c *gin.Context) {
+	id, ok := entityID(c)
+	if !ok {
+		return
+	}
+	var s models.Shortcut
+	order, pinnedOrder, ok := bindEntity(c, &s)
+	if !ok {
+		return
+	}
+	editBackupData(c, "更新了书签「"+s.Name+"」", func(bd *models.BackupData) (interface{}, error) {
+		return backupdata.UpdateShortcut(bd, id, s, order, pinnedOrder)
+	})
+}
+
+// DeleteShortcut 删除备份中的书签
+func DeleteShortcut(c *gin.Context) {
+	id, ok := entityID(c)
+	if !ok {
+		return
+	}
+	editBackupData(c, "删除了书签 "+strconv.Itoa(id), func(bd *models.BackupData) (interface{}, error) {
+		return nil, backupdata.DeleteShortcut(bd, id)
+	})
+}
+
+// ---------- 文件夹 ----------
+
+// ListFolders 获取备份中的文件夹列表
+func ListFolders(c *gin.Context) {
+	if _, bd, ok := readBackupData(c); ok {
+		c.JSON(http.StatusOK, gin.H{"data": bd.Folders})
+	}
+}
+
+// GetFolder 获取备份中的单个文件夹
+func GetFolder(c *gin.Context) {
+	_, bd, ok := readBackupData(c)
+	if !ok {
+		return
+	}
+	id, ok := entityID(c)
+	if !ok {
+		return
+	}
+	i := backupdata.FindFolder(bd, id)
+	if i < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件夹不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": bd.Folders[i]})
+}
+
+// CreateFolder 在备份中新增文件夹
+func CreateFolder(c *gin.Context) {
+	var f models.Folder
+	order, _, ok := bindEntity(c, &f)
+	if !ok {
+		return
+	}
+	editBackupData(c, "新增了文件夹「"+f.Name+"」", func(bd *models.BackupData) (interface{}, error) {
+		return backupdata.AddFolder(bd, f, order)
+	})
+}
+
+// UpdateFolder 更新备份中的文件夹
+func UpdateFolder(c *gin.Context) {
+	id, ok := entityID(c)
+	if !ok {
+		return
+	}
+	var f models.Folder
+	order, _, ok := bindEntity(c, &f)
+	if !ok {
+		return
+	}
+	editBackupData(c, "更新了文件夹「"+f.Name+"」", func(bd *models.BackupData) (interface{}, error) {
+		return backupdata.UpdateFolder(bd, id, f, order)
+	})
+}
+
+// DeleteFolder 删除备份中的文件夹，cascade=true 时同时删除其中的书签
+func DeleteFolder(c *gin.Context) {
+	id, ok := entityID(c)
+	if !ok {
+		return
+	}
+	cascade := c.Query("cascade") == "true"
+	editBackupData(c, "删除了文件夹 "+strconv.Itoa(id), func(bd *models.BackupData) (interface{}, error) {
+		return nil, backupdata.DeleteFolder(bd, id, cascade)
+	})
+}
+
+// ---------- 分区 ----------
+
+// ListPartitions 获取备份中的分区列表
+func ListPartitions(c *gin.Context) {
+	if _, bd, ok := readBackupData(c); ok {
+		c.JSON(http.StatusOK, gin.H{"data": bd.Partitions})
+	}
+}
+
+// GetPartition 获取备份中的单个分区
+func GetPartition(c *gin.Context) {
+	_, bd, ok := readBackupData(c)
+	if !ok {
+		return
+	}
+	id, ok := entityID(c)
+	if !ok {
+		return
+	}
+	i := backupdata.FindPartition(bd, id)
+	if i < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分区不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": bd.Partitions[i]})
+}
+
+// CreatePartition 在备份中新增分区
+func CreatePartition(c *gin.Context) {
+	var p models.Partition
+	order, _, ok := bindEntity(c, &p)
+	if !ok {
+		return
+	}
+	editBackupData(c, "新增了分区「"+p.Name+"」", func(bd *models.BackupData) (interface{}, error) {
+		return backupdata.AddPartition(bd, p, order)
+	})
+}
+
+// UpdatePartition 更新备份中的分区
+func UpdatePartition(c *gin.Context) {
+	id, ok := entityID(c)
+	if !ok {
+		return
+	}
+	var p models.Partition
+	order, _, ok := bindEntity(c, &p)
+	if !ok {
+		return
+	}
+	editBackupData(c, "更新了分区「"+p.Name+"」", func(bd *models.BackupData) (interface{}, error) {
+		return backupdata.UpdatePartition(bd, id, p, order)
+	})
+}
+
+// DeletePartition 删除备份中的分区，cascade=true 时同时删除其中的文件夹和书签
+func DeletePartition(c *gin.Context) {
+	id, ok := entityID(c)
+	if !ok {
+		return
+	}
+	cascade := c.Query("cascade") == "true"
+	editBackupData(c, "删除了分区 "+strconv.Itoa(id), func(bd *models.BackupData) (interface{}, error) {
+		return nil, backupdata.DeletePartition(bd, id, cascade)
+	})
+}
+
+// ---------- 搜索引擎 ----------
+
+// ListSearchEngines 获取备份中的搜索引擎列表
+func ListSearchEngines(c *gin.Context) {
+	if _, bd, ok := readBackupData(c); ok {
+		c.JSON(http.StatusOK, gin.H{"data": bd.SearchEngines})
+	}
+}
+
+// GetSearchEngine 获取备份中的单个搜索引擎
+func GetSearchEngine(c *gin.Context) {
+	_, bd, ok := readBackupData(c)
+	if !ok {
+		return
+	}
+	id, ok := entityID(c)
+	if !ok {
+		return
+	}
+	i := backupdata.FindSearchEngine(bd, id)
+	if i < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "搜索引擎不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": bd.SearchEngines[i]})
+}
+
+// CreateSearchEngine 在备份中新增搜索引擎，可通过 position 查询参数指定插入位置
+func CreateSearchEngine(c *gin.Context) {
+	var e models.SearchEngine
+	if _, _, ok := bindEntity(c, &e); !ok {
+		return
+	}
+	var position *int
+	if p, err := strconv.Atoi(c.Query("position")); err == nil {
+		position = &p
+	}
+	editBackupData(c, "新增了搜索引擎「"+e.Name+"」", func(bd *models.BackupData) (interface{}, error) {
+		return backupdata.AddSearchEngine(bd, e, position)
+	})
+}
+
+// UpdateSearchEngine 更新备份中的搜索引擎
+func UpdateSearchEngine(c *gin.Context) {
+	id, ok := entityID(c)
+	if !ok {
+		return
+	}
+	var e models.SearchEngine
+	if _, _, ok := bindEntity(c, &e); !ok {
+		return
+	}
+	editBackupData(c, "更新了搜索引擎「"+e.Name+"」", func(bd *models.BackupData) (interface{}, error) {
+		return backupdata.UpdateSearchEngine(bd, id, e)
+	})
+}
+
+// DeleteSearchEngine 删除备份中的搜索引擎
+func DeleteSearchEngine(c *gin.Context) {
+	id, ok := entityID(c)
+	if !ok {
+		return
+	}
+	editBackupData(c, "删除了搜索引擎 "+strconv.Itoa(id), func(bd *models.BackupData) (interface{}, error) {
+		return nil, backupdata.DeleteSearchEngine(bd, id)
+	})
+}
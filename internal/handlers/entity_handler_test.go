@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupEntityBackup 创建带一个书签的备份
+func setupEntityBackup(t *testing.T) (*models.User, *models.Backup) {
+	t.Helper()
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	backup := createTestBackup(t, user.ID, "home", testData(shortcut(1, "GitHub", "https://github.com")))
+	return user, backup
+}
+
+func TestCreateShortcutRejectsInvalidData(t *testing.T) {
+	user, backup := setupEntityBackup(t)
+
+	c, w := newTestContext(user, http.MethodPost, "/api/backups/1/shortcuts", models.Shortcut{Name: "坏链接", URL: "not a url"})
+	c.Params = gin.Params{{Key: "id", Value: strconv.FormatUint(uint64(backup.ID), 10)}}
+	CreateShortcut(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("状态码 = %d，应为 400: %s", w.Code, w.Body.String())
+	}
+	resp := decodeResponse(t, w)
+	if issues, _ := resp["errors"].([]interface{}); len(issues) == 0 {
+		t.Errorf("应答应包含问题列表: %v", resp)
+	}
+
+	var stored models.Backup
+	database.DB.First(&stored, backup.ID)
+	if stored.Version != backup.Version {
+		t.Errorf("校验失败后版本 = %d，不应生成新版本", stored.Version)
+	}
+}
+
+func TestCreateShortcutLenientReturnsWarnings(t *testing.T) {
+	user, backup := setupEntityBackup(t)
+
+	c, w := newTestContext(user, http.MethodPost, "/api/backups/1/shortcuts?lenient=true", models.Shortcut{Name: "坏链接", URL: "not a url"})
+	c.Params = gin.Params{{Key: "id", Value: strconv.FormatUint(uint64(backup.ID), 10)}}
+	CreateShortcut(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d，应为 200: %s", w.Code, w.Body.String())
+	}
+	resp := decodeResponse(t, w)
+	if warnings, _ := resp["warnings"].([]interface{}); len(warnings) == 0 {
+		t.Errorf("宽松模式应返回警告: %v", resp)
+	}
+	if resp["revision"] != float64(backup.Version+1) {
+		t.Errorf("revision = %v，应生成新版本", resp["revision"])
+	}
+}
+
+func TestCreateShortcutValid(t *testing.T) {
+	user, backup := setupEntityBackup(t)
+
+	c, w := newTestContext(user, http.MethodPost, "/api/backups/1/shortcuts", models.Shortcut{Name: "Go", URL: "https://go.dev"})
+	c.Params = gin.Params{{Key: "id", Value: strconv.FormatUint(uint64(backup.ID), 10)}}
+	CreateShortcut(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d，应为 200: %s", w.Code, w.Body.String())
+	}
+	if _, ok := decodeResponse(t, w)["warnings"]; ok {
+		t.Errorf("合法数据不应返回警告")
+	}
+}
+
+// 早期备份已有问题时，无关的修改不受影响，只拒绝修改新引入的问题
+func TestEditPreInvalidBackupRejectsOnlyNewIssues(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	missing := 99
+	broken := shortcut(2, "断链", "https://example.com")
+	broken.FolderID = &missing
+	backup := createTestBackup(t, user.ID, "home", testData(
+		shortcut(1, "GitHub", "https://github.com"),
+		broken,
+		shortcut(3, "旧书签", "legacy"),
+		shortcut(1, "重复", "https://example.org"),
+	))
+	id := strconv.FormatUint(uint64(backup.ID), 10)
+
+	send := func(method, target, eid string, body interface{}, handler gin.HandlerFunc) (int, map[string]interface{}) {
+		t.Helper()
+		c, w := newTestContext(user, method, target, body)
+		c.Params = gin.Params{{Key: "id", Value: id}, {Key: "eid", Value: eid}}
+		handler(c)
+		return w.Code, decodeResponse(t, w)
+	}
+
+	// 新增合法书签：已有问题作为警告返回
+	code, resp := send(http.MethodPost, "/api/backups/"+id+"/shortcuts", "", models.Shortcut{Name: "Go", URL: "https://go.dev"}, CreateShortcut)
+	if code != http.StatusOK {
+		t.Fatalf("新增书签状态码 = %d，应为 200: %v", code, resp)
+	}
+	if warnings, _ := resp["warnings"].([]interface{}); len(warnings) != 3 {
+		t.Errorf("warnings = %v，应返回已有的 3 个问题", resp["warnings"])
+	}
+
+	// 修改有问题的条目本身但不引入新问题，问题说明中的名称随之变化
+	code, resp = send(http.MethodPut, "/api/backups/"+id+"/shortcuts/3", "3", models.Shortcut{Name: "旧书签（改名）", URL: "legacy"}, UpdateShortcut)
+	if code != http.StatusOK {
+		t.Fatalf("修改有问题的书签状态码 = %d，应为 200: %v", code, resp)
+	}
+
+	// 删除前面的条目使下标变化，已有问题不视为新问题
+	code, resp = send(http.MethodDelete, "/api/backups/"+id+"/shortcuts/1", "1", nil, DeleteShortcut)
+	if code != http.StatusOK {
+		t.Fatalf("删除书签状态码 = %d，应为 200: %v", code, resp)
+	}
+
+	// 引入新问题时仍然拒绝，且只列出新问题
+	code, resp = send(http.MethodPost, "/api/backups/"+id+"/shortcuts", "", models.Shortcut{Name: "坏链接", URL: "not a url"}, CreateShortcut)
+	if code != http.StatusBadRequest {
+		t.Fatalf("引入新问题状态码 = %d，应为 400: %v", code, resp)
+	}
+	issues, _ := resp["errors"].([]interface{})
+	if len(issues) != 1 {
+		t.Fatalf("errors = %v，应只包含新引入的问题", resp["errors"])
+	}
+	if issue, _ := issues[0].(map[string]interface{}); issue["code"] != "invalid_url" {
+		t.Errorf("新问题 = %v，应为 invalid_url", issue)
+	}
+
+	var stored models.Backup
+	database.DB.First(&stored, backup.ID)
+	if stored.Version != backup.Version+3 {
+		t.Errorf("版本 = %d，应为 %d", stored.Version, backup.Version+3)
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"itab-backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FsckRequest 备份检查请求
+type FsckRequest struct {
+	Fix bool `json:"fix"` // 是否就地修复
+}
+
+// FsckBackups 检查所有备份的数据一致性，可选就地修复（仅管理员）
+func FsckBackups(c *gin.Context) {
+	var req FsckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		req.Fix = false // 未提供请求体时只检查
+	}
+
+	reports, err := store.Fsck(req.Fix)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查备份失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"fix":   req.Fix,
+		"total": len(reports),
+		"data":  reports,
+	})
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// permissionOwner 同步列表中自己的备份的权限
+const permissionOwner = "owner"
+
+// SetBackupGrantRequest 共享备份请求
+type SetBackupGrantRequest struct {
+	Username   string `json:"username" binding:"required"`   // 被授权的用户名
+	Permission string `json:"permission" binding:"required"` // read/write
+}
+
+// grantPermission 获取用户对备份的共享权限，未共享时返回空字符串
+func grantPermission(backupID, userID uint) (string, error) {
+	var grants []models.BackupGrant
+	if err := database.DB.Select("permission").Where("backup_id = ? AND user_id = ?", backupID, userID).
+		Limit(1).Find(&grants).Error; err != nil {
+		return "", err
+	}
+	if len(grants) == 0 {
+		return "", nil
+	}
+	return grants[0].Permission, nil
+}
+
+// requireGrant 校验当前用户对他人备份的共享权限，need 为 read 或 write，失败时已写入响应
+// 未共享时返回 404，避免泄露备份是否存在
+func requireGrant(c *gin.Context, backup *models.Backup, need string) bool {
+	permission, err := grantPermission(backup.ID, c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查共享权限失败"})
+		return false
+	}
+	if permission == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "备份不存在"})
+		return false
+	}
+	if need == models.GrantWrite && permission != models.GrantWrite {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有此备份的只读权限"})
+		return false
+	}
+	return true
+}
+
+// resolveSyncOwner 解析同步请求指定的备份所有者用户名，为空时为当前用户，失败时已写入响应
+func resolveSyncOwner(c *gin.Context, owner string) (uint, bool) {
+	userID := c.GetUint("user_id")
+	if username, _ := c.Get("username"); owner == "" || owner == username {
+		return userID, true
+	}
+
+	var user models.User
+	if err := database.DB.Select("id").Where("username = ?", owner).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "备份不存在"})
+		return 0, false
+	}
+	return user.ID, true
+}
+
+// ListBackupGrants 获取备份的共享授权列表
+func ListBackupGrants(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	var grants []models.BackupGrant
+	err := database.DB.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, username")
+	}).Where("backup_id = ?", backup.ID).Order("id").Find(&grants).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取共享列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": grants})
+}
+
+// SetBackupGrant 将备份共享给其他用户，已共享时修改权限
+func SetBackupGrant(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	var req SetBackupGrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if req.Permission != models.GrantRead && req.Permission != models.GrantWrite {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的权限，只能为 read 或 write"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Select("id, username").Where("username = ?", req.Username).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if user.ID == backup.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能共享给备份的所有者"})
+		return
+	}
+
+	grant := &models.BackupGrant{
+		BackupID:   backup.ID,
+		UserID:     user.ID,
+		Permission: req.Permission,
+	}
+	err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "backup_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"permission", "updated_at"}),
+	}).Create(grant).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "共享备份失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "备份共享成功", "data": gin.H{
+		"backup_id":  backup.ID,
+		"user_id":    user.ID,
+		"username":   user.Username,
+		"permission": req.Permission,
+	}})
+}
+
+// DeleteBackupGrant 取消对指定用户的共享
+func DeleteBackupGrant(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	uid, err := strconv.ParseUint(c.Param("uid"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	result := database.DB.Where("backup_id = ? AND user_id = ?", backup.ID, uid).Delete(&models.BackupGrant{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "取消共享失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未共享给该用户"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已取消共享"})
+}
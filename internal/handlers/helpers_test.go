@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// setupTestDB 为每个测试初始化独立的临时数据库
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	if err := database.InitDB(filepath.Join(t.TempDir(), "itab.db")); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+// createTestUser 创建测试用户
+func createTestUser(t *testing.T, username string) *models.User {
+	t.Helper()
+	user := &models.User{Username: username, Password: "secret"}
+	if err := database.DB.Create(user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	return user
+}
+
+// testData 生成包含指定书签的备份数据
+func testData(shortcuts ...models.Shortcut) *models.BackupData {
+	if shortcuts == nil {
+		shortcuts = []models.Shortcut{}
+	}
+	return &models.BackupData{
+		Partitions:    []models.Partition{},
+		Folders:       []models.Folder{},
+		Shortcuts:     shortcuts,
+		SearchEngines: []models.SearchEngine{},
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	return string(data)
+}
+
+// createTestBackup 创建备份并返回已加载数据的记录
+func createTestBackup(t *testing.T, userID uint, name string, bd *models.BackupData) *models.Backup {
+	t.Helper()
+	backup := &models.Backup{Name: name, Data: mustJSON(t, bd), UserID: userID, PasswordsEncrypted: false}
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return store.Create(tx, backup, userID, 0, "")
+	}); err != nil {
+		t.Fatalf("创建备份失败: %v", err)
+	}
+	return backup
+}
+
+// saveTestRevision 将备份数据保存为新版本
+func saveTestRevision(t *testing.T, backup *models.Backup, bd *models.BackupData) {
+	t.Helper()
+	backup.Data = mustJSON(t, bd)
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return store.SaveRevision(tx, backup, backup.UserID, 0, "")
+	}); err != nil {
+		t.Fatalf("保存版本失败: %v", err)
+	}
+}
+
+// newTestContext 构造以指定用户身份发起的请求上下文
+func newTestContext(user *models.User, method, target string, body interface{}) (*gin.Context, *httptest.ResponseRecorder) {
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, reader)
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", user.ID)
+	c.Set("username", user.Username)
+	c.Set("is_admin", user.IsAdmin)
+	c.Set("access_key_id", uint(0))
+	c.Set("access_key", "")
+	return c, w
+}
+
+// decodeResponse 解析 JSON 应答
+func decodeResponse(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析应答失败: %v（%s）", err, w.Body.String())
+	}
+	return resp
+}
+
+func shortcut(id int, name, url string) models.Shortcut {
+	return models.Shortcut{ID: id, Name: name, URL: url, Order: id}
+}
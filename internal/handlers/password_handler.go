@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"itab-backend/internal/models"
+	"itab-backend/internal/vault"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPasswordFileSize 导入的密码文件最大大小
+const maxPasswordFileSize = 16 << 20
+
+// ExportPasswords 将备份中的密码条目导出为密码管理器的导入文件
+// format 可为 bitwarden-json/bitwarden-csv/keepass-csv；服务端无法解密已加密的密码，含有已加密条目时拒绝导出，
+// skip_encrypted=true 时只导出未加密的条目
+func ExportPasswords(c *gin.Context) {
+	format := strings.ToLower(c.Query("format"))
+	f, ok := vault.Lookup(format)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的导出格式: " + format})
+		return
+	}
+
+	backup, bd, ok := readBackupData(c)
+	if !ok {
+		return
+	}
+
+	entries, encrypted := vault.Plaintext(backup.PasswordsEncrypted, bd.Passwords)
+	if encrypted > 0 && (len(entries) == 0 || c.Query("skip_encrypted") != "true") {
+		msg := fmt.Sprintf("有 %d 个密码条目已加密，服务端无法导出明文，可使用 skip_encrypted=true 只导出未加密的条目", encrypted)
+		if len(entries) == 0 {
+			msg = "备份中的密码已加密，服务端无法导出明文，请在客户端中导出"
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": msg, "encrypted": encrypted})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf, entries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出密码失败"})
+		return
+	}
+
+	username, _ := c.Get("username")
+	log.Printf("[备份] 用户 %s 导出了备份「%s」的 %d 个密码条目（%s）", username, backup.Name, len(entries), format)
+
+	filename := backup.Name + "-passwords" + f.Ext
+	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Skipped-Encrypted", fmt.Sprint(encrypted))
+	c.Data(http.StatusOK, f.ContentType, buf.Bytes())
+}
+
+// ImportPasswords 将密码管理器导出的文件合并到备份的密码条目，生成新版本
+// 表单字段：file 密码文件，format 文件格式（为空时自动判断）；备份的密码已加密时拒绝导入明文
+func ImportPasswords(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请上传密码文件"})
+		return
+	}
+	if header.Size > maxPasswordFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "密码文件过大"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取密码文件失败"})
+		return
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取密码文件失败"})
+		return
+	}
+
+	format := strings.ToLower(c.DefaultPostForm("format", c.Query("format")))
+	entries, skipped, err := vault.Parse(format, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	editBackupDataAs(c, "import", "从「"+header.Filename+"」导入密码", "导入密码", func(backup *models.Backup, bd *models.BackupData) (interface{}, error) {
+		stats, err := vault.Import(bd, backup.PasswordsEncrypted, entries)
+		stats.Skipped += skipped
+		return stats, err
+	})
+}
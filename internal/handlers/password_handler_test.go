@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"itab-backend/internal/models"
+	"itab-backend/internal/vault"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestExportPasswordsWithEncryptedEntries(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	bd := testData()
+	bd.Passwords = []models.Password{
+		{ID: 1, Name: "GitHub", URL: "https://github.com", Username: "alice", Password: "secret", CreatedAt: 1700000000000},
+		{ID: 2, Name: "Bank", Password: "U2FsdGVkX1+cipher", Encrypted: true, CreatedAt: 1700000000000},
+	}
+	backup := createTestBackup(t, user.ID, "home", bd)
+	id := strconv.FormatUint(uint64(backup.ID), 10)
+
+	export := func(query string) (int, http.Header, []byte) {
+		c, w := newTestContext(user, http.MethodGet, "/api/backups/"+id+"/passwords/export?"+query, nil)
+		c.Params = gin.Params{{Key: "id", Value: id}}
+		ExportPasswords(c)
+		return w.Code, w.Header(), w.Body.Bytes()
+	}
+
+	if code, _, body := export("format=bitwarden-json"); code != http.StatusUnprocessableEntity {
+		t.Fatalf("含有已加密条目时状态码 = %d，应为 422: %s", code, body)
+	}
+
+	code, header, body := export("format=bitwarden-json&skip_encrypted=true")
+	if code != http.StatusOK {
+		t.Fatalf("状态码 = %d，应为 200: %s", code, body)
+	}
+	if got := header.Get("X-Skipped-Encrypted"); got != "1" {
+		t.Errorf("X-Skipped-Encrypted = %q，应为 1", got)
+	}
+	entries, _, err := vault.Parse("", body)
+	if err != nil {
+		t.Fatalf("解析导出文件失败: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name != "GitHub" || entries[0].Password != "secret" {
+		t.Errorf("导出的条目 = %+v，只应包含明文条目", entries)
+	}
+}
+
+func TestExportPasswordsEncryptedBackup(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	bd := testData()
+	bd.Passwords = []models.Password{{ID: 1, Name: "GitHub", Password: "cipher"}}
+	backup := createTestBackup(t, user.ID, "home", bd)
+	backup.PasswordsEncrypted = true
+	saveTestRevision(t, backup, bd)
+	id := strconv.FormatUint(uint64(backup.ID), 10)
+
+	c, w := newTestContext(user, http.MethodGet, "/api/backups/"+id+"/passwords/export?format=keepass-csv&skip_encrypted=true", nil)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	ExportPasswords(c)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("备份整体加密时状态码 = %d，应为 422: %s", w.Code, w.Body.String())
+	}
+}
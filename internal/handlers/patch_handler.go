@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/jsonpatch"
+	"itab-backend/internal/models"
+	"itab-backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SyncPatch 以 JSON Patch（RFC 6902）增量更新备份数据（远程同步接口）
+// 必须通过 If-Match 头或 base_revision 查询参数指定补丁所基于的版本
+func SyncPatch(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	username, _ := c.Get("username")
+	accessKeyID := c.GetUint("access_key_id")
+	accessKey, _ := c.Get("access_key")
+	name := c.Param("name")
+
+	ownerID, ok := resolveSyncOwner(c, c.Query("owner"))
+	if !ok {
+		return
+	}
+
+	var backup models.Backup
+	if err := database.DB.Where("name = ? AND user_id = ?", name, ownerID).First(&backup).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "备份不存在"})
+		return
+	}
+	// 他人的备份需要共享的写入权限
+	if ownerID != userID && !requireGrant(c, &backup, models.GrantWrite) {
+		return
+	}
+	if err := store.Load(&backup); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取备份数据失败"})
+		return
+	}
+
+	// 补丁只能应用到确定的版本上
+	ifMatch := c.GetHeader("If-Match")
+	var baseRevision *int
+	if param := c.Query("base_revision"); param != "" {
+		v, err := strconv.Atoi(param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的版本号"})
+			return
+		}
+		baseRevision = &v
+	}
+	if ifMatch == "" && baseRevision == nil {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "请通过 If-Match 头或 base_revision 参数指定补丁所基于的版本"})
+		return
+	}
+	if status := checkUploadPrecondition(&backup, ifMatch, baseRevision); status != 0 {
+		respondRevisionConflict(c, status, &backup)
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取请求体失败"})
+		return
+	}
+	patch, err := jsonpatch.Decode(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	current := backup.Data
+	if current == "" {
+		current = "{}"
+	}
+	patched, err := patch.Apply([]byte(current))
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "应用补丁失败: " + err.Error()})
+		return
+	}
+
+	// 补丁结果必须仍是合法的备份数据
+	warnings, ok := validateUploadData(c, patched)
+	if !ok {
+		return
+	}
+
+	if store.Unchanged(&backup, string(patched)) {
+		// 补丁未改变内容，不产生新版本
+		c.Header("ETag", backupETag(&backup))
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "备份内容未变化",
+			"backup_id": backup.ID,
+			"revision":  backup.Version,
+			"size":      backup.Size,
+			"unchanged": true,
+		})
+		return
+	}
+
+	if !enforceQuota(c, backup.UserID, false, int64(len(patched))) {
+		return
+	}
+
+	backup.Data = string(patched)
+	backup.Size = int64(len(backup.Data))
+	backup.SyncCount++
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := store.SaveRevision(tx, &backup, userID, accessKeyID, accessKey.(string)); err != nil {
+			return err
+		}
+		return tx.Create(&models.SyncRecord{
+			BackupName:  backup.Name,
+			TransType:   "patch",
+			AccessKeyID: accessKeyID,
+			AccessKey:   accessKey.(string),
+			UserID:      userID,
+		}).Error
+	})
+	if errors.Is(err, store.ErrRevisionConflict) {
+		database.DB.First(&backup, backup.ID)
+		respondRevisionConflict(c, http.StatusConflict, &backup)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新备份失败"})
+		return
+	}
+
+	// 打印操作日志
+	log.Printf("[同步] 用户 %s 使用密钥 %s 以补丁方式更新了备份「%s」（%d 个操作）", username, accessKey, backup.Name, len(patch))
+
+	resp := gin.H{
+		"message":   "备份更新成功",
+		"backup_id": backup.ID,
+		"revision":  backup.Version,
+		"size":      backup.Size,
+	}
+	if len(warnings) > 0 {
+		resp["warnings"] = warnings
+	}
+	c.Header("ETag", backupETag(&backup))
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+
+	"itab-backend/internal/quota"
+
+	"github.com/gin-gonic/gin"
+)
+
+// enforceQuota 检查用户写入 size 字节新数据是否超出配额，超出时返回 413 并返回 false
+func enforceQuota(c *gin.Context, userID uint, newBackup bool, size int64) bool {
+	exceeded, err := quota.Check(userID, newBackup, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查存储配额失败"})
+		return false
+	}
+	if exceeded != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "超出存储配额：" + exceeded.Message,
+			"quota": exceeded,
+		})
+		return false
+	}
+	return true
+}
+
+// GetDefaultQuota 获取默认存储配额
+func GetDefaultQuota(c *gin.Context) {
+	limits, err := quota.Default()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取默认配额失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": limits})
+}
+
+// UpdateDefaultQuota 设置默认存储配额，0 表示不限制
+func UpdateDefaultQuota(c *gin.Context) {
+	var limits quota.Limits
+	if err := c.ShouldBindJSON(&limits); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if limits.MaxBytes < 0 || limits.MaxBackups < 0 || limits.MaxBackupSize < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "配额不能为负数"})
+		return
+	}
+
+	if err := quota.SetDefault(limits); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "设置默认配额失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "默认配额已更新", "data": limits})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"itab-backend/internal/retention"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDefaultRetention 获取全局历史版本保留策略
+func GetDefaultRetention(c *gin.Context) {
+	policy, err := retention.Default()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取保留策略失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": policy})
+}
+
+// UpdateDefaultRetention 设置全局历史版本保留策略，全部为 0 表示保留所有版本
+func UpdateDefaultRetention(c *gin.Context) {
+	var policy retention.Policy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if err := policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := retention.SetDefault(policy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "设置保留策略失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "保留策略已更新", "data": policy})
+}
+
+// GetBackupRetention 获取备份生效的保留策略
+func GetBackupRetention(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	policy, source, err := retention.ForBackup(backup.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取保留策略失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"backup_id": backup.ID,
+		"policy":    policy,
+		"source":    source,
+	}})
+}
+
+// UpdateBackupRetention 为备份单独设置保留策略
+func UpdateBackupRetention(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	var policy retention.Policy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if err := policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := retention.SetForBackup(backup.ID, policy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "设置保留策略失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "保留策略已更新", "data": gin.H{
+		"backup_id": backup.ID,
+		"policy":    policy,
+		"source":    retention.SourceBackup,
+	}})
+}
+
+// DeleteBackupRetention 删除备份单独设置的保留策略，恢复使用全局策略
+func DeleteBackupRetention(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	if err := retention.ClearForBackup(backup.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除保留策略失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已恢复使用全局保留策略"})
+}
+
+// PreviewRetention 预览按保留策略将被删除的历史版本，不会实际删除
+func PreviewRetention(c *gin.Context) {
+	var backupID uint
+	if s := c.Query("backup_id"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的备份ID"})
+			return
+		}
+		backupID = uint(id)
+	}
+
+	plans, err := retention.Run(backupID, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "计算清理计划失败"})
+		return
+	}
+
+	total := 0
+	for _, p := range plans {
+		total += len(p.Delete)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": plans, "total": total})
+}
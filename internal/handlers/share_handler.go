@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"itab-backend/internal/auth"
+	"itab-backend/internal/backupdata"
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// shareTokenLength 分享链接令牌的长度
+const shareTokenLength = 32
+
+// CreateShareLinkRequest 创建分享链接请求
+type CreateShareLinkRequest struct {
+	PartitionID int    `json:"partition_id" binding:"required"`
+	Password    string `json:"password"`    // 访问密码，为空表示无需密码
+	ExpireDays  int    `json:"expire_days"` // 0表示永久
+}
+
+// shareError 打开分享链接失败的原因
+type shareError struct {
+	status           int
+	message          string
+	passwordRequired bool
+}
+
+// ListShareLinks 获取备份的分享链接列表
+func ListShareLinks(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	var links []models.ShareLink
+	if err := database.DB.Where("backup_id = ?", backup.ID).Order("id").Find(&links).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取分享链接失败"})
+		return
+	}
+	for i := range links {
+		links[i].HasPassword = links[i].Password != ""
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": links})
+}
+
+// CreateShareLink 为备份中的分区创建公开分享链接，私密分区不能分享
+func CreateShareLink(c *gin.Context) {
+	backup, bd, ok := readBackupData(c)
+	if !ok {
+		return
+	}
+
+	var req CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if req.ExpireDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "有效天数不能为负数"})
+		return
+	}
+
+	if _, err := backupdata.SharePartition(bd, req.PartitionID); err != nil {
+		switch {
+		case errors.Is(err, backupdata.ErrEntityNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "分区不存在"})
+		case errors.Is(err, backupdata.ErrPrivatePartition):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建分享链接失败"})
+		}
+		return
+	}
+
+	link := &models.ShareLink{
+		Token:       auth.GenerateRandomString(shareTokenLength),
+		BackupID:    backup.ID,
+		PartitionID: req.PartitionID,
+		Password:    req.Password,
+		HasPassword: req.Password != "",
+		UserID:      c.GetUint("user_id"),
+	}
+	if req.ExpireDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpireDays)
+		link.ExpiresAt = &expiresAt
+	}
+
+	if err := database.DB.Create(link).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建分享链接失败"})
+		return
+	}
+
+	log.Printf("[分享] 用户 %d 分享了备份 %d 的分区 %d", link.UserID, backup.ID, req.PartitionID)
+	c.JSON(http.StatusOK, gin.H{"message": "分享链接创建成功", "data": link})
+}
+
+// DeleteShareLink 删除分享链接，删除后链接立即失效
+func DeleteShareLink(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	lid, err := strconv.ParseUint(c.Param("lid"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分享链接ID"})
+		return
+	}
+
+	result := database.DB.Where("id = ? AND backup_id = ?", lid, backup.ID).Delete(&models.ShareLink{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除分享链接失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分享链接不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "分享链接已删除"})
+}
+
+// openShare 校验分享链接并读取分享的分区内容
+// 每次访问都重新检查分区，分区被删除或改为私密、备份被移入回收站后链接随即失效
+func openShare(token, password string) (*models.ShareLink, *backupdata.SharedPartition, *shareError) {
+	var link models.ShareLink
+	if err := database.DB.Where("token = ?", token).First(&link).Error; err != nil {
+		return nil, nil, &shareError{status: http.StatusNotFound, message: "分享链接不存在"}
+	}
+	if link.ExpiresAt != nil && link.ExpiresAt.Before(time.Now()) {
+		return nil, nil, &shareError{status: http.StatusGone, message: "分享链接已过期"}
+	}
+	if link.Password != "" && subtle.ConstantTimeCompare([]byte(link.Password), []byte(password)) != 1 {
+		message := "请输入访问密码"
+		if password != "" {
+			message = "访问密码错误"
+		}
+		return nil, nil, &shareError{status: http.StatusUnauthorized, message: message, passwordRequired: true}
+	}
+
+	var backup models.Backup
+	if err := database.DB.First(&backup, link.BackupID).Error; err != nil {
+		return nil, nil, &shareError{status: http.StatusNotFound, message: "分享的内容不存在"}
+	}
+	if err := store.Load(&backup); err != nil {
+		return nil, nil, &shareError{status: http.StatusInternalServerError, message: "读取分享内容失败"}
+	}
+	bd, err := backupdata.Parse(backup.Data)
+	if err != nil {
+		return nil, nil, &shareError{status: http.StatusInternalServerError, message: "读取分享内容失败"}
+	}
+	shared, err := backupdata.SharePartition(bd, link.PartitionID)
+	if err != nil {
+		return nil, nil, &shareError{status: http.StatusNotFound, message: "分享的内容不存在"}
+	}
+
+	database.DB.Model(&link).UpdateColumn("view_count", gorm.Expr("view_count + 1"))
+	return &link, shared, nil
+}
+
+// GetSharedPartition 以JSON形式获取分享的分区（公开接口），访问密码通过 X-Share-Password 头传递
+func GetSharedPartition(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	link, shared, serr := openShare(c.Param("token"), c.GetHeader("X-Share-Password"))
+	if serr != nil {
+		body := gin.H{"error": serr.message}
+		if serr.passwordRequired {
+			body["password_required"] = true
+		}
+		c.JSON(serr.status, body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": shared, "expires_at": link.ExpiresAt})
+}
+
+// ViewSharedPartition 以网页形式展示分享的分区（公开接口），需要密码时显示密码表单，表单以 POST 提交
+func ViewSharedPartition(c *gin.Context) {
+	// 避免点击书签时通过 Referer 泄露分享链接，并且不让搜索引擎收录
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+	c.Header("Cache-Control", "no-store")
+
+	page := sharePage{Title: "书签分享"}
+	password := c.PostForm("password")
+	link, shared, serr := openShare(c.Param("token"), password)
+	status := http.StatusOK
+	if serr != nil {
+		status = serr.status
+		page.PasswordRequired = serr.passwordRequired
+		// 首次打开需要密码的链接时只显示密码表单，不显示错误
+		if !serr.passwordRequired || password != "" {
+			page.Error = serr.message
+		}
+	} else {
+		page.Title = shared.Name
+		page.Partition = shared
+		page.ExpiresAt = link.ExpiresAt
+	}
+
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := sharePageTemplate.Execute(c.Writer, page); err != nil {
+		log.Printf("[分享] 渲染分享页面失败: %v", err)
+	}
+}
+
+// sharePage 分享页面的模板数据
+type sharePage struct {
+	Title            string
+	Error            string
+	PasswordRequired bool
+	Partition        *backupdata.SharedPartition
+	ExpiresAt        *time.Time
+}
+
+// isShareImageSrc 判断图标是否为可在分享页面中显示的图片地址，只允许 http(s) 和 data:image 地址
+func isShareImageSrc(src string) bool {
+	lower := strings.ToLower(src)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "data:image/")
+}
+
+// shareIconSrc 书签图标的图片地址：优先使用 iconUrl，其次是图片形式的 icon
+func shareIconSrc(s backupdata.SharedShortcut) template.URL {
+	for _, src := range []string{s.IconURL, s.Icon} {
+		if isShareImageSrc(src) {
+			return template.URL(src)
+		}
+	}
+	return ""
+}
+
+// shareIconText 没有图片图标时显示的文字：文字形式的 icon，或名称的首字
+func shareIconText(s backupdata.SharedShortcut) string {
+	if s.Icon != "" && !isShareImageSrc(s.Icon) && utf8.RuneCountInString(s.Icon) <= 2 {
+		return s.Icon
+	}
+	r, _ := utf8.DecodeRuneInString(strings.TrimSpace(s.Name))
+	if r == utf8.RuneError {
+		return "?"
+	}
+	return strings.ToUpper(string(r))
+}
+
+var sharePageTemplate = template.Must(template.New("share").Funcs(template.FuncMap{
+	"iconSrc":  shareIconSrc,
+	"iconText": shareIconText,
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex, nofollow">
+<title>{{.Title}} - iTab</title>
+<style>
+body { margin: 0; font-family: -apple-system, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; background: #f5f6f8; color: #333; }
+main { max-width: 960px; margin: 0 auto; padding: 32px 16px; }
+h1 { font-size: 24px; margin: 0 0 4px; }
+h2 { font-size: 16px; margin: 24px 0 12px; color: #555; }
+.meta { color: #999; font-size: 13px; }
+.grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(96px, 1fr)); gap: 12px; }
+.item { display: flex; flex-direction: column; align-items: center; padding: 12px 6px; border-radius: 10px; color: inherit; text-decoration: none; background: #fff; box-shadow: 0 1px 3px rgba(0,0,0,.06); }
+.item:hover { box-shadow: 0 2px 8px rgba(0,0,0,.12); }
+.icon { width: 48px; height: 48px; border-radius: 12px; object-fit: contain; }
+.text-icon { display: flex; align-items: center; justify-content: center; background: #4a90e2; color: #fff; font-size: 22px; }
+.name { margin-top: 8px; font-size: 13px; max-width: 100%; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+.empty { color: #999; }
+.card { max-width: 360px; margin: 80px auto; padding: 24px; background: #fff; border-radius: 10px; box-shadow: 0 1px 3px rgba(0,0,0,.06); text-align: center; }
+.card input { width: 100%; box-sizing: border-box; padding: 8px; margin: 12px 0; border: 1px solid #ddd; border-radius: 6px; }
+.card button { padding: 8px 24px; border: 0; border-radius: 6px; background: #4a90e2; color: #fff; cursor: pointer; }
+.error { color: #e74c3c; }
+</style>
+</head>
+<body>
+<main>
+{{- if .Partition}}
+<h1>{{.Partition.Name}}</h1>
+<div class="meta">{{if .ExpiresAt}}链接有效期至 {{.ExpiresAt.Format "2006-01-02 15:04"}}{{else}}永久有效的分享链接{{end}}</div>
+{{- if .Partition.Shortcuts}}
+<div class="grid" style="margin-top: 20px">{{range .Partition.Shortcuts}}{{template "shortcut" .}}{{end}}</div>
+{{- end}}
+{{- range .Partition.Folders}}
+<h2>{{.Name}}</h2>
+{{- if .Shortcuts}}
+<div class="grid">{{range .Shortcuts}}{{template "shortcut" .}}{{end}}</div>
+{{- else}}
+<div class="empty">空文件夹</div>
+{{- end}}
+{{- end}}
+{{- if and (not .Partition.Shortcuts) (not .Partition.Folders)}}
+<p class="empty">此分区中没有可显示的书签</p>
+{{- end}}
+{{- else if .PasswordRequired}}
+<form class="card" method="post">
+<div>此分享需要访问密码</div>
+{{- if .Error}}<div class="error">{{.Error}}</div>{{end}}
+<input type="password" name="password" placeholder="访问密码" autofocus>
+<button type="submit">查看</button>
+</form>
+{{- else}}
+<div class="card error">{{.Error}}</div>
+{{- end}}
+</main>
+</body>
+</html>
+{{define "shortcut"}}<a class="item" href="{{.URL}}" target="_blank" rel="noopener noreferrer" title="{{.Name}}">{{with iconSrc .}}<img class="icon" src="{{.}}" alt="" loading="lazy">{{else}}<span class="icon text-icon">{{iconText .}}</span>{{end}}<span class="name">{{.Name}}</span></a>{{end}}`))
@@ -2,28 +2,193 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"itab-backend/internal/backupdata"
 	"itab-backend/internal/database"
 	"itab-backend/internal/models"
+	"itab-backend/internal/store"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// SyncList 获取用户备份列表（远程同步接口）
+// syncBackup 同步列表中的备份，附带所有者和当前用户的权限
+type syncBackup struct {
+	models.Backup
+	Owner      string `json:"owner"`      // 所有者用户名
+	Permission string `json:"permission"` // owner/read/write
+}
+
+// SyncList 获取用户备份列表，包括其他用户共享的备份（远程同步接口）
 func SyncList(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
 	var backups []models.Backup
-	if err := database.DB.Select("id, name, size, sync_count, created_at, updated_at").
-		Where("user_id = ?", userID).Find(&backups).Error; err != nil {
+	if err := database.DB.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, username")
+	}).Select("id, name, size, stored_size, encoding, sync_count, version, user_id, created_at, updated_at").
+		Where("user_id = ? OR id IN (SELECT backup_id FROM backup_grants WHERE user_id = ?)", userID, userID).
+		Find(&backups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取备份列表失败"})
+		return
+	}
+
+	var grants []models.BackupGrant
+	if err := database.DB.Select("backup_id, permission").Where("user_id = ?", userID).Find(&grants).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取备份列表失败"})
 		return
 	}
+	permissions := make(map[uint]string, len(grants))
+	for _, g := range grants {
+		permissions[g.BackupID] = g.Permission
+	}
+
+	items := make([]syncBackup, len(backups))
+	for i, b := range backups {
+		b.ETag = backupETag(&b)
+		permission := permissionOwner
+		if b.UserID != userID {
+			permission = permissions[b.ID]
+		}
+		items[i] = syncBackup{Backup: b, Owner: b.User.Username, Permission: permission}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// backupETag 生成备份当前版本的强ETag
+func backupETag(backup *models.Backup) string {
+	return fmt.Sprintf("\"%d-%d\"", backup.ID, backup.Version)
+}
+
+// etagMatches 按强比较规则判断 If-Match / If-None-Match 头是否匹配指定ETag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUploadPrecondition 校验上传请求携带的版本前置条件，backup 为 nil 表示同名备份尚不存在
+// 通过时返回 0，否则返回应答的HTTP状态码；未携带前置条件的旧客户端总是通过
+func checkUploadPrecondition(backup *models.Backup, ifMatch string, baseRevision *int) int {
+	if ifMatch != "" && (backup == nil || !etagMatches(ifMatch, backupETag(backup))) {
+		return http.StatusPreconditionFailed
+	}
+
+	if baseRevision != nil {
+		current := 0
+		if backup != nil {
+			current = backup.Version
+		}
+		if *baseRevision != current {
+			return http.StatusConflict
+		}
+	}
+
+	return 0
+}
+
+// respondRevisionConflict 返回版本冲突应答，附带服务端当前版本
+func respondRevisionConflict(c *gin.Context, status int, backup *models.Backup) {
+	resp := gin.H{
+		"error":    "备份已被其他客户端修改，请先同步最新数据",
+		"revision": 0,
+	}
+	if backup != nil {
+		etag := backupETag(backup)
+		c.Header("ETag", etag)
+		resp["backup_id"] = backup.ID
+		resp["revision"] = backup.Version
+		resp["etag"] = etag
+	}
+	c.JSON(status, resp)
+}
+
+// errMergeBaseMissing 合并所需的基础版本不存在（如已被保留策略清理），客户端需先同步最新数据
+var errMergeBaseMissing = errors.New("基础版本不存在或已被清理")
+
+// mergeUploadData 将客户端基于 baseRevision 修改的数据与服务端当前数据三方合并
+// 返回合并后的JSON；存在冲突时返回冲突列表；基础版本不存在时返回 errMergeBaseMissing；
+// 任一方存在重复ID时返回包装 backupdata.ErrMergeDuplicateID 的错误
+func mergeUploadData(backup *models.Backup, baseRevision int, localData string) (string, []backupdata.Conflict, error) {
+	var baseVersion models.BackupVersion
+	err := database.DB.Where("backup_id = ? AND version = ?", backup.ID, baseRevision).First(&baseVersion).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil, errMergeBaseMissing
+	}
+	if err != nil {
+		return "", nil, errors.New("读取基础版本失败")
+	}
+	if err := store.LoadVersion(&baseVersion); err != nil {
+		return "", nil, errors.New("读取基础版本失败")
+	}
+
+	base, err := backupdata.Parse(baseVersion.Data)
+	if err != nil {
+		return "", nil, errors.New("解析基础版本失败")
+	}
+	local, err := backupdata.Parse(localData)
+	if err != nil {
+		return "", nil, errors.New("解析上传数据失败")
+	}
+	remote, err := backupdata.Parse(backup.Data)
+	if err != nil {
+		return "", nil, errors.New("解析服务端数据失败")
+	}
+
+	merged, conflicts, err := backupdata.Merge(base, local, remote)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(conflicts) > 0 {
+		return "", conflicts, nil
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(data), nil, nil
+}
+
+// validateUploadData 校验上传的备份数据
+// 默认严格模式下发现问题即返回 422 及问题列表；宽松模式（?lenient=true）下问题仅作为警告返回，不阻止上传
+func validateUploadData(c *gin.Context, data []byte) ([]backupdata.Issue, bool) {
+	return validateBackupData(c, data, http.StatusUnprocessableEntity, nil)
+}
+
+// validateBackupData 同 validateUploadData，严格模式下以 status 返回校验失败
+// baseline 为修改前已存在的问题，严格模式下只拒绝新引入的问题，已有问题作为警告返回
+func validateBackupData(c *gin.Context, data []byte, status int, baseline []backupdata.Issue) ([]backupdata.Issue, bool) {
+	_, issues := backupdata.Validate(data)
+	if len(issues) == 0 {
+		return nil, true
+	}
+
+	if c.Query("lenient") != "true" {
+		if added := backupdata.NewIssues(baseline, issues); len(added) > 0 {
+			c.JSON(status, gin.H{
+				"error":  "备份数据校验失败",
+				"errors": added,
+			})
+			return nil, false
+		}
+		return issues, true
+	}
 
-	c.JSON(http.StatusOK, gin.H{"data": backups})
+	username, _ := c.Get("username")
+	log.Printf("[同步] 用户 %s 以宽松模式上传了存在 %d 个问题的备份数据", username, len(issues))
+	return issues, true
 }
 
 // SyncDownload 下载备份数据（远程同步接口）
@@ -33,6 +198,10 @@ func SyncDownload(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的备份ID"})
 		return
 	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
 
 	userID := c.GetUint("user_id")
 	username, _ := c.Get("username")
@@ -40,10 +209,24 @@ func SyncDownload(c *gin.Context) {
 	accessKey, _ := c.Get("access_key")
 
 	var backup models.Backup
-	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&backup).Error; err != nil {
+	if err := database.DB.Where("id = ?", id).First(&backup).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "备份不存在"})
 		return
 	}
+	// 他人的备份需要共享权限
+	if backup.UserID != userID && !requireGrant(c, &backup, models.GrantRead) {
+		return
+	}
+
+	// 客户端已持有最新版本时无需重复传输（仅原始备份格式，导出的书签文件不是同步数据）
+	if format == nil {
+		etag := backupETag(&backup)
+		c.Header("ETag", etag)
+		if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
 
 	// 记录同步记录
 	record := &models.SyncRecord{
@@ -62,6 +245,14 @@ func SyncDownload(c *gin.Context) {
 	log.Printf("[同步] 用户 %s 使用密钥 %s 下载了备份「%s」", username, accessKey, backup.Name)
 
 	// 解析data为对象
+	if err := store.Load(&backup); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取备份数据失败"})
+		return
+	}
+	if format != nil {
+		writeBookmarkExport(c, &backup, format)
+		return
+	}
 	var backupData interface{}
 	if err := json.Unmarshal([]byte(backup.Data), &backupData); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "解析备份数据失败"})
@@ -73,6 +264,7 @@ func SyncDownload(c *gin.Context) {
 		"version":            "2.1",
 		"exportDate":         backup.UpdatedAt,
 		"passwordsEncrypted": backup.PasswordsEncrypted,
+		"revision":           backup.Version,
 		"data":               backupData,
 	})
 }
@@ -82,8 +274,18 @@ type SyncUploadRequest struct {
 	Name               string      `json:"name" binding:"required"` // 备份名称
 	Data               interface{} `json:"data" binding:"required"` // 备份数据
 	PasswordsEncrypted bool        `json:"passwordsEncrypted"`      // 密码是否加密
+	BaseRevision       *int        `json:"base_revision"`           // 客户端所基于的版本号，为空表示不做并发校验
+	Merge              bool        `json:"merge"`                   // 版本冲突时尝试与服务端数据三方合并，需配合 base_revision
+	OnTrashed          string      `json:"on_trashed"`              // 同名备份在回收站中时的处理方式：restore/recreate
+	Owner              string      `json:"owner"`                   // 备份所有者用户名，上传他人共享的备份时指定，为空表示自己的备份
 }
 
+// 上传到回收站中同名备份时的处理方式
+const (
+	onTrashedRestore  = "restore"  // 从回收站恢复该备份，并将上传数据保存为新版本
+	onTrashedRecreate = "recreate" // 彻底删除回收站中的备份，重新创建
+)
+
 // SyncUpload 上传备份数据（远程同步接口）
 func SyncUpload(c *gin.Context) {
 	userID := c.GetUint("user_id")
@@ -105,9 +307,150 @@ func SyncUpload(c *gin.Context) {
 
 	dataSize := int64(len(importData))
 
-	// 查找是否存在同名备份
+	// 校验数据结构与引用完整性
+	warnings, ok := validateUploadData(c, []byte(importData))
+	if !ok {
+		return
+	}
+
+	ownerID, ok := resolveSyncOwner(c, req.Owner)
+	if !ok {
+		return
+	}
+
+	// 查找是否存在同名备份（包括回收站中的备份）
 	var existingBackup models.Backup
-	err := database.DB.Where("name = ? AND user_id = ?", req.Name, userID).First(&existingBackup).Error
+	var trashedBackup *models.Backup // 需要彻底删除后重新创建的回收站备份
+	restoreTrashed := false
+	err := database.DB.Unscoped().Where("name = ? AND user_id = ?", req.Name, ownerID).First(&existingBackup).Error
+	if ownerID != userID {
+		// 只能更新他人已共享给自己且具有写入权限的备份，不能在他人名下创建备份
+		if err != nil || existingBackup.DeletedAt.Valid {
+			c.JSON(http.StatusNotFound, gin.H{"error": "备份不存在"})
+			return
+		}
+		if !requireGrant(c, &existingBackup, models.GrantWrite) {
+			return
+		}
+	}
+	if err == nil && existingBackup.DeletedAt.Valid {
+		switch req.OnTrashed {
+		case onTrashedRestore:
+			restoreTrashed = true
+		case onTrashedRecreate:
+			trashedBackup = &existingBackup
+			err = gorm.ErrRecordNotFound
+		default:
+			c.JSON(http.StatusConflict, gin.H{
+				"error":      "同名备份在回收站中，请通过 on_trashed 选择恢复（restore）或重新创建（recreate）",
+				"trashed":    true,
+				"backup_id":  existingBackup.ID,
+				"revision":   existingBackup.Version,
+				"deleted_at": existingBackup.DeletedAt.Time,
+			})
+			return
+		}
+	}
+	if err == nil {
+		if loadErr := store.Load(&existingBackup); loadErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取备份数据失败"})
+			return
+		}
+	}
+
+	// 校验并发前置条件（If-Match 头或 base_revision 字段）
+	ifMatch := c.GetHeader("If-Match")
+	merged := false
+	if err == nil {
+		status := checkUploadPrecondition(&existingBackup, ifMatch, req.BaseRevision)
+		if status == http.StatusConflict && req.Merge {
+			// 客户端基于旧版本修改，尝试与服务端当前数据合并
+			mergedData, conflicts, mergeErr := mergeUploadData(&existingBackup, *req.BaseRevision, importData)
+			if errors.Is(mergeErr, errMergeBaseMissing) {
+				// 与未请求合并时一样作为普通的版本冲突处理，客户端拉取最新数据后重新上传
+				c.Header("ETag", backupETag(&existingBackup))
+				c.JSON(http.StatusConflict, gin.H{
+					"error":        "基础版本已被清理，无法合并，请先同步最新数据",
+					"backup_id":    existingBackup.ID,
+					"revision":     existingBackup.Version,
+					"etag":         backupETag(&existingBackup),
+					"base_missing": true,
+				})
+				return
+			}
+			if errors.Is(mergeErr, backupdata.ErrMergeDuplicateID) {
+				// 重复ID的条目无法按ID对应，合并会丢失条目
+				c.Header("ETag", backupETag(&existingBackup))
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error":    "无法合并: " + mergeErr.Error(),
+					"revision": existingBackup.Version,
+					"etag":     backupETag(&existingBackup),
+				})
+				return
+			}
+			if mergeErr != nil {
+				c.Header("ETag", backupETag(&existingBackup))
+				c.JSON(http.StatusConflict, gin.H{
+					"error":    "无法合并: " + mergeErr.Error(),
+					"revision": existingBackup.Version,
+					"etag":     backupETag(&existingBackup),
+				})
+				return
+			}
+			if len(conflicts) > 0 {
+				c.Header("ETag", backupETag(&existingBackup))
+				c.JSON(http.StatusConflict, gin.H{
+					"error":     "合并存在冲突，请处理后重新上传",
+					"backup_id": existingBackup.ID,
+					"revision":  existingBackup.Version,
+					"etag":      backupETag(&existingBackup),
+					"conflicts": conflicts,
+				})
+				return
+			}
+			// 合并结果也可能出现悬空引用（如一方删除了文件夹，另一方向其中新增了书签）
+			if warnings, ok = validateUploadData(c, []byte(mergedData)); !ok {
+				return
+			}
+			importData = mergedData
+			dataSize = int64(len(importData))
+			merged = true
+		} else if status != 0 {
+			respondRevisionConflict(c, status, &existingBackup)
+			return
+		}
+	} else if status := checkUploadPrecondition(nil, ifMatch, req.BaseRevision); status != 0 {
+		respondRevisionConflict(c, status, nil)
+		return
+	}
+
+	if err == nil && !restoreTrashed && store.Unchanged(&existingBackup, importData) && existingBackup.PasswordsEncrypted == req.PasswordsEncrypted {
+		// 内容与当前版本相同，不产生新版本也不改写备份
+		log.Printf("[同步] 用户 %s 使用密钥 %s 上传的备份「%s」内容未变化", username, accessKey, req.Name)
+		resp := gin.H{
+			"message":   "备份内容未变化",
+			"backup_id": existingBackup.ID,
+			"revision":  existingBackup.Version,
+			"unchanged": true,
+		}
+		if merged {
+			var mergedData interface{}
+			json.Unmarshal([]byte(importData), &mergedData)
+			resp["merged"] = true
+			resp["data"] = mergedData
+		}
+		if len(warnings) > 0 {
+			resp["warnings"] = warnings
+		}
+		c.Header("ETag", backupETag(&existingBackup))
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	// 重新创建时回收站中的备份会被删除，备份数量不变
+	if !enforceQuota(c, ownerID, err != nil && trashedBackup == nil, dataSize) {
+		return
+	}
 
 	if err == nil {
 		// 更新现有备份
@@ -115,7 +458,21 @@ func SyncUpload(c *gin.Context) {
 		existingBackup.Size = dataSize
 		existingBackup.SyncCount++
 		existingBackup.PasswordsEncrypted = req.PasswordsEncrypted
-		if err := database.DB.Save(&existingBackup).Error; err != nil {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if restoreTrashed {
+				if err := store.Restore(tx, &existingBackup); err != nil {
+					return err
+				}
+			}
+			return store.SaveRevision(tx, &existingBackup, userID, accessKeyID, accessKey.(string))
+		})
+		if errors.Is(err, store.ErrRevisionConflict) {
+			// 读取之后被其他客户端抢先更新，返回最新版本号
+			database.DB.First(&existingBackup, existingBackup.ID)
+			respondRevisionConflict(c, http.StatusConflict, &existingBackup)
+			return
+		}
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新备份失败"})
 			return
 		}
@@ -131,12 +488,36 @@ func SyncUpload(c *gin.Context) {
 		database.DB.Create(record)
 
 		// 打印操作日志
-		log.Printf("[同步] 用户 %s 使用密钥 %s 更新了备份「%s」", username, accessKey, req.Name)
+		if restoreTrashed {
+			log.Printf("[同步] 用户 %s 使用密钥 %s 从回收站恢复并更新了备份「%s」", username, accessKey, req.Name)
+		} else if merged {
+			log.Printf("[同步] 用户 %s 使用密钥 %s 合并更新了备份「%s」", username, accessKey, req.Name)
+		} else {
+			log.Printf("[同步] 用户 %s 使用密钥 %s 更新了备份「%s」", username, accessKey, req.Name)
+		}
 
-		c.JSON(http.StatusOK, gin.H{
+		resp := gin.H{
 			"message":   "备份更新成功",
 			"backup_id": existingBackup.ID,
-		})
+			"revision":  existingBackup.Version,
+		}
+		if restoreTrashed {
+			resp["message"] = "备份已从回收站恢复并更新"
+			resp["restored"] = true
+		}
+		if merged {
+			// 返回合并结果，客户端应以此替换本地数据
+			var mergedData interface{}
+			json.Unmarshal([]byte(importData), &mergedData)
+			resp["message"] = "备份合并成功"
+			resp["merged"] = true
+			resp["data"] = mergedData
+		}
+		if len(warnings) > 0 {
+			resp["warnings"] = warnings
+		}
+		c.Header("ETag", backupETag(&existingBackup))
+		c.JSON(http.StatusOK, resp)
 		return
 	}
 
@@ -144,13 +525,25 @@ func SyncUpload(c *gin.Context) {
 	backup := &models.Backup{
 		Name:               req.Name,
 		Data:               importData,
-		Size:               dataSize,
 		SyncCount:          1,
 		PasswordsEncrypted: req.PasswordsEncrypted,
 		UserID:             userID,
 	}
 
-	if err := database.DB.Create(backup).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if trashedBackup != nil {
+			if err := store.Delete(tx, trashedBackup); err != nil {
+				return err
+			}
+		}
+		return store.Create(tx, backup, userID, accessKeyID, accessKey.(string))
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// 查询之后同名备份被其他客户端抢先创建
+		c.JSON(http.StatusConflict, gin.H{"error": "同名备份已被其他客户端创建，请先同步最新数据", "name": req.Name})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建备份失败"})
 		return
 	}
@@ -166,10 +559,23 @@ func SyncUpload(c *gin.Context) {
 	database.DB.Create(record)
 
 	// 打印操作日志
-	log.Printf("[同步] 用户 %s 使用密钥 %s 创建了备份「%s」", username, accessKey, req.Name)
+	if trashedBackup != nil {
+		log.Printf("[同步] 用户 %s 使用密钥 %s 彻底删除回收站中的同名备份后重新创建了备份「%s」", username, accessKey, req.Name)
+	} else {
+		log.Printf("[同步] 用户 %s 使用密钥 %s 创建了备份「%s」", username, accessKey, req.Name)
+	}
 
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"message":   "备份创建成功",
 		"backup_id": backup.ID,
-	})
+		"revision":  backup.Version,
+	}
+	if trashedBackup != nil {
+		resp["recreated"] = true
+	}
+	if len(warnings) > 0 {
+		resp["warnings"] = warnings
+	}
+	c.Header("ETag", backupETag(backup))
+	c.JSON(http.StatusOK, resp)
 }
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"itab-backend/internal/backupdata"
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/store"
+)
+
+// setupMergeBackup 创建版本 1（base）后由其他客户端修改书签 1 的名称，保存为版本 2
+func setupMergeBackup(t *testing.T) (*models.User, *models.Backup) {
+	t.Helper()
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	backup := createTestBackup(t, user.ID, "home", testData(
+		shortcut(1, "GitHub", "https://github.com"),
+		shortcut(2, "Go", "https://go.dev"),
+	))
+	saveTestRevision(t, backup, testData(
+		shortcut(1, "GitHub (remote)", "https://github.com"),
+		shortcut(2, "Go", "https://go.dev"),
+	))
+	return user, backup
+}
+
+func TestMergeUploadDataClean(t *testing.T) {
+	_, backup := setupMergeBackup(t)
+
+	// 客户端基于版本 1 修改了另一个书签
+	local := mustJSON(t, testData(
+		shortcut(1, "GitHub", "https://github.com"),
+		shortcut(2, "Go (local)", "https://go.dev"),
+	))
+	merged, conflicts, err := mergeUploadData(backup, 1, local)
+	if err != nil {
+		t.Fatalf("mergeUploadData: %v", err)
+	}
+	if len(conflicts) > 0 {
+		t.Fatalf("不应有冲突: %+v", conflicts)
+	}
+	bd, err := backupdata.Parse(merged)
+	if err != nil {
+		t.Fatalf("解析合并结果: %v", err)
+	}
+	names := map[int]string{}
+	for _, s := range bd.Shortcuts {
+		names[s.ID] = s.Name
+	}
+	if names[1] != "GitHub (remote)" || names[2] != "Go (local)" {
+		t.Errorf("合并结果 = %v，应同时包含双方的修改", names)
+	}
+}
+
+func TestMergeUploadDataConflict(t *testing.T) {
+	_, backup := setupMergeBackup(t)
+
+	// 客户端与服务端修改了同一书签的同一字段
+	local := mustJSON(t, testData(
+		shortcut(1, "GitHub (local)", "https://github.com"),
+		shortcut(2, "Go", "https://go.dev"),
+	))
+	merged, conflicts, err := mergeUploadData(backup, 1, local)
+	if err != nil {
+		t.Fatalf("mergeUploadData: %v", err)
+	}
+	if merged != "" {
+		t.Errorf("存在冲突时不应返回合并结果")
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("冲突数 = %d，应为 1: %+v", len(conflicts), conflicts)
+	}
+	c := conflicts[0]
+	if c.Type != "shortcut" || c.ID != 1 || c.Field != "name" {
+		t.Errorf("冲突 = %+v，应为书签 1 的 name 字段", c)
+	}
+	if c.Local != "GitHub (local)" || c.Remote != "GitHub (remote)" {
+		t.Errorf("冲突值 local=%v remote=%v", c.Local, c.Remote)
+	}
+}
+
+func TestMergeUploadDataMissingBase(t *testing.T) {
+	_, backup := setupMergeBackup(t)
+
+	// 版本 1 已被保留策略清理
+	if _, err := store.DeleteVersions(database.DB, backup.ID, []int{1}); err != nil {
+		t.Fatalf("删除版本: %v", err)
+	}
+	_, _, err := mergeUploadData(backup, 1, mustJSON(t, testData()))
+	if !errors.Is(err, errMergeBaseMissing) {
+		t.Fatalf("err = %v，应为 errMergeBaseMissing", err)
+	}
+}
+
+func TestSyncUploadMergeMissingBaseIsConflict(t *testing.T) {
+	user, backup := setupMergeBackup(t)
+	if _, err := store.DeleteVersions(database.DB, backup.ID, []int{1}); err != nil {
+		t.Fatalf("删除版本: %v", err)
+	}
+
+	base := 1
+	c, w := newTestContext(user, http.MethodPost, "/api/sync/upload", SyncUploadRequest{
+		Name:         "home",
+		Data:         testData(shortcut(1, "GitHub (local)", "https://github.com")),
+		BaseRevision: &base,
+		Merge:        true,
+	})
+	SyncUpload(c)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("状态码 = %d，应为 409: %s", w.Code, w.Body.String())
+	}
+	if got, want := w.Header().Get("ETag"), backupETag(backup); got != want {
+		t.Errorf("ETag = %q，应为 %q", got, want)
+	}
+	resp := decodeResponse(t, w)
+	if resp["base_missing"] != true || resp["revision"] != float64(2) {
+		t.Errorf("应答 = %v", resp)
+	}
+}
+
+// 宽松模式上传的重复ID无法按ID合并，拒绝而不是静默丢弃条目
+func TestSyncUploadMergeRejectsDuplicateIDs(t *testing.T) {
+	user, backup := setupMergeBackup(t)
+
+	base := 1
+	c, w := newTestContext(user, http.MethodPost, "/api/sync/upload?lenient=true", SyncUploadRequest{
+		Name: "home",
+		Data: testData(
+			shortcut(1, "GitHub", "https://github.com"),
+			shortcut(2, "Go", "https://go.dev"),
+			shortcut(2, "Go Blog", "https://go.dev/blog"),
+		),
+		BaseRevision: &base,
+		Merge:        true,
+	})
+	SyncUpload(c)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("状态码 = %d，应为 422: %s", w.Code, w.Body.String())
+	}
+	resp := decodeResponse(t, w)
+	if resp["revision"] != float64(2) || resp["etag"] != backupETag(backup) {
+		t.Errorf("应答 = %v", resp)
+	}
+	var stored models.Backup
+	database.DB.First(&stored, backup.ID)
+	if stored.Version != 2 {
+		t.Errorf("版本 = %d，不应保存", stored.Version)
+	}
+}
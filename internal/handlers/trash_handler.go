@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/store"
+	"itab-backend/internal/trash"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// trashedBackup 回收站中的备份，附带自动彻底删除的时间
+type trashedBackup struct {
+	models.Backup
+	PurgeAt *time.Time `json:"purge_at"` // 自动彻底删除的时间，nil 表示不会自动删除
+}
+
+// findTrashedBackup 按路径参数查找回收站中的备份并校验访问权限，失败时已写入响应
+func findTrashedBackup(c *gin.Context) (*models.Backup, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的备份ID"})
+		return nil, false
+	}
+
+	userID := c.GetUint("user_id")
+	isAdmin := c.GetBool("is_admin")
+
+	var backup models.Backup
+	if err := database.DB.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&backup).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "回收站中不存在此备份"})
+		return nil, false
+	}
+
+	// 非管理员只能操作自己的备份
+	if !isAdmin && backup.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权访问此备份"})
+		return nil, false
+	}
+
+	return &backup, true
+}
+
+// ListTrash 获取回收站中的备份列表
+func ListTrash(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	isAdmin := c.GetBool("is_admin")
+
+	config, err := trash.GetConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取回收站设置失败"})
+		return
+	}
+
+	var backups []models.Backup
+	query := database.DB.Unscoped().Preload("User").
+		Select("id, name, size, stored_size, encoding, sync_count, version, user_id, created_at, updated_at, deleted_at").
+		Where("deleted_at IS NOT NULL")
+
+	if !isAdmin {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.Order("deleted_at DESC").Find(&backups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取回收站列表失败"})
+		return
+	}
+
+	items := make([]trashedBackup, len(backups))
+	for i, b := range backups {
+		b.User.Password = ""
+		items[i] = trashedBackup{Backup: b, PurgeAt: config.PurgeAt(b.DeletedAt.Time)}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": items, "purge_days": config.PurgeDays})
+}
+
+// RestoreTrashedBackup 从回收站恢复备份
+func RestoreTrashedBackup(c *gin.Context) {
+	backup, ok := findTrashedBackup(c)
+	if !ok {
+		return
+	}
+
+	if err := store.Restore(database.DB, backup); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复备份失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "备份已恢复", "data": gin.H{
+		"backup_id": backup.ID,
+		"name":      backup.Name,
+		"revision":  backup.Version,
+	}})
+}
+
+// PurgeTrashedBackup 彻底删除回收站中的备份及其全部历史版本
+func PurgeTrashedBackup(c *gin.Context) {
+	backup, ok := findTrashedBackup(c)
+	if !ok {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return store.Delete(tx, backup)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除备份失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "备份已彻底删除"})
+}
+
+// GetTrashConfig 获取回收站设置
+func GetTrashConfig(c *gin.Context) {
+	config, err := trash.GetConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取回收站设置失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": config})
+}
+
+// UpdateTrashConfig 修改回收站设置，purge_days 为 0 表示不自动删除
+func UpdateTrashConfig(c *gin.Context) {
+	var config trash.Config
+	if err := c.ShouldBindJSON(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if config.PurgeDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "保留天数不能为负数"})
+		return
+	}
+
+	if err := trash.SetConfig(config); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存回收站设置失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "回收站设置已更新", "data": config})
+}
@@ -6,8 +6,10 @@ import (
 
 	"itab-backend/internal/database"
 	"itab-backend/internal/models"
+	"itab-backend/internal/quota"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // CreateUserRequest 创建用户请求
@@ -18,9 +20,36 @@ type CreateUserRequest struct {
 }
 
 // UpdateUserRequest 更新用户请求
+// 配额字段：不传表示不修改，-1 表示恢复使用默认配额，0 表示不限制
 type UpdateUserRequest struct {
-	Password string `json:"password"`
-	IsAdmin  *bool  `json:"is_admin"`
+	Password        string `json:"password"`
+	IsAdmin         *bool  `json:"is_admin"`
+	QuotaBytes      *int64 `json:"quota_bytes"`
+	QuotaBackups    *int64 `json:"quota_backups"`
+	QuotaBackupSize *int64 `json:"quota_backup_size"`
+}
+
+// userWithQuota 附带存储配额及用量的用户信息
+type userWithQuota struct {
+	models.User
+	Quota *quota.Status `json:"quota"`
+}
+
+// applyQuotaOverride 按请求修改用户的单项配额
+func applyQuotaOverride(field **int64, value *int64) bool {
+	if value == nil {
+		return true
+	}
+	switch {
+	case *value == -1:
+		*field = nil
+	case *value >= 0:
+		v := *value
+		*field = &v
+	default:
+		return false
+	}
+	return true
 }
 
 // ListUsers 获取用户列表
@@ -96,6 +125,12 @@ func UpdateUser(c *gin.Context) {
 	if req.IsAdmin != nil {
 		user.IsAdmin = *req.IsAdmin
 	}
+	if !applyQuotaOverride(&user.QuotaBytes, req.QuotaBytes) ||
+		!applyQuotaOverride(&user.QuotaBackups, req.QuotaBackups) ||
+		!applyQuotaOverride(&user.QuotaBackupSize, req.QuotaBackupSize) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的配额"})
+		return
+	}
 
 	if err := database.DB.Save(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新用户失败"})
@@ -114,9 +149,9 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	// 检查是否存在备份数据
+	// 检查是否存在备份数据（包括回收站中的备份）
 	var backupCount int64
-	database.DB.Model(&models.Backup{}).Where("user_id = ?", id).Count(&backupCount)
+	database.DB.Unscoped().Model(&models.Backup{}).Where("user_id = ?", id).Count(&backupCount)
 	if backupCount > 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "该用户存在备份数据，请先删除备份数据"})
 		return
@@ -130,7 +165,14 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := database.DB.Delete(&models.User{}, id).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// 删除其他用户共享给该用户的授权
+		if err := tx.Where("user_id = ?", id).Delete(&models.BackupGrant{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, id).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除用户失败"})
 		return
 	}
@@ -152,6 +194,12 @@ func GetUser(c *gin.Context) {
 		return
 	}
 
+	status, err := quota.StatusOf(&user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取存储配额失败"})
+		return
+	}
+
 	user.Password = ""
-	c.JSON(http.StatusOK, gin.H{"data": user})
+	c.JSON(http.StatusOK, gin.H{"data": userWithQuota{User: user, Quota: status}})
 }
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// findBackupForUser 根据路径参数查找备份，并校验当前用户的访问权限
+// 只读取备份记录，不解码备份数据：当前数据块损坏或丢失时仍可查看和恢复历史版本，需要数据时再调用 loadBackupData
+// 查找失败时已写入错误响应，调用方直接返回即可
+func findBackupForUser(c *gin.Context) (*models.Backup, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的备份ID"})
+		return nil, false
+	}
+
+	userID := c.GetUint("user_id")
+	isAdmin := c.GetBool("is_admin")
+
+	var backup models.Backup
+	if err := database.DB.First(&backup, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "备份不存在"})
+		return nil, false
+	}
+
+	// 非管理员只能访问自己的备份
+	if !isAdmin && backup.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权访问此备份"})
+		return nil, false
+	}
+
+	return &backup, true
+}
+
+// loadBackupData 解码备份当前数据到 Data 字段，失败时已写入错误响应
+func loadBackupData(c *gin.Context, backup *models.Backup) bool {
+	if err := store.Load(backup); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取备份数据失败"})
+		return false
+	}
+	return true
+}
+
+// findBackupVersion 查找备份的指定版本
+func findBackupVersion(c *gin.Context, backupID uint, versionParam string) (*models.BackupVersion, bool) {
+	v, err := strconv.Atoi(versionParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的版本号"})
+		return nil, false
+	}
+
+	var version models.BackupVersion
+	if err := database.DB.Where("backup_id = ? AND version = ?", backupID, v).First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "版本不存在"})
+		return nil, false
+	}
+	if err := store.LoadVersion(&version); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取版本数据失败"})
+		return nil, false
+	}
+
+	return &version, true
+}
+
+// ListBackupVersions 获取备份的历史版本列表
+func ListBackupVersions(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	var versions []models.BackupVersion
+	if err := database.DB.Select("id, backup_id, version, size, encoding, stored_size, passwords_encrypted, access_key_id, access_key, user_id, created_at").
+		Where("backup_id = ?", backup.ID).Order("version DESC").Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取版本列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"current_version": backup.Version,
+		"data":            versions,
+	})
+}
+
+// GetBackupVersion 获取备份的指定版本详情
+func GetBackupVersion(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	version, ok := findBackupVersion(c, backup.ID, c.Param("v"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": version})
+}
+
+// RestoreBackupVersion 将指定历史版本恢复为当前版本
+// 恢复操作本身也会生成一个新版本，因此可以随时撤销
+func RestoreBackupVersion(c *gin.Context) {
+	backup, ok := findBackupForUser(c)
+	if !ok {
+		return
+	}
+
+	version, ok := findBackupVersion(c, backup.ID, c.Param("v"))
+	if !ok {
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	username, _ := c.Get("username")
+
+	// 恢复会产生新版本，计入备份所有者的配额
+	if !enforceQuota(c, backup.UserID, false, version.Size) {
+		return
+	}
+
+	backup.Data = version.Data
+	backup.Size = version.Size
+	backup.PasswordsEncrypted = version.PasswordsEncrypted
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := store.SaveRevision(tx, backup, userID, 0, ""); err != nil {
+			return err
+		}
+		return tx.Create(&models.SyncRecord{
+			BackupName: backup.Name,
+			TransType:  "restore",
+			UserID:     userID,
+		}).Error
+	})
+	if errors.Is(err, store.ErrRevisionConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "备份已被其他请求修改，请刷新后重试"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复版本失败"})
+		return
+	}
+
+	// 打印操作日志
+	log.Printf("[备份] 用户 %s 将备份「%s」恢复到版本 %d（新版本 %d）", username, backup.Name, version.Version, backup.Version)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "版本恢复成功",
+		"version": backup.Version,
+	})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"itab-backend/internal/backupdata"
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 当前数据块丢失时仍可列出并恢复历史版本
+func TestRestoreVersionWithMissingCurrentBlob(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestUser(t, "alice")
+	backup := createTestBackup(t, alice.ID, "home", testData(shortcut(1, "GitHub", "https://github.com")))
+	saveTestRevision(t, backup, testData(shortcut(1, "GitHub", "https://github.com"), shortcut(2, "Go", "https://go.dev")))
+
+	if err := database.DB.Where("hash = ?", backup.BlobHash).Delete(&models.Blob{}).Error; err != nil {
+		t.Fatalf("删除数据块失败: %v", err)
+	}
+	id := strconv.FormatUint(uint64(backup.ID), 10)
+
+	c, w := newTestContext(alice, http.MethodGet, "/api/backups/"+id+"/versions", nil)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	ListBackupVersions(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("列出版本状态码 = %d，应为 200: %s", w.Code, w.Body.String())
+	}
+
+	c, w = newTestContext(alice, http.MethodPost, "/api/backups/"+id+"/versions/1/restore", nil)
+	c.Params = gin.Params{{Key: "id", Value: id}, {Key: "v", Value: "1"}}
+	RestoreBackupVersion(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("恢复版本状态码 = %d，应为 200: %s", w.Code, w.Body.String())
+	}
+	if v := decodeResponse(t, w)["version"]; v != float64(3) {
+		t.Errorf("恢复后版本 = %v，应为 3", v)
+	}
+
+	var restored models.Backup
+	database.DB.First(&restored, backup.ID)
+	if err := store.Load(&restored); err != nil {
+		t.Fatalf("恢复后读取备份失败: %v", err)
+	}
+	bd, err := backupdata.Parse(restored.Data)
+	if err != nil {
+		t.Fatalf("解析恢复后的数据失败: %v", err)
+	}
+	if len(bd.Shortcuts) != 1 || bd.Shortcuts[0].Name != "GitHub" {
+		t.Errorf("恢复后的书签 = %+v，应为版本 1 的内容", bd.Shortcuts)
+	}
+
+	// 读取数据块丢失的版本仍报告错误
+	c, w = newTestContext(alice, http.MethodGet, "/api/backups/"+id+"/diff?from=2", nil)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	DiffBackup(c)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("比较丢失的版本状态码 = %d，应为 500", w.Code)
+	}
+}
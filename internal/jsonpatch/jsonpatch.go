@@ -0,0 +1,351 @@
+// Package jsonpatch 实现 RFC 6902 JSON Patch
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Operation 单个补丁操作
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Patch 补丁文档，按顺序应用
+type Patch []Operation
+
+// Decode 解析补丁文档并检查每个操作的必填字段
+func Decode(data []byte) (Patch, error) {
+	var patch Patch
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, fmt.Errorf("补丁格式错误: %v", err)
+	}
+
+	for i, op := range patch {
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("第 %d 个操作（%s）缺少 value", i, op.Op)
+			}
+		case "move", "copy":
+			if _, err := parsePointer(op.From); err != nil {
+				return nil, fmt.Errorf("第 %d 个操作的 from 无效: %v", i, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("第 %d 个操作类型不支持: %q", i, op.Op)
+		}
+		if _, err := parsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("第 %d 个操作的 path 无效: %v", i, err)
+		}
+	}
+
+	return patch, nil
+}
+
+// Apply 将补丁应用到JSON文档，返回新文档
+// 任意一个操作失败时返回错误，原文档不受影响
+func (p Patch) Apply(doc []byte) ([]byte, error) {
+	root, err := decodeValue(doc)
+	if err != nil {
+		return nil, fmt.Errorf("文档格式错误: %v", err)
+	}
+
+	for i, op := range p {
+		root, err = op.apply(root)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个操作（%s %s）失败: %v", i, op.Op, op.Path, err)
+		}
+	}
+
+	return json.Marshal(root)
+}
+
+func (op Operation) apply(root interface{}) (interface{}, error) {
+	path, _ := parsePointer(op.Path)
+
+	switch op.Op {
+	case "add":
+		value, err := decodeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	case "remove":
+		root, _, err := remove(root, path)
+		return root, err
+	case "replace":
+		value, err := decodeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		return update(root, path, func(parent interface{}, key string) (interface{}, error) {
+			switch node := parent.(type) {
+			case map[string]interface{}:
+				if _, ok := node[key]; !ok {
+					return nil, fmt.Errorf("成员 %q 不存在", key)
+				}
+				node[key] = value
+				return node, nil
+			case []interface{}:
+				idx, err := arrayIndex(key, len(node)-1)
+				if err != nil {
+					return nil, err
+				}
+				node[idx] = value
+				return node, nil
+			}
+			return nil, fmt.Errorf("无法在非容器值上替换 %q", key)
+		})
+	case "move":
+		from, _ := parsePointer(op.From)
+		if op.Path != op.From && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("不能移动到自身的子节点")
+		}
+		root, value, err := remove(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	case "copy":
+		from, _ := parsePointer(op.From)
+		value, err := get(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, deepCopy(value))
+	case "test":
+		expected, err := decodeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		actual, err := get(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(actual, expected) {
+			return nil, fmt.Errorf("测试值不匹配")
+		}
+		return root, nil
+	}
+
+	return nil, fmt.Errorf("不支持的操作 %q", op.Op)
+}
+
+// add 在指定位置新增值，数组位置 "-" 表示追加到末尾
+func add(root interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(root, path, func(parent interface{}, key string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[key] = value
+			return node, nil
+		case []interface{}:
+			idx := len(node)
+			if key != "-" {
+				var err error
+				if idx, err = arrayIndex(key, len(node)); err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[idx+1:], node[idx:])
+			node[idx] = value
+			return node, nil
+		}
+		return nil, fmt.Errorf("无法在非容器值上新增 %q", key)
+	})
+}
+
+// remove 删除指定位置的值，返回新文档和被删除的值
+func remove(root interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("不能删除根节点")
+	}
+	var removed interface{}
+	root, err := update(root, path, func(parent interface{}, key string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("成员 %q 不存在", key)
+			}
+			removed = value
+			delete(node, key)
+			return node, nil
+		case []interface{}:
+			idx, err := arrayIndex(key, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[idx]
+			return append(node[:idx], node[idx+1:]...), nil
+		}
+		return nil, fmt.Errorf("无法在非容器值上删除 %q", key)
+	})
+	return root, removed, err
+}
+
+// update 定位到路径的父节点并调用 fn 修改，返回更新后的根节点
+func update(node interface{}, path []string, fn func(parent interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+
+	child, err := getChild(node, path[0])
+	if err != nil {
+		return nil, err
+	}
+	child, err = update(child, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parent := node.(type) {
+	case map[string]interface{}:
+		parent[path[0]] = child
+	case []interface{}:
+		idx, _ := arrayIndex(path[0], len(parent)-1)
+		parent[idx] = child
+	}
+	return node, nil
+}
+
+// get 获取指定位置的值
+func get(node interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		var err error
+		if node, err = getChild(node, token); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func getChild(node interface{}, token string) (interface{}, error) {
+	switch parent := node.(type) {
+	case map[string]interface{}:
+		child, ok := parent[token]
+		if !ok {
+			return nil, fmt.Errorf("成员 %q 不存在", token)
+		}
+		return child, nil
+	case []interface{}:
+		idx, err := arrayIndex(token, len(parent)-1)
+		if err != nil {
+			return nil, err
+		}
+		return parent[idx], nil
+	}
+	return nil, fmt.Errorf("路径 %q 指向非容器值", token)
+}
+
+// parsePointer 解析 RFC 6901 JSON Pointer
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON Pointer 必须以 / 开头: %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex 解析数组下标，允许的最大值为 max
+func arrayIndex(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("无效的数组下标 %q", token)
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 {
+		return 0, fmt.Errorf("无效的数组下标 %q", token)
+	}
+	if idx > max {
+		return 0, fmt.Errorf("数组下标 %d 越界", idx)
+	}
+	return idx, nil
+}
+
+// decodeValue 解析JSON值，数字保留原始精度
+func decodeValue(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = deepCopy(item)
+		}
+		return m
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, item := range v {
+			arr[i] = deepCopy(item)
+		}
+		return arr
+	}
+	return value
+}
+
+// equal 按JSON语义比较两个值，数字按数值比较
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		ra, okA := new(big.Rat).SetString(x.String())
+		rb, okB := new(big.Rat).SetString(y.String())
+		return okA && okB && ra.Cmp(rb) == 0
+	}
+	return a == b
+}
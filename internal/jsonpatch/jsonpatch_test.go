@@ -0,0 +1,230 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string // 为空表示应失败
+		err   string // 失败时错误信息应包含的内容
+	}{
+		{
+			name:  "~1 反转义为 /",
+			doc:   `{"a/b":1}`,
+			patch: `[{"op":"replace","path":"/a~1b","value":2}]`,
+			want:  `{"a/b":2}`,
+		},
+		{
+			name:  "~0 反转义为 ~",
+			doc:   `{"m~n":1}`,
+			patch: `[{"op":"remove","path":"/m~0n"}]`,
+			want:  `{}`,
+		},
+		{
+			name:  "~01 先处理 ~1 再处理 ~0",
+			doc:   `{"~1":1,"/":2}`,
+			patch: `[{"op":"test","path":"/~01","value":1},{"op":"test","path":"/~1","value":2}]`,
+			want:  `{"~1":1,"/":2}`,
+		},
+		{
+			name:  "- 追加到数组末尾",
+			doc:   `{"list":[1,2]}`,
+			patch: `[{"op":"add","path":"/list/-","value":3}]`,
+			want:  `{"list":[1,2,3]}`,
+		},
+		{
+			name:  "按下标插入",
+			doc:   `{"list":[1,3]}`,
+			patch: `[{"op":"add","path":"/list/1","value":2}]`,
+			want:  `{"list":[1,2,3]}`,
+		},
+		{
+			name:  "下标等于长度时插入到末尾",
+			doc:   `{"list":[1]}`,
+			patch: `[{"op":"add","path":"/list/1","value":2}]`,
+			want:  `{"list":[1,2]}`,
+		},
+		{
+			name:  "新增时下标越界",
+			doc:   `{"list":[1]}`,
+			patch: `[{"op":"add","path":"/list/2","value":2}]`,
+			err:   "越界",
+		},
+		{
+			name:  "替换时下标越界",
+			doc:   `{"list":[1]}`,
+			patch: `[{"op":"replace","path":"/list/1","value":2}]`,
+			err:   "越界",
+		},
+		{
+			name:  "删除时下标越界",
+			doc:   `{"list":[]}`,
+			patch: `[{"op":"remove","path":"/list/0"}]`,
+			err:   "越界",
+		},
+		{
+			name:  "前导零的下标无效",
+			doc:   `{"list":[1,2]}`,
+			patch: `[{"op":"remove","path":"/list/01"}]`,
+			err:   "无效的数组下标",
+		},
+		{
+			name:  "移动",
+			doc:   `{"a":{"b":1},"c":{}}`,
+			patch: `[{"op":"move","from":"/a/b","path":"/c/d"}]`,
+			want:  `{"a":{},"c":{"d":1}}`,
+		},
+		{
+			name:  "不能移动到自身的子节点",
+			doc:   `{"a":{"b":{}}}`,
+			patch: `[{"op":"move","from":"/a","path":"/a/b/c"}]`,
+			err:   "子节点",
+		},
+		{
+			name:  "移动到前缀相同的兄弟节点",
+			doc:   `{"a":1,"ab":{}}`,
+			patch: `[{"op":"move","from":"/a","path":"/ab/x"}]`,
+			want:  `{"ab":{"x":1}}`,
+		},
+		{
+			name:  "复制为深拷贝",
+			doc:   `{"a":{"x":1}}`,
+			patch: `[{"op":"copy","from":"/a","path":"/b"},{"op":"replace","path":"/b/x","value":2}]`,
+			want:  `{"a":{"x":1},"b":{"x":2}}`,
+		},
+		{
+			name:  "test 通过",
+			doc:   `{"n":1.0,"o":{"a":[1,"x"]}}`,
+			patch: `[{"op":"test","path":"/n","value":1},{"op":"test","path":"/o","value":{"a":[1,"x"]}}]`,
+			want:  `{"n":1.0,"o":{"a":[1,"x"]}}`,
+		},
+		{
+			name:  "test 失败",
+			doc:   `{"n":1}`,
+			patch: `[{"op":"test","path":"/n","value":2}]`,
+			err:   "测试值不匹配",
+		},
+		{
+			name:  "替换不存在的成员",
+			doc:   `{}`,
+			patch: `[{"op":"replace","path":"/a","value":1}]`,
+			err:   "不存在",
+		},
+		{
+			name:  "替换根节点",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"replace","path":"","value":[1]}]`,
+			want:  `[1]`,
+		},
+		{
+			name:  "不能删除根节点",
+			doc:   `{}`,
+			patch: `[{"op":"remove","path":""}]`,
+			err:   "根节点",
+		},
+		{
+			name:  "大整数保留精度",
+			doc:   `{"id":1234567890123456789}`,
+			patch: `[{"op":"add","path":"/x","value":true}]`,
+			want:  `{"id":1234567890123456789,"x":true}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := Decode([]byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			got, err := patch.Apply([]byte(tt.doc))
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("应失败，得到 %s", got)
+				}
+				if !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("错误 = %q，应包含 %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+// 后面的操作失败时整个补丁不生效，原文档保持不变
+func TestApplyIsAtomic(t *testing.T) {
+	doc := []byte(`{"shortcuts":[{"id":1,"name":"a"}],"n":1}`)
+	original := string(doc)
+	patch, err := Decode([]byte(`[
+		{"op":"replace","path":"/shortcuts/0/name","value":"b"},
+		{"op":"add","path":"/shortcuts/-","value":{"id":2}},
+		{"op":"remove","path":"/n"},
+		{"op":"test","path":"/shortcuts/0/name","value":"c"}
+	]`))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	got, err := patch.Apply(doc)
+	if err == nil {
+		t.Fatalf("应失败，得到 %s", got)
+	}
+	if got != nil {
+		t.Errorf("失败时不应返回文档，得到 %s", got)
+	}
+	if !strings.Contains(err.Error(), "第 3 个操作") {
+		t.Errorf("错误 = %q，应指出失败的操作序号", err)
+	}
+	if string(doc) != original {
+		t.Errorf("原文档被修改: %s", doc)
+	}
+
+	// 同一补丁可再次应用到原文档，结果与首次一致
+	if _, err2 := patch.Apply(doc); err2 == nil || err2.Error() != err.Error() {
+		t.Errorf("再次应用的错误 = %v，应为 %v", err2, err)
+	}
+}
+
+func TestDecodeRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+	}{
+		{"不是数组", `{"op":"add"}`},
+		{"不支持的操作", `[{"op":"merge","path":"/a"}]`},
+		{"缺少 value", `[{"op":"add","path":"/a"}]`},
+		{"path 不以 / 开头", `[{"op":"remove","path":"a"}]`},
+		{"from 无效", `[{"op":"move","from":"a","path":"/b"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode([]byte(tt.patch)); err == nil {
+				t.Errorf("Decode(%s) 应失败", tt.patch)
+			}
+		})
+	}
+}
+
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("结果不是合法的 JSON: %v（%s）", err, got)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("期望值不是合法的 JSON: %v", err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("结果 = %s，应为 %s", got, want)
+	}
+}
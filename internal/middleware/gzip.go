@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipMinSize 响应体小于该大小时不压缩，压缩收益不足以抵消开销
+const gzipMinSize = 1024
+
+// GzipMiddleware 请求/响应压缩中间件
+// 请求头 Content-Encoding: gzip 时解压请求体，解压后超过 maxBodySize 字节返回 413；
+// 请求头 Accept-Encoding 包含 gzip 时压缩响应体，HEAD 请求和不允许响应体的状态码（如 304）不压缩
+func GzipMiddleware(maxBodySize int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !decompressRequest(c, maxBodySize) {
+			c.Abort()
+			return
+		}
+
+		if c.Request.Method == http.MethodHead || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		writer := &gzipResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Header("Vary", "Accept-Encoding")
+		defer writer.close()
+		c.Next()
+	}
+}
+
+// decompressRequest 按 Content-Encoding 解压请求体，失败时写入错误响应并返回 false
+func decompressRequest(c *gin.Context, maxBodySize int64) bool {
+	encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return true
+	case "gzip", "x-gzip":
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "不支持的请求体编码: " + encoding})
+		return false
+	}
+
+	reader, err := gzip.NewReader(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体不是有效的gzip数据"})
+		return false
+	}
+	defer reader.Close()
+
+	// 多读一个字节用于判断是否超出限制，避免压缩炸弹耗尽内存
+	body, err := io.ReadAll(io.LimitReader(reader, maxBodySize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "解压请求体失败"})
+		return false
+	}
+	if int64(len(body)) > maxBodySize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":    "解压后的请求体超过大小限制",
+			"max_size": maxBodySize,
+		})
+		return false
+	}
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.ContentLength = int64(len(body))
+	c.Request.Header.Del("Content-Encoding")
+	c.Request.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return true
+}
+
+// acceptsGzip 判断客户端是否接受gzip编码的响应（q=0 表示拒绝）
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		if strings.ToLower(strings.TrimSpace(fields[0])) != "gzip" {
+			continue
+		}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter 在首次写入时决定是否压缩响应体
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	gz      *gzip.Writer
+	decided bool
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	if !bodyAllowed(w.Status()) {
+		return 0, http.ErrBodyNotAllowed
+	}
+	if !w.decided {
+		w.decided = true
+		header := w.Header()
+		if header.Get("Content-Encoding") == "" && len(data) >= gzipMinSize {
+			header.Set("Content-Encoding", "gzip")
+			header.Del("Content-Length")
+			w.gz = gzip.NewWriter(w.ResponseWriter)
+		}
+	}
+	if w.gz != nil {
+		return w.gz.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// bodyAllowed 状态码是否允许响应体，1xx、204 和 304 不允许
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// close 写出gzip尾部数据
+func (w *gzipResponseWriter) close() {
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
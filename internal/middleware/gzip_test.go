@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newGzipServer 启动使用 GzipMiddleware 的测试服务，/echo 原样返回请求体，/big 返回较大的响应
+func newGzipServer(t *testing.T, maxBodySize int64) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GzipMiddleware(maxBodySize))
+	r.POST("/echo", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, "text/plain", body)
+	})
+	big := strings.Repeat("书签", gzipMinSize)
+	r.GET("/big", func(c *gin.Context) { c.String(http.StatusOK, big) })
+	r.HEAD("/big", func(c *gin.Context) { c.String(http.StatusOK, big) })
+	r.GET("/small", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	r.GET("/not-modified", func(c *gin.Context) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteString(big)
+	})
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// do 发送请求，显式设置 Accept-Encoding 时客户端不会自动解压响应
+func do(t *testing.T, req *http.Request) (*http.Response, []byte) {
+	t.Helper()
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("读取响应失败: %v", err)
+	}
+	return resp, body
+}
+
+func TestGzipRequestBody(t *testing.T) {
+	const maxBodySize = 1000
+	server := newGzipServer(t, maxBodySize)
+
+	valid := gzipBytes(t, bytes.Repeat([]byte("a"), 100))
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		status   int
+	}{
+		{"解压后原样传给处理函数", "gzip", valid, http.StatusOK},
+		{"x-gzip 等同 gzip", "x-gzip", valid, http.StatusOK},
+		{"未压缩的请求体不处理", "", []byte("plain"), http.StatusOK},
+		{"解压后恰好等于限制", "gzip", gzipBytes(t, bytes.Repeat([]byte("a"), maxBodySize)), http.StatusOK},
+		// 压缩后很小，解压后超过限制
+		{"解压后超过限制", "gzip", gzipBytes(t, bytes.Repeat([]byte("a"), maxBodySize+1)), http.StatusRequestEntityTooLarge},
+		{"不是gzip数据", "gzip", []byte("not gzip"), http.StatusBadRequest},
+		{"截断的gzip数据", "gzip", valid[:len(valid)-10], http.StatusBadRequest},
+		{"不支持的编码", "br", []byte("data"), http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, server.URL+"/echo", bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			resp, body := do(t, req)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("状态码 = %d，应为 %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			want := tt.body
+			if tt.encoding != "" {
+				zr, _ := gzip.NewReader(bytes.NewReader(tt.body))
+				want, _ = io.ReadAll(zr)
+			}
+			if !bytes.Equal(body, want) {
+				t.Errorf("处理函数收到 %d 字节，应为 %d 字节", len(body), len(want))
+			}
+		})
+	}
+}
+
+func TestGzipResponse(t *testing.T) {
+	server := newGzipServer(t, 1<<20)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		acceptEncoding string
+		status         int
+		gzipped        bool
+		emptyBody      bool
+	}{
+		{"接受gzip时压缩", http.MethodGet, "/big", "gzip, deflate", http.StatusOK, true, false},
+		{"q 值大于 0 时压缩", http.MethodGet, "/big", "br;q=1.0, gzip;q=0.5", http.StatusOK, true, false},
+		{"q=0 表示拒绝", http.MethodGet, "/big", "gzip;q=0", http.StatusOK, false, false},
+		{"q=0.0 表示拒绝", http.MethodGet, "/big", "deflate, gzip; q=0.0", http.StatusOK, false, false},
+		{"未声明gzip", http.MethodGet, "/big", "deflate", http.StatusOK, false, false},
+		{"响应较小时不压缩", http.MethodGet, "/small", "gzip", http.StatusOK, false, false},
+		{"HEAD 请求不压缩", http.MethodHead, "/big", "gzip", http.StatusOK, false, true},
+		{"304 不压缩且无响应体", http.MethodGet, "/not-modified", "gzip", http.StatusNotModified, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, server.URL+tt.path, nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			resp, body := do(t, req)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("状态码 = %d，应为 %d", resp.StatusCode, tt.status)
+			}
+			if got := resp.Header.Get("Content-Encoding") == "gzip"; got != tt.gzipped {
+				t.Fatalf("Content-Encoding = %q，是否压缩应为 %v", resp.Header.Get("Content-Encoding"), tt.gzipped)
+			}
+			if tt.emptyBody {
+				if len(body) != 0 {
+					t.Errorf("响应体 = %d 字节，应为空", len(body))
+				}
+				return
+			}
+			if tt.gzipped {
+				zr, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("响应体不是gzip数据: %v", err)
+				}
+				if body, err = io.ReadAll(zr); err != nil {
+					t.Fatalf("解压响应体失败: %v", err)
+				}
+				if resp.Header.Get("Vary") != "Accept-Encoding" {
+					t.Errorf("Vary = %q", resp.Header.Get("Vary"))
+				}
+			}
+			if tt.path == "/big" && string(body) != strings.Repeat("书签", gzipMinSize) {
+				t.Errorf("响应体内容不一致，长度 %d", len(body))
+			}
+		})
+	}
+}
@@ -2,17 +2,22 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // User 用户模型
 type User struct {
-	ID            uint      `json:"id" gorm:"primaryKey"`
-	Username      string    `json:"username" gorm:"uniqueIndex;size:50;not null"`
-	Password      string    `json:"password,omitempty" gorm:"size:255;not null"`
-	IsAdmin       bool      `json:"is_admin" gorm:"default:false"`
-	NeedChangePwd bool      `json:"need_change_pwd" gorm:"default:false"` // 首次登录需要修改密码
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	Username        string    `json:"username" gorm:"uniqueIndex;size:50;not null"`
+	Password        string    `json:"password,omitempty" gorm:"size:255;not null"`
+	IsAdmin         bool      `json:"is_admin" gorm:"default:false"`
+	NeedChangePwd   bool      `json:"need_change_pwd" gorm:"default:false"` // 首次登录需要修改密码
+	QuotaBytes      *int64    `json:"quota_bytes"`                          // 备份及历史版本总大小上限（字节），nil 使用默认配额，0 不限制
+	QuotaBackups    *int64    `json:"quota_backups"`                        // 备份数量上限，nil 使用默认配额，0 不限制
+	QuotaBackupSize *int64    `json:"quota_backup_size"`                    // 单个备份大小上限（字节），nil 使用默认配额，0 不限制
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
 
 // AccessKey 密钥模型
@@ -29,25 +34,115 @@ type AccessKey struct {
 
 // Backup 备份模型
 type Backup struct {
+	ID                 uint           `json:"id" gorm:"primaryKey"`
+	Name               string         `json:"name" gorm:"uniqueIndex:idx_backups_user_name,priority:2;size:255;not null"` // 备份名称，同一用户下唯一
+	Data               string         `json:"data,omitempty" gorm:"type:text"`                                            // JSON数据（早期明文存储时；读取后为解码的数据）
+	Payload            []byte         `json:"-" gorm:"type:blob"`                                                         // 早期压缩存储的数据
+	BlobHash           string         `json:"-" gorm:"size:64;index"`                                                     // 数据块哈希，数据存储于 Blob
+	Encoding           string         `json:"encoding" gorm:"size:20"`                                                    // 存储编码，空表示明文
+	Size               int64          `json:"size"`                                                                       // 原始数据大小（字节）
+	StoredSize         int64          `json:"stored_size"`                                                                // 实际存储大小（字节）
+	SyncCount          int            `json:"sync_count" gorm:"default:0"`                                                // 同步次数
+	Version            int            `json:"version" gorm:"default:0"`                                                   // 当前版本号
+	PasswordsEncrypted bool           `json:"passwords_encrypted" gorm:"default:true"`                                    // 密码是否加密
+	DataKey            []byte         `json:"-" gorm:"type:blob"`                                                         // 由主密钥包装的数据密钥，为空表示数据未加密
+	KeyID              string         `json:"-" gorm:"size:16;index"`                                                     // 包装数据密钥的主密钥标识
+	UserID             uint           `json:"user_id" gorm:"uniqueIndex:idx_backups_user_name,priority:1;not null"`
+	User               User           `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `json:"deleted_at" gorm:"index"` // 移至回收站的时间，非空表示已删除
+	ETag               string         `json:"etag,omitempty" gorm:"-"` // 当前版本的ETag，仅用于接口返回
+}
+
+// BackupVersion 备份历史版本，每次上传或恢复都会生成一个快照
+type BackupVersion struct {
 	ID                 uint      `json:"id" gorm:"primaryKey"`
-	Name               string    `json:"name" gorm:"uniqueIndex;size:255;not null"` // 备份名称，唯一值
-	Data               string    `json:"data,omitempty" gorm:"type:text"`           // JSON数据
-	Size               int64     `json:"size"`                                      // 备份大小（字节）
-	SyncCount          int       `json:"sync_count" gorm:"default:0"`               // 同步次数
-	PasswordsEncrypted bool      `json:"passwords_encrypted" gorm:"default:true"`   // 密码是否加密
-	UserID             uint      `json:"user_id" gorm:"not null"`
-	User               User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	BackupID           uint      `json:"backup_id" gorm:"uniqueIndex:idx_backup_version;not null"`
+	Version            int       `json:"version" gorm:"uniqueIndex:idx_backup_version;not null"` // 版本号，从1开始递增
+	Data               string    `json:"data,omitempty" gorm:"type:text"`                        // JSON数据（早期明文存储时；读取后为解码的数据）
+	Payload            []byte    `json:"-" gorm:"type:blob"`                                     // 早期压缩存储的数据
+	BlobHash           string    `json:"-" gorm:"size:64;index"`                                 // 数据块哈希，数据存储于 Blob
+	Encoding           string    `json:"encoding" gorm:"size:20"`                                // 存储编码，空表示明文
+	Size               int64     `json:"size"`                                                   // 原始数据大小（字节）
+	StoredSize         int64     `json:"stored_size"`                                            // 实际存储大小（字节）
+	PasswordsEncrypted bool      `json:"passwords_encrypted"`                                    // 密码是否加密
+	AccessKeyID        uint      `json:"access_key_id"`
+	AccessKey          string    `json:"access_key" gorm:"size:64"` // 产生该版本的密钥，管理后台操作时为空
+	UserID             uint      `json:"user_id" gorm:"not null"`   // 产生该版本的用户
 	CreatedAt          time.Time `json:"created_at"`
-	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// 备份共享权限
+const (
+	GrantRead  = "read"  // 只能下载
+	GrantWrite = "write" // 可以下载和上传
+)
+
+// BackupGrant 备份共享授权，被授权的用户可使用自己的密钥同步该备份
+type BackupGrant struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	BackupID   uint      `json:"backup_id" gorm:"uniqueIndex:idx_backup_grant;not null"`
+	UserID     uint      `json:"user_id" gorm:"uniqueIndex:idx_backup_grant;index;not null"` // 被授权的用户
+	User       User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Permission string    `json:"permission" gorm:"size:10;not null"` // read/write
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ShareLink 分区的公开分享链接，持有链接的任何人无需登录即可只读查看该分区
+type ShareLink struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Token       string     `json:"token" gorm:"uniqueIndex;size:64;not null"`
+	BackupID    uint       `json:"backup_id" gorm:"index;not null"`
+	PartitionID int        `json:"partition_id"`
+	Password    string     `json:"-" gorm:"size:255"` // 访问密码，为空表示无需密码
+	HasPassword bool       `json:"has_password" gorm:"-"`
+	ExpiresAt   *time.Time `json:"expires_at"` // 过期时间，为空表示永久有效
+	ViewCount   int        `json:"view_count" gorm:"default:0"`
+	UserID      uint       `json:"user_id" gorm:"not null"` // 创建链接的用户
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// RetentionPolicy 备份单独设置的历史版本保留策略，未设置时使用全局策略
+type RetentionPolicy struct {
+	BackupID    uint      `json:"backup_id" gorm:"primaryKey"`
+	KeepLast    int       `json:"keep_last"`    // 保留最近 N 个版本
+	KeepDaily   int       `json:"keep_daily"`   // 最近 N 天每天保留一个版本
+	KeepWeekly  int       `json:"keep_weekly"`  // 最近 N 周每周保留一个版本
+	KeepMonthly int       `json:"keep_monthly"` // 最近 N 个月每月保留一个版本
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Blob 备份数据块，按内容哈希去重存储（加密时只在同一备份内去重），被备份及其历史版本引用
+type Blob struct {
+	Hash       string    `json:"hash" gorm:"primaryKey;size:64"`  // 原始数据的SHA-256（十六进制），加密时为以数据密钥派生的 HMAC-SHA256
+	Backend    string    `json:"backend" gorm:"size:20"`          // 存储后端：db/fs/s3，空表示 db
+	Data       string    `json:"-" gorm:"type:text"`              // JSON数据（存于数据库且明文存储时）
+	Payload    []byte    `json:"-" gorm:"type:blob"`              // 压缩或加密后的数据（存于数据库且压缩或加密存储时）
+	Encoding   string    `json:"encoding" gorm:"size:20"`         // 存储编码，空表示明文
+	OwnerID    uint      `json:"owner_id" gorm:"default:0;index"` // 以其数据密钥加密该数据块的备份，0 表示未加密
+	Size       int64     `json:"size"`                            // 原始数据大小（字节）
+	StoredSize int64     `json:"stored_size"`                     // 实际存储大小（字节）
+	RefCount   int       `json:"ref_count" gorm:"default:0"`      // 引用计数，归零时删除
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Setting 管理员可在运行时修改的全局配置，值为JSON
+type Setting struct {
+	Key       string    `json:"key" gorm:"primaryKey;size:64"`
+	Value     string    `json:"value" gorm:"type:text"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // SyncRecord 同步记录模型
 type SyncRecord struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
 	BackupName  string    `json:"backup_name" gorm:"size:255;not null"`
-	TransType   string    `json:"trans_type" gorm:"size:20;not null"` // upload/download
+	TransType   string    `json:"trans_type" gorm:"size:20;not null"` // upload/download/patch/edit/restore/repair/rename/duplicate/move/import
 	AccessKeyID uint      `json:"access_key_id"`
-	AccessKey   string    `json:"access_key" gorm:"size:64"` // 使用的密钥
+	AccessKey   string    `json:"access_key" gorm:"size:64"`        // 使用的密钥
+	Detail      string    `json:"detail,omitempty" gorm:"size:512"` // 操作详情，如重命名前的名称、复制来源
 	UserID      uint      `json:"user_id" gorm:"not null"`
 	User        User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	CreatedAt   time.Time `json:"created_at"`
@@ -0,0 +1,157 @@
+// Package quota 提供用户存储配额的计算与检查
+package quota
+
+import (
+	"fmt"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/settings"
+)
+
+// settingKey 默认配额在 settings 表中的键
+const settingKey = "quota.default"
+
+// 超出的配额类型
+const (
+	LimitBytes      = "max_bytes"
+	LimitBackups    = "max_backups"
+	LimitBackupSize = "max_backup_size"
+)
+
+// Limits 配额上限，0 表示不限制
+type Limits struct {
+	MaxBytes      int64 `json:"max_bytes"`       // 备份及历史版本总大小（字节）
+	MaxBackups    int64 `json:"max_backups"`     // 备份数量
+	MaxBackupSize int64 `json:"max_backup_size"` // 单个备份大小（字节）
+}
+
+// Usage 当前用量
+type Usage struct {
+	UsedBytes   int64 `json:"used_bytes"`
+	UsedBackups int64 `json:"used_backups"`
+}
+
+// Status 配额及用量
+type Status struct {
+	Limits
+	Usage
+}
+
+// Exceeded 超出配额的详细信息
+type Exceeded struct {
+	Limit     string `json:"limit"`     // 超出的配额类型
+	Max       int64  `json:"max"`       // 配额上限
+	Used      int64  `json:"used"`      // 当前用量
+	Requested int64  `json:"requested"` // 本次请求需要的量
+	Message   string `json:"-"`
+}
+
+// Default 获取默认配额，未设置时不限制
+func Default() (Limits, error) {
+	var limits Limits
+	_, err := settings.Get(settingKey, &limits)
+	return limits, err
+}
+
+// SetDefault 设置默认配额
+func SetDefault(limits Limits) error {
+	return settings.Set(settingKey, limits)
+}
+
+// ForUser 获取用户生效的配额：用户单独设置的值优先，否则使用默认配额
+func ForUser(user *models.User) (Limits, error) {
+	limits, err := Default()
+	if err != nil {
+		return limits, err
+	}
+	if user.QuotaBytes != nil {
+		limits.MaxBytes = *user.QuotaBytes
+	}
+	if user.QuotaBackups != nil {
+		limits.MaxBackups = *user.QuotaBackups
+	}
+	if user.QuotaBackupSize != nil {
+		limits.MaxBackupSize = *user.QuotaBackupSize
+	}
+	return limits, nil
+}
+
+// UsageOf 统计用户用量：备份当前数据加上除当前版本外的历史版本的原始大小，回收站中的备份同样计入
+func UsageOf(userID uint) (Usage, error) {
+	var usage Usage
+	var current struct {
+		Count int64
+		Bytes int64
+	}
+	if err := database.DB.Unscoped().Model(&models.Backup{}).Select("COUNT(*) AS count, COALESCE(SUM(size), 0) AS bytes").
+		Where("user_id = ?", userID).Scan(&current).Error; err != nil {
+		return usage, err
+	}
+
+	var history int64
+	if err := database.DB.Table("backup_versions").Select("COALESCE(SUM(backup_versions.size), 0)").
+		Joins("JOIN backups ON backups.id = backup_versions.backup_id").
+		Where("backups.user_id = ? AND backup_versions.version <> backups.version", userID).
+		Scan(&history).Error; err != nil {
+		return usage, err
+	}
+
+	usage.UsedBackups = current.Count
+	usage.UsedBytes = current.Bytes + history
+	return usage, nil
+}
+
+// BackupUsage 统计单个备份计入配额的用量：当前数据加上除当前版本外的历史版本
+func BackupUsage(backup *models.Backup) (int64, error) {
+	var history int64
+	err := database.DB.Model(&models.BackupVersion{}).Select("COALESCE(SUM(size), 0)").
+		Where("backup_id = ? AND version <> ?", backup.ID, backup.Version).Scan(&history).Error
+	return backup.Size + history, err
+}
+
+// StatusOf 获取用户的配额及用量
+func StatusOf(user *models.User) (*Status, error) {
+	limits, err := ForUser(user)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := UsageOf(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Status{Limits: limits, Usage: usage}, nil
+}
+
+// Check 检查用户写入 size 字节的新数据是否超出配额，newBackup 表示将创建新备份
+// 更新备份时原数据转为历史版本继续计入用量，因此新增用量始终为 size
+func Check(userID uint, newBackup bool, size int64) (*Exceeded, error) {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	status, err := StatusOf(&user)
+	if err != nil {
+		return nil, err
+	}
+
+	if status.MaxBackupSize > 0 && size > status.MaxBackupSize {
+		return &Exceeded{
+			Limit: LimitBackupSize, Max: status.MaxBackupSize, Used: 0, Requested: size,
+			Message: fmt.Sprintf("备份大小 %d 字节超过单个备份上限 %d 字节", size, status.MaxBackupSize),
+		}, nil
+	}
+	if newBackup && status.MaxBackups > 0 && status.UsedBackups+1 > status.MaxBackups {
+		return &Exceeded{
+			Limit: LimitBackups, Max: status.MaxBackups, Used: status.UsedBackups, Requested: 1,
+			Message: fmt.Sprintf("备份数量已达上限 %d 个", status.MaxBackups),
+		}, nil
+	}
+	if status.MaxBytes > 0 && status.UsedBytes+size > status.MaxBytes {
+		return &Exceeded{
+			Limit: LimitBytes, Max: status.MaxBytes, Used: status.UsedBytes, Requested: size,
+			Message: fmt.Sprintf("存储空间不足：已用 %d 字节，本次需要 %d 字节，上限 %d 字节", status.UsedBytes, size, status.MaxBytes),
+		}, nil
+	}
+	return nil, nil
+}
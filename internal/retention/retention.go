@@ -0,0 +1,256 @@
+// Package retention 按保留策略清理备份的历史版本
+package retention
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/settings"
+	"itab-backend/internal/store"
+
+	"gorm.io/gorm"
+)
+
+// settingKey 全局保留策略在 settings 表中的键
+const settingKey = "retention.default"
+
+// 策略来源
+const (
+	SourceDefault = "default" // 全局策略
+	SourceBackup  = "backup"  // 备份单独设置的策略
+)
+
+// Policy 历史版本保留策略，各项为 0 表示不按该规则保留；全部为 0 时保留所有版本
+// 按天/周/月的规则以当前时间为基准，保留窗口内每个周期最新的一个版本
+type Policy struct {
+	KeepLast    int `json:"keep_last"`    // 保留最近 N 个版本
+	KeepDaily   int `json:"keep_daily"`   // 最近 N 天每天保留一个版本
+	KeepWeekly  int `json:"keep_weekly"`  // 最近 N 周每周保留一个版本
+	KeepMonthly int `json:"keep_monthly"` // 最近 N 个月每月保留一个版本
+}
+
+// Enabled 策略是否会删除版本
+func (p Policy) Enabled() bool {
+	return p.KeepLast > 0 || p.KeepDaily > 0 || p.KeepWeekly > 0 || p.KeepMonthly > 0
+}
+
+// Validate 校验策略参数
+func (p Policy) Validate() error {
+	if p.KeepLast < 0 || p.KeepDaily < 0 || p.KeepWeekly < 0 || p.KeepMonthly < 0 {
+		return errors.New("保留数量不能为负数")
+	}
+	return nil
+}
+
+// VersionInfo 版本摘要
+type VersionInfo struct {
+	Version   int       `json:"version"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Plan 单个备份的清理计划
+type Plan struct {
+	BackupID uint          `json:"backup_id"`
+	Name     string        `json:"name"`
+	UserID   uint          `json:"user_id"`
+	Policy   Policy        `json:"policy"`
+	Source   string        `json:"source"` // default/backup
+	Kept     int           `json:"kept"`
+	Delete   []VersionInfo `json:"delete"`
+}
+
+// Default 获取全局保留策略，未设置时保留所有版本
+func Default() (Policy, error) {
+	var policy Policy
+	_, err := settings.Get(settingKey, &policy)
+	return policy, err
+}
+
+// SetDefault 设置全局保留策略
+func SetDefault(policy Policy) error {
+	return settings.Set(settingKey, policy)
+}
+
+// ForBackup 获取备份生效的保留策略及其来源
+func ForBackup(backupID uint) (Policy, string, error) {
+	var rows []models.RetentionPolicy
+	if err := database.DB.Where("backup_id = ?", backupID).Limit(1).Find(&rows).Error; err != nil {
+		return Policy{}, "", err
+	}
+	if len(rows) > 0 {
+		r := rows[0]
+		return Policy{KeepLast: r.KeepLast, KeepDaily: r.KeepDaily, KeepWeekly: r.KeepWeekly, KeepMonthly: r.KeepMonthly}, SourceBackup, nil
+	}
+	policy, err := Default()
+	return policy, SourceDefault, err
+}
+
+// SetForBackup 为备份单独设置保留策略
+func SetForBackup(backupID uint, policy Policy) error {
+	return database.DB.Save(&models.RetentionPolicy{
+		BackupID:    backupID,
+		KeepLast:    policy.KeepLast,
+		KeepDaily:   policy.KeepDaily,
+		KeepWeekly:  policy.KeepWeekly,
+		KeepMonthly: policy.KeepMonthly,
+	}).Error
+}
+
+// ClearForBackup 删除备份单独设置的保留策略，恢复使用全局策略
+func ClearForBackup(backupID uint) error {
+	return database.DB.Where("backup_id = ?", backupID).Delete(&models.RetentionPolicy{}).Error
+}
+
+// selectExpired 按策略挑选需要删除的版本，versions 需按版本号从新到旧排列，当前版本始终保留
+func selectExpired(versions []models.BackupVersion, current int, policy Policy, now time.Time) []models.BackupVersion {
+	if !policy.Enabled() {
+		return nil
+	}
+
+	keep := make(map[int]bool)
+	keep[current] = true
+
+	for i := 0; i < policy.KeepLast && i < len(versions); i++ {
+		keep[versions[i].Version] = true
+	}
+
+	// 每个周期保留最新的一个版本（versions 从新到旧，周期内第一个即最新）
+	keepPeriodic := func(n int, cutoff time.Time, bucket func(time.Time) string) {
+		if n <= 0 {
+			return
+		}
+		seen := make(map[string]bool)
+		for _, v := range versions {
+			t := v.CreatedAt.In(now.Location())
+			if t.Before(cutoff) {
+				continue
+			}
+			key := bucket(t)
+			if !seen[key] {
+				seen[key] = true
+				keep[v.Version] = true
+			}
+		}
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	keepPeriodic(policy.KeepDaily, today.AddDate(0, 0, -(policy.KeepDaily-1)), func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	keepPeriodic(policy.KeepWeekly, monday.AddDate(0, 0, -7*(policy.KeepWeekly-1)), func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	keepPeriodic(policy.KeepMonthly, thisMonth.AddDate(0, -(policy.KeepMonthly-1), 0), func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+
+	var expired []models.BackupVersion
+	for _, v := range versions {
+		if !keep[v.Version] {
+			expired = append(expired, v)
+		}
+	}
+	return expired
+}
+
+// planBackup 计算单个备份的清理计划
+func planBackup(backup *models.Backup, now time.Time) (*Plan, error) {
+	policy, source, err := ForBackup(backup.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []models.BackupVersion
+	if err := database.DB.Select("id, version, size, created_at").
+		Where("backup_id = ?", backup.ID).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		BackupID: backup.ID,
+		Name:     backup.Name,
+		UserID:   backup.UserID,
+		Policy:   policy,
+		Source:   source,
+		Delete:   []VersionInfo{},
+	}
+	expired := selectExpired(versions, backup.Version, policy, now)
+	for _, v := range expired {
+		plan.Delete = append(plan.Delete, VersionInfo{Version: v.Version, Size: v.Size, CreatedAt: v.CreatedAt})
+	}
+	plan.Kept = len(versions) - len(expired)
+	return plan, nil
+}
+
+// Run 对所有备份（backupID 非 0 时只处理该备份）计算清理计划，dryRun 为 false 时执行删除
+// 只返回有版本需要删除的计划
+func Run(backupID uint, dryRun bool) ([]Plan, error) {
+	now := time.Now()
+	plans := []Plan{}
+
+	query := database.DB.Select("id, name, user_id, version")
+	if backupID != 0 {
+		query = query.Where("id = ?", backupID)
+	}
+
+	var backups []models.Backup
+	err := query.FindInBatches(&backups, 50, func(_ *gorm.DB, _ int) error {
+		for i := range backups {
+			plan, err := planBackup(&backups[i], now)
+			if err != nil {
+				return err
+			}
+			if len(plan.Delete) == 0 {
+				continue
+			}
+			if !dryRun {
+				versions := make([]int, len(plan.Delete))
+				for j, v := range plan.Delete {
+					versions[j] = v.Version
+				}
+				err := database.DB.Transaction(func(tx *gorm.DB) error {
+					_, err := store.DeleteVersions(tx, plan.BackupID, versions)
+					return err
+				})
+				if err != nil {
+					return fmt.Errorf("清理备份 %d 的历史版本失败: %v", plan.BackupID, err)
+				}
+			}
+			plans = append(plans, *plan)
+		}
+		return nil
+	}).Error
+	return plans, err
+}
+
+// StartRetention 启动历史版本清理定时任务，每天凌晨执行一次
+func StartRetention() {
+	go func() {
+		for {
+			now := time.Now()
+			next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 10, 0, 0, now.Location())
+			time.Sleep(next.Sub(now))
+
+			plans, err := Run(0, false)
+			if err != nil {
+				log.Printf("[保留策略] 清理历史版本失败: %v", err)
+				continue
+			}
+			deleted := 0
+			for _, p := range plans {
+				deleted += len(p.Delete)
+			}
+			if deleted > 0 {
+				log.Printf("[保留策略] 已清理 %d 个备份的 %d 个历史版本", len(plans), deleted)
+			}
+		}
+	}()
+}
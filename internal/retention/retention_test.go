@@ -0,0 +1,268 @@
+package retention
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/store"
+
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// now 2024-03-13 周三 12:00，本周一为 03-11
+var now = time.Date(2024, 3, 13, 12, 0, 0, 0, time.UTC)
+
+func at(year int, month time.Month, day, hour, min, sec int) time.Time {
+	return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+}
+
+// versionsAt 按从新到旧的时间生成版本，版本号从 len(times) 递减到 1
+func versionsAt(times ...time.Time) []models.BackupVersion {
+	versions := make([]models.BackupVersion, len(times))
+	for i, t := range times {
+		versions[i] = models.BackupVersion{Version: len(times) - i, CreatedAt: t}
+	}
+	return versions
+}
+
+func TestSelectExpired(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Policy
+		versions []models.BackupVersion
+		current  int
+		now      time.Time // 为零值时使用 now
+		want     []int     // 应删除的版本号，从新到旧
+	}{
+		{
+			name:     "未启用策略时保留全部",
+			policy:   Policy{},
+			versions: versionsAt(now, now.Add(-time.Hour), now.AddDate(-1, 0, 0)),
+			current:  3,
+			want:     nil,
+		},
+		{
+			name:     "保留最近 N 个",
+			policy:   Policy{KeepLast: 2},
+			versions: versionsAt(now, now.Add(-time.Hour), now.Add(-2*time.Hour), now.Add(-3*time.Hour), now.Add(-4*time.Hour)),
+			current:  5,
+			want:     []int{3, 2, 1},
+		},
+		{
+			name:     "保留数量超过版本数",
+			policy:   Policy{KeepLast: 10},
+			versions: versionsAt(now, now.Add(-time.Hour)),
+			current:  2,
+			want:     nil,
+		},
+		{
+			name:   "当前版本不在任何规则内也保留",
+			policy: Policy{KeepDaily: 1},
+			// 恢复操作后当前版本可能不是最新创建的版本
+			versions: versionsAt(now, now.Add(-time.Hour), now.AddDate(0, 0, -30)),
+			current:  1,
+			want:     []int{2},
+		},
+		{
+			name:   "按天：每天保留最新一个",
+			policy: Policy{KeepDaily: 2},
+			versions: versionsAt(
+				at(2024, 3, 13, 11, 0, 0),
+				at(2024, 3, 13, 8, 0, 0),
+				at(2024, 3, 12, 23, 59, 59),
+				at(2024, 3, 12, 0, 0, 0),
+				at(2024, 3, 11, 23, 59, 59),
+			),
+			current: 5,
+			want:    []int{4, 2, 1},
+		},
+		{
+			name:   "按天：窗口起点当天零点在窗口内",
+			policy: Policy{KeepDaily: 2},
+			versions: versionsAt(
+				at(2024, 3, 13, 11, 0, 0),
+				at(2024, 3, 12, 0, 0, 0),
+				at(2024, 3, 12, 0, 0, 0).Add(-time.Nanosecond),
+			),
+			current: 3,
+			want:    []int{1},
+		},
+		{
+			name:   "按周：以周一为一周开始",
+			policy: Policy{KeepWeekly: 2},
+			versions: versionsAt(
+				at(2024, 3, 13, 9, 0, 0),    // 本周
+				at(2024, 3, 11, 0, 0, 0),    // 本周一零点
+				at(2024, 3, 10, 23, 59, 59), // 上周日
+				at(2024, 3, 4, 0, 0, 0),     // 上周一零点，窗口起点
+				at(2024, 3, 3, 23, 59, 59),  // 窗口之外
+			),
+			current: 5,
+			want:    []int{4, 2, 1},
+		},
+		{
+			name:   "按周：跨年的 ISO 周",
+			policy: Policy{KeepWeekly: 1},
+			versions: versionsAt(
+				at(2025, 1, 1, 10, 0, 0),   // 2025-W01
+				at(2024, 12, 30, 0, 0, 0),  // 同属 2025-W01
+				at(2024, 12, 29, 23, 0, 0), // 2024-W52，窗口之外
+			),
+			current: 3,
+			now:     at(2025, 1, 1, 12, 0, 0),
+			want:    []int{2, 1},
+		},
+		{
+			name:   "按月：每月保留最新一个",
+			policy: Policy{KeepMonthly: 3},
+			versions: versionsAt(
+				at(2024, 3, 2, 0, 0, 0),
+				at(2024, 2, 29, 23, 0, 0),
+				at(2024, 2, 1, 0, 0, 0),
+				at(2024, 1, 1, 0, 0, 0), // 窗口起点
+				at(2023, 12, 31, 23, 59, 59),
+			),
+			current: 5,
+			want:    []int{3, 1},
+		},
+		{
+			name:   "多条规则取并集",
+			policy: Policy{KeepLast: 1, KeepDaily: 1, KeepMonthly: 2},
+			versions: versionsAt(
+				at(2024, 3, 13, 11, 0, 0),
+				at(2024, 3, 13, 10, 0, 0),
+				at(2024, 3, 1, 0, 0, 0),
+				at(2024, 2, 20, 0, 0, 0),
+				at(2024, 2, 10, 0, 0, 0),
+			),
+			current: 5,
+			want:    []int{4, 3, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := now
+			if !tt.now.IsZero() {
+				base = tt.now
+			}
+			var got []int
+			for _, v := range selectExpired(tt.versions, tt.current, tt.policy, base) {
+				got = append(got, v.Version)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("删除 %v，应删除 %v", got, tt.want)
+			}
+			for _, v := range got {
+				if v == tt.current {
+					t.Errorf("当前版本 %d 被选中删除", tt.current)
+				}
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	if err := database.InitDB(filepath.Join(t.TempDir(), "itab.db")); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	backup := &models.Backup{Name: "home", Data: `{"v":1}`, UserID: 1}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := store.Create(tx, backup, 1, 0, ""); err != nil {
+			return err
+		}
+		for v := 2; v <= 4; v++ {
+			backup.Data = fmt.Sprintf(`{"v":%d}`, v)
+			if err := store.SaveRevision(tx, backup, 1, 0, ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("创建备份失败: %v", err)
+	}
+	untouched := &models.Backup{Name: "work", Data: `{"w":1}`, UserID: 1}
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return store.Create(tx, untouched, 1, 0, "")
+	}); err != nil {
+		t.Fatalf("创建备份失败: %v", err)
+	}
+	if err := SetForBackup(backup.ID, Policy{KeepLast: 2}); err != nil {
+		t.Fatalf("设置保留策略失败: %v", err)
+	}
+
+	versionCount := func() int64 {
+		var n int64
+		database.DB.Model(&models.BackupVersion{}).Where("backup_id = ?", backup.ID).Count(&n)
+		return n
+	}
+	refTotal := func() int64 {
+		var n int64
+		database.DB.Model(&models.Blob{}).Select("COALESCE(SUM(ref_count), 0)").Scan(&n)
+		return n
+	}
+	refsBefore := refTotal()
+
+	plans, err := Run(0, true)
+	if err != nil {
+		t.Fatalf("Run(dryRun): %v", err)
+	}
+	if len(plans) != 1 || plans[0].BackupID != backup.ID || len(plans[0].Delete) != 2 || plans[0].Kept != 2 {
+		t.Fatalf("清理计划 = %+v，应删除备份 %d 的 2 个版本", plans, backup.ID)
+	}
+	if plans[0].Source != SourceBackup {
+		t.Errorf("策略来源 = %s，应为 %s", plans[0].Source, SourceBackup)
+	}
+	if n := versionCount(); n != 4 {
+		t.Errorf("预览后版本数 = %d，不应删除", n)
+	}
+	if n := refTotal(); n != refsBefore {
+		t.Errorf("预览后引用总数 = %d，应为 %d", n, refsBefore)
+	}
+
+	plans, err = Run(backup.ID, false)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(plans) != 1 || len(plans[0].Delete) != 2 {
+		t.Fatalf("清理结果 = %+v", plans)
+	}
+	if n := versionCount(); n != 2 {
+		t.Errorf("清理后版本数 = %d，应为 2", n)
+	}
+	if n := refTotal(); n != refsBefore-2 {
+		t.Errorf("清理后引用总数 = %d，应为 %d", n, refsBefore-2)
+	}
+	var blobs int64
+	database.DB.Model(&models.Blob{}).Count(&blobs)
+	if blobs != 3 {
+		t.Errorf("清理后数据块数 = %d，应为 3（版本 3、4 及另一个备份）", blobs)
+	}
+	if err := store.Load(backup); err != nil || backup.Data != `{"v":4}` {
+		t.Errorf("当前数据 = %q, %v", backup.Data, err)
+	}
+
+	if plans, err := Run(0, false); err != nil || len(plans) != 0 {
+		t.Errorf("再次清理 = %+v, %v，应无需删除", plans, err)
+	}
+}
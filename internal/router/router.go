@@ -7,6 +7,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// maxRequestBodySize gzip请求体解压后的最大大小
+const maxRequestBodySize = 64 << 20
+
 // SetupRouter 配置路由
 func SetupRouter() *gin.Engine {
 	r := gin.Default()
@@ -18,8 +21,9 @@ func SetupRouter() *gin.Engine {
 	// CORS中间件
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, x-access-key, x-secret-key")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, x-access-key, x-secret-key, Content-Encoding, If-Match, If-None-Match, X-Share-Password")
+		c.Header("Access-Control-Expose-Headers", "ETag, X-Skipped-Encrypted")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -35,18 +39,24 @@ func SetupRouter() *gin.Engine {
 	// 公开接口
 	r.POST("/api/login", handlers.Login)
 
+	// 分区公开分享（无需登录）
+	r.GET("/s/:token", handlers.ViewSharedPartition)
+	r.POST("/s/:token", handlers.ViewSharedPartition)
+	r.GET("/api/share/:token", handlers.GetSharedPartition)
+
 	// 远程同步接口（使用AccessKey认证）
 	sync := r.Group("/api/sync")
-	sync.Use(middleware.AccessKeyMiddleware())
+	sync.Use(middleware.AccessKeyMiddleware(), middleware.GzipMiddleware(maxRequestBodySize))
 	{
 		sync.GET("/list", handlers.SyncList)
 		sync.GET("/download/:id", handlers.SyncDownload)
 		sync.POST("/upload", handlers.SyncUpload)
+		sync.PATCH("/backups/:name", handlers.SyncPatch)
 	}
 
 	// 需要登录的接口
 	api := r.Group("/api")
-	api.Use(middleware.AuthMiddleware())
+	api.Use(middleware.AuthMiddleware(), middleware.GzipMiddleware(maxRequestBodySize))
 	{
 		// 用户信息
 		api.GET("/me", handlers.GetCurrentUser)
@@ -61,8 +71,56 @@ func SetupRouter() *gin.Engine {
 		// 备份管理
 		api.GET("/backups", handlers.ListBackups)
 		api.GET("/backups/:id", handlers.GetBackup)
+		api.POST("/backups/import", handlers.ImportBookmarks)
+		api.POST("/backups/import/preview", handlers.PreviewImportBookmarks)
 		api.DELETE("/backups/:id", handlers.DeleteBackup)
 		api.GET("/backups/:id/download", handlers.DownloadBackup)
+		api.POST("/backups/:id/rename", handlers.RenameBackup)
+		api.POST("/backups/:id/duplicate", handlers.DuplicateBackup)
+		api.POST("/backups/:id/import", handlers.ImportBookmarksInto)
+		api.POST("/backups/:id/import/preview", handlers.PreviewImportBookmarksInto)
+		api.GET("/backups/:id/passwords/export", handlers.ExportPasswords)
+		api.POST("/backups/:id/passwords/import", handlers.ImportPasswords)
+		api.GET("/backups/:id/versions", handlers.ListBackupVersions)
+		api.GET("/backups/:id/versions/:v", handlers.GetBackupVersion)
+		api.POST("/backups/:id/versions/:v/restore", handlers.RestoreBackupVersion)
+		api.GET("/backups/:id/diff", handlers.DiffBackup)
+		api.GET("/backups/:id/grants", handlers.ListBackupGrants)
+		api.PUT("/backups/:id/grants", handlers.SetBackupGrant)
+		api.DELETE("/backups/:id/grants/:uid", handlers.DeleteBackupGrant)
+		api.GET("/backups/:id/share-links", handlers.ListShareLinks)
+		api.POST("/backups/:id/share-links", handlers.CreateShareLink)
+		api.DELETE("/backups/:id/share-links/:lid", handlers.DeleteShareLink)
+		api.GET("/backups/:id/retention", handlers.GetBackupRetention)
+		api.PUT("/backups/:id/retention", handlers.UpdateBackupRetention)
+		api.DELETE("/backups/:id/retention", handlers.DeleteBackupRetention)
+
+		// 备份内条目管理
+		api.GET("/backups/:id/shortcuts", handlers.ListShortcuts)
+		api.POST("/backups/:id/shortcuts", handlers.CreateShortcut)
+		api.GET("/backups/:id/shortcuts/:eid", handlers.GetShortcut)
+		api.PUT("/backups/:id/shortcuts/:eid", handlers.UpdateShortcut)
+		api.DELETE("/backups/:id/shortcuts/:eid", handlers.DeleteShortcut)
+		api.GET("/backups/:id/folders", handlers.ListFolders)
+		api.POST("/backups/:id/folders", handlers.CreateFolder)
+		api.GET("/backups/:id/folders/:eid", handlers.GetFolder)
+		api.PUT("/backups/:id/folders/:eid", handlers.UpdateFolder)
+		api.DELETE("/backups/:id/folders/:eid", handlers.DeleteFolder)
+		api.GET("/backups/:id/partitions", handlers.ListPartitions)
+		api.POST("/backups/:id/partitions", handlers.CreatePartition)
+		api.GET("/backups/:id/partitions/:eid", handlers.GetPartition)
+		api.PUT("/backups/:id/partitions/:eid", handlers.UpdatePartition)
+		api.DELETE("/backups/:id/partitions/:eid", handlers.DeletePartition)
+		api.GET("/backups/:id/search-engines", handlers.ListSearchEngines)
+		api.POST("/backups/:id/search-engines", handlers.CreateSearchEngine)
+		api.GET("/backups/:id/search-engines/:eid", handlers.GetSearchEngine)
+		api.PUT("/backups/:id/search-engines/:eid", handlers.UpdateSearchEngine)
+		api.DELETE("/backups/:id/search-engines/:eid", handlers.DeleteSearchEngine)
+
+		// 回收站
+		api.GET("/trash", handlers.ListTrash)
+		api.POST("/trash/:id/restore", handlers.RestoreTrashedBackup)
+		api.DELETE("/trash/:id", handlers.PurgeTrashedBackup)
 
 		// 同步记录
 		api.GET("/sync-records", handlers.ListSyncRecords)
@@ -81,6 +139,25 @@ func SetupRouter() *gin.Engine {
 			admin.PUT("/users/:id", handlers.UpdateUser)
 			admin.DELETE("/users/:id", handlers.DeleteUser)
 
+			// 备份移动
+			admin.POST("/backups/:id/move", handlers.MoveBackup)
+
+			// 存储配额
+			admin.GET("/settings/quota", handlers.GetDefaultQuota)
+			admin.PUT("/settings/quota", handlers.UpdateDefaultQuota)
+
+			// 历史版本保留策略
+			admin.GET("/settings/retention", handlers.GetDefaultRetention)
+			admin.PUT("/settings/retention", handlers.UpdateDefaultRetention)
+			admin.GET("/retention/preview", handlers.PreviewRetention)
+
+			// 回收站设置
+			admin.GET("/settings/trash", handlers.GetTrashConfig)
+			admin.PUT("/settings/trash", handlers.UpdateTrashConfig)
+
+			// 备份检查与修复
+			admin.POST("/fsck", handlers.FsckBackups)
+
 			// 日志管理
 			admin.GET("/logs", handlers.GetLogFiles)
 			admin.POST("/logs/clean", handlers.CleanLogs)
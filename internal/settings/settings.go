@@ -0,0 +1,40 @@
+// Package settings 提供管理员可在运行时修改的全局配置，以JSON形式保存在 settings 表
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Get 读取配置到 v，配置不存在时返回 false 且不修改 v
+func Get(key string, v interface{}) (bool, error) {
+	var setting models.Setting
+	if err := database.DB.Where("key = ?", key).First(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := json.Unmarshal([]byte(setting.Value), v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Set 保存配置
+func Set(key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&models.Setting{Key: key, Value: string(value)}).Error
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FSBackend 本地目录存储，对象按键的前两级前缀分目录保存，如 ab/cd/abcd...
+type FSBackend struct {
+	dir string
+}
+
+// NewFSBackend 创建本地目录存储
+func NewFSBackend(dir string) (*FSBackend, error) {
+	if dir == "" {
+		return nil, errors.New("未指定存储目录")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建存储目录失败: %v", err)
+	}
+	return &FSBackend{dir: dir}, nil
+}
+
+// path 对象在目录中的路径
+func (b *FSBackend) path(key string) string {
+	if len(key) < 4 {
+		return filepath.Join(b.dir, key)
+	}
+	return filepath.Join(b.dir, key[:2], key[2:4], key)
+}
+
+// Put 先写入临时文件再重命名，避免读到写了一半的对象
+func (b *FSBackend) Put(key string, data []byte) error {
+	path := b.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get 读取对象
+func (b *FSBackend) Get(key string) ([]byte, error) {
+	data, err := os.ReadFile(b.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Delete 删除对象
+func (b *FSBackend) Delete(key string) error {
+	err := os.Remove(b.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// List 遍历目录中的对象，跳过写入中的临时文件
+func (b *FSBackend) List(fn func(key string, modTime time.Time) error) error {
+	return filepath.WalkDir(b.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.Contains(d.Name(), ".tmp") {
+			return err
+		}
+		info, err := d.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fn(d.Name(), info.ModTime())
+	})
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFSBackendRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	b, err := NewFSBackend(dir)
+	if err != nil {
+		t.Fatalf("NewFSBackend: %v", err)
+	}
+	key := "abcdef0123"
+
+	if _, err := b.Get(key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("读取不存在的对象 = %v，应为 ErrNotFound", err)
+	}
+	if err := b.Put(key, []byte("v1")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ab", "cd", key)); err != nil {
+		t.Errorf("对象应按前缀分目录保存: %v", err)
+	}
+	// 覆盖写入
+	if err := b.Put(key, []byte("v2")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if data, err := b.Get(key); err != nil || string(data) != "v2" {
+		t.Errorf("Get = %q, %v，应为 v2", data, err)
+	}
+
+	if err := b.Delete(key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := b.Get(key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("删除后读取 = %v，应为 ErrNotFound", err)
+	}
+	if err := b.Delete(key); err != nil {
+		t.Errorf("删除不存在的对象 = %v，不应报错", err)
+	}
+}
+
+func TestFSBackendList(t *testing.T) {
+	dir := t.TempDir()
+	b, err := NewFSBackend(dir)
+	if err != nil {
+		t.Fatalf("NewFSBackend: %v", err)
+	}
+	for _, key := range []string{"abcdef", "abcd99", "123456"} {
+		if err := b.Put(key, []byte(key)); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+	// 写入中断留下的临时文件不算对象
+	if err := os.WriteFile(filepath.Join(dir, "12", "34", "123456.tmp1"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	start := time.Now().Add(-time.Minute)
+	err = b.List(func(key string, modTime time.Time) error {
+		if modTime.Before(start) {
+			t.Errorf("对象 %s 的修改时间 = %v", key, modTime)
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	sort.Strings(keys)
+	if want := []string{"123456", "abcd99", "abcdef"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("List = %v，应为 %v", keys, want)
+	}
+
+	stop := errors.New("stop")
+	if err := b.List(func(string, time.Time) error { return stop }); err != stop {
+		t.Errorf("fn 返回错误时 List = %v，应返回该错误", err)
+	}
+}
@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config S3兼容对象存储配置
+type S3Config struct {
+	Endpoint  string // 服务地址，如 http://127.0.0.1:9000
+	Bucket    string // 存储桶，需预先创建
+	Region    string // 区域，MinIO 默认 us-east-1
+	AccessKey string
+	SecretKey string
+	Prefix    string // 对象键前缀
+}
+
+// S3Backend S3兼容对象存储（AWS S3、MinIO 等），使用路径风格访问并以 Signature V4 签名
+type S3Backend struct {
+	cfg    S3Config
+	base   *url.URL
+	client *http.Client
+}
+
+// NewS3Backend 创建S3兼容对象存储
+func NewS3Backend(cfg S3Config) (*S3Backend, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("未指定S3服务地址或存储桶")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("未指定S3访问密钥")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	base, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("无效的S3服务地址: %s", cfg.Endpoint)
+	}
+	return &S3Backend{
+		cfg:    cfg,
+		base:   base,
+		client: &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// Put 上传对象
+func (b *S3Backend) Put(key string, data []byte) error {
+	resp, err := b.do(http.MethodPut, b.cfg.Prefix+key, nil, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return b.responseError(resp)
+	}
+	return nil
+}
+
+// Get 下载对象
+func (b *S3Backend) Get(key string) ([]byte, error) {
+	resp, err := b.do(http.MethodGet, b.cfg.Prefix+key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, b.responseError(resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Delete 删除对象，S3 对不存在的对象同样返回成功
+func (b *S3Backend) Delete(key string) error {
+	resp, err := b.do(http.MethodDelete, b.cfg.Prefix+key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return b.responseError(resp)
+	}
+	return nil
+}
+
+// List 以 ListObjectsV2 分页列出前缀下的对象
+func (b *S3Backend) List(fn func(key string, modTime time.Time) error) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {b.cfg.Prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := b.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := b.responseError(resp)
+			resp.Body.Close()
+			return err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("解析S3对象列表失败: %v", err)
+		}
+		for _, obj := range result.Contents {
+			if err := fn(strings.TrimPrefix(obj.Key, b.cfg.Prefix), obj.LastModified); err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// responseError 将S3错误响应转换为错误信息
+func (b *S3Backend) responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("S3请求失败 (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// do 发送签名后的请求，object 为空时请求存储桶本身
+func (b *S3Backend) do(method, object string, query url.Values, body []byte) (*http.Response, error) {
+	u := *b.base
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + b.cfg.Bucket
+	if object != "" {
+		u.Path += "/" + object
+	}
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	b.sign(req, body, time.Now().UTC())
+	return b.client.Do(req)
+}
+
+// sign 按 AWS Signature Version 4 为请求签名
+func (b *S3Backend) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + b.cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+b.cfg.SecretKey), date)
+	key = hmacSHA256(key, b.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery 按参数名排序并以 RFC 3986 编码查询参数，签名与实际请求使用相同的编码
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode 除字母、数字和 -_.~ 外均编码为 %XX
+func uriEncode(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
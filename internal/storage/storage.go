@@ -0,0 +1,22 @@
+// Package storage 提供备份数据块内容的外部存储后端（本地目录、S3兼容对象存储）
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("对象不存在")
+
+// Backend 数据块存储后端，对象以内容哈希为键，写入后不再修改
+type Backend interface {
+	// Put 写入对象，已存在时覆盖
+	Put(key string, data []byte) error
+	// Get 读取对象，不存在时返回 ErrNotFound
+	Get(key string) ([]byte, error)
+	// Delete 删除对象，不存在时不报错
+	Delete(key string) error
+	// List 遍历全部对象的键和修改时间，fn 返回错误时停止遍历
+	List(fn func(key string, modTime time.Time) error) error
+}
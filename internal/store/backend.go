@@ -0,0 +1,212 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+// 数据块存储后端名称
+const (
+	BackendDB = "db" // 存于数据库 blobs 表
+	BackendFS = "fs" // 本地目录
+	BackendS3 = "s3" // S3兼容对象存储
+)
+
+var (
+	backends      = map[string]storage.Backend{}
+	activeBackend = BackendDB
+)
+
+// RegisterBackend 注册外部存储后端，迁移或读取旧数据时也会用到非当前使用的后端
+func RegisterBackend(name string, backend storage.Backend) {
+	backends[name] = backend
+}
+
+// UseBackend 设置新数据块写入的存储后端
+func UseBackend(name string) error {
+	if name != BackendDB && backends[name] == nil {
+		return fmt.Errorf("存储后端 %s 未配置", name)
+	}
+	activeBackend = name
+	return nil
+}
+
+// ActiveBackend 当前写入使用的存储后端
+func ActiveBackend() string {
+	return activeBackend
+}
+
+// isDBBackend 数据块内容是否存于数据库
+func isDBBackend(name string) bool {
+	return name == "" || name == BackendDB
+}
+
+// externalBackend 获取外部存储后端
+func externalBackend(name string) (storage.Backend, error) {
+	backend := backends[name]
+	if backend == nil {
+		return nil, fmt.Errorf("存储后端 %s 未配置", name)
+	}
+	return backend, nil
+}
+
+// readBlob 读取数据块内容，解密并解码
+func readBlob(blob *models.Blob) (string, error) {
+	var raw []byte
+	if isDBBackend(blob.Backend) {
+		if blob.OwnerID == 0 {
+			return decodePayload(blob.Data, blob.Payload, blob.Encoding)
+		}
+		raw = blob.Payload
+	} else {
+		backend, err := externalBackend(blob.Backend)
+		if err != nil {
+			return "", err
+		}
+		raw, err = backend.Get(blob.Hash)
+		if err != nil {
+			return "", fmt.Errorf("读取数据块 %s 失败: %v", blob.Hash, err)
+		}
+	}
+	if blob.OwnerID != 0 {
+		key, err := ownerKey(blob.OwnerID)
+		if err != nil {
+			return "", err
+		}
+		if raw, err = key.decrypt(blob.Hash, raw); err != nil {
+			return "", err
+		}
+	}
+	return decodeBytes(raw, blob.Encoding)
+}
+
+// blobHash 计算数据块内容的哈希：未加密时为 SHA-256，加密时为所属备份数据密钥派生的 HMAC
+func blobHash(blob *models.Blob, data string) (string, error) {
+	if blob.OwnerID == 0 {
+		return HashData(data), nil
+	}
+	key, err := ownerKey(blob.OwnerID)
+	if err != nil {
+		return "", err
+	}
+	return key.hash(data), nil
+}
+
+// writeBlob 将编码后的数据写入数据块所在的存储后端；存于数据库时只填充字段，由调用方写入
+func writeBlob(blob *models.Blob, p payload) error {
+	if isDBBackend(blob.Backend) {
+		blob.Data, blob.Payload = p.data, p.blob
+		return nil
+	}
+	backend, err := externalBackend(blob.Backend)
+	if err != nil {
+		return err
+	}
+	blob.Data, blob.Payload = "", nil
+	return backend.Put(blob.Hash, p.bytes())
+}
+
+// MigrateStorage 将全部数据块迁移到当前使用的存储后端，返回迁移的数据块数量
+// 迁移前会先把早期内联存储的备份和历史版本转为数据块
+func MigrateStorage() (int, error) {
+	if _, err := migrateBackups(); err != nil {
+		return 0, err
+	}
+	if _, err := migrateVersions(); err != nil {
+		return 0, err
+	}
+
+	target := activeBackend
+	query := database.DB.Where("ref_count > 0")
+	if isDBBackend(target) {
+		query = query.Where("backend NOT IN ?", []string{"", BackendDB})
+	} else {
+		query = query.Where("backend <> ?", target)
+	}
+
+	migrated := 0
+	var blobs []models.Blob
+	err := query.FindInBatches(&blobs, 20, func(_ *gorm.DB, _ int) error {
+		for i := range blobs {
+			if err := moveBlob(&blobs[i], target); err != nil {
+				return fmt.Errorf("迁移数据块 %s 失败: %v", blobs[i].Hash, err)
+			}
+			migrated++
+		}
+		return nil
+	}).Error
+	return migrated, err
+}
+
+// moveBlob 将单个数据块迁移到目标后端：先写入目标，再更新记录，最后删除源数据
+// 已压缩或加密的数据原样迁移，不改变编码
+func moveBlob(blob *models.Blob, target string) error {
+	data, err := readBlob(blob)
+	if err != nil {
+		return err
+	}
+	if hash, err := blobHash(blob, data); err != nil {
+		return err
+	} else if hash != blob.Hash {
+		return errors.New("数据块内容与哈希不一致")
+	}
+
+	source := blob.Backend
+	p := payload{encoding: blob.Encoding, encrypted: blob.OwnerID != 0, storedSize: blob.StoredSize}
+	switch {
+	case blob.Encoding == EncodingPlain && !p.encrypted:
+		p.data = data
+	case isDBBackend(source):
+		p.blob = blob.Payload
+	default:
+		backend, err := externalBackend(source)
+		if err != nil {
+			return err
+		}
+		if p.blob, err = backend.Get(blob.Hash); err != nil {
+			return fmt.Errorf("读取数据块 %s 失败: %v", blob.Hash, err)
+		}
+	}
+
+	moved := *blob
+	moved.Backend = target
+	if err := writeBlob(&moved, p); err != nil {
+		return err
+	}
+
+	columns := p.columns()
+	columns["backend"] = target
+	columns["data"] = moved.Data
+	columns["payload"] = moved.Payload
+	result := database.DB.Model(&models.Blob{}).Where("hash = ? AND backend = ?", blob.Hash, source).UpdateColumns(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// 迁移期间数据块已被删除，清理刚写入目标后端的副本
+		if !isDBBackend(target) {
+			if backend, err := externalBackend(target); err == nil {
+				backend.Delete(blob.Hash)
+			}
+		}
+		return nil
+	}
+
+	if !isDBBackend(source) {
+		backend, err := externalBackend(source)
+		if err != nil {
+			return err
+		}
+		if err := backend.Delete(blob.Hash); err != nil {
+			log.Printf("[存储] 删除源数据块 %s 失败: %v", blob.Hash, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// HashData 计算未加密备份数据的内容哈希，相同内容的数据只存储一份
+func HashData(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// dataHash 计算备份数据在 key 下的哈希，key 为 nil 时即 HashData
+func dataHash(key *dataKey, data string) string {
+	if key == nil {
+		return HashData(data)
+	}
+	return key.hash(data)
+}
+
+// acquireBlob 为备份 owner（须已写入数据库）的数据增加 refs 个引用，数据块不存在时按当前压缩和加密设置写入当前存储后端
+// 设置了主密钥时数据以 owner 的数据密钥加密，只与同一备份的数据去重；否则所有备份共享相同内容的数据块
+func acquireBlob(tx *gorm.DB, owner *models.Backup, data string, refs int) (*models.Blob, error) {
+	key, err := backupKey(tx, owner)
+	if err != nil {
+		return nil, err
+	}
+	hash := dataHash(key, data)
+
+	var blobs []models.Blob
+	if err := tx.Select("hash, backend, encoding, size, stored_size, ref_count, owner_id").Where("hash = ?", hash).Limit(1).Find(&blobs).Error; err != nil {
+		return nil, err
+	}
+	if len(blobs) > 0 {
+		blob := &blobs[0]
+		columns := map[string]interface{}{"ref_count": gorm.Expr("ref_count + ?", refs)}
+		if blob.RefCount <= 0 && !isDBBackend(blob.Backend) {
+			// 外部存储中等待回收的数据块可能已被删除，重新写入
+			p, err := encodePayload(key, hash, data)
+			if err != nil {
+				return nil, err
+			}
+			if err := writeBlob(blob, p); err != nil {
+				return nil, err
+			}
+			blob.Encoding, blob.StoredSize = p.encoding, p.storedSize
+			for k, v := range p.columns() {
+				columns[k] = v
+			}
+		}
+		result := tx.Model(&models.Blob{}).Where("hash = ?", hash).UpdateColumns(columns)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		// 读取之后数据块因引用归零被并发删除时，按新数据块重新写入
+		if result.RowsAffected > 0 {
+			blob.RefCount += refs
+			return blob, nil
+		}
+	}
+
+	p, err := encodePayload(key, hash, data)
+	if err != nil {
+		return nil, err
+	}
+	blob := &models.Blob{
+		Hash:       hash,
+		Backend:    activeBackend,
+		Encoding:   p.encoding,
+		Size:       int64(len(data)),
+		StoredSize: p.storedSize,
+		RefCount:   refs,
+	}
+	if key != nil {
+		blob.OwnerID = owner.ID
+	}
+	if err := writeBlob(blob, p); err != nil {
+		return nil, err
+	}
+	if err := tx.Create(blob).Error; err != nil {
+		return nil, err
+	}
+	return blob, nil
+}
+
+// retainBlob 为已被引用的数据块增加一个引用
+func retainBlob(tx *gorm.DB, hash string) error {
+	result := tx.Model(&models.Blob{}).Where("hash = ? AND ref_count > 0", hash).
+		UpdateColumn("ref_count", gorm.Expr("ref_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("数据块 %s 不存在", hash)
+	}
+	return nil
+}
+
+// releaseBlob 释放数据块的一个引用，hash 为空（早期数据）时忽略
+// 存于数据库的数据块引用归零时随事务一起删除；外部存储的数据块无法随事务回滚，
+// 保留引用为零的记录，由 CollectGarbage 在事务之外删除
+func releaseBlob(tx *gorm.DB, hash string) error {
+	if hash == "" {
+		return nil
+	}
+	if err := tx.Model(&models.Blob{}).Where("hash = ?", hash).
+		UpdateColumn("ref_count", gorm.Expr("ref_count - 1")).Error; err != nil {
+		return err
+	}
+	return tx.Where("hash = ? AND ref_count <= 0 AND backend IN ?", hash, []string{"", BackendDB}).Delete(&models.Blob{}).Error
+}
+
+// blobColumns 引用数据块时写入备份或历史版本的列，同时清空早期的内联数据
+func blobColumns(blob *models.Blob) map[string]interface{} {
+	return map[string]interface{}{
+		"blob_hash":   blob.Hash,
+		"data":        "",
+		"payload":     nil,
+		"encoding":    blob.Encoding,
+		"stored_size": blob.StoredSize,
+	}
+}
+
+// loadData 读取备份或历史版本的数据：引用数据块时从数据块读取，否则解码早期的内联数据
+func loadData(hash, data string, inline []byte, encoding string) (string, error) {
+	if hash == "" {
+		return decodePayload(data, inline, encoding)
+	}
+
+	var blob models.Blob
+	if err := database.DB.Where("hash = ?", hash).First(&blob).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("数据块 %s 不存在", hash)
+		}
+		return "", err
+	}
+	return readBlob(&blob)
+}
+
+// Load 将备份存储的数据解码到 Data 字段，读取数据库后、使用 Data 前调用
+func Load(backup *models.Backup) error {
+	data, err := loadData(backup.BlobHash, backup.Data, backup.Payload, backup.Encoding)
+	if err != nil {
+		return err
+	}
+	backup.Data = data
+	backup.Payload = nil
+	return nil
+}
+
+// LoadVersion 将历史版本存储的数据解码到 Data 字段
+func LoadVersion(version *models.BackupVersion) error {
+	data, err := loadData(version.BlobHash, version.Data, version.Payload, version.Encoding)
+	if err != nil {
+		return err
+	}
+	version.Data = data
+	version.Payload = nil
+	return nil
+}
+
+// orphanGrace 外部存储中没有记录的对象保留的时长
+// 数据块对象在事务提交前写入外部存储，事务回滚后对象没有对应的记录；
+// 进行中的事务写入的对象尚未超过该时长，不会被误删
+const orphanGrace = time.Hour
+
+// CollectGarbage 按备份和历史版本的实际引用重新计算引用计数，并删除无引用的数据块，
+// 以及外部存储中没有数据块记录的对象
+func CollectGarbage() (int64, error) {
+	err := database.DB.Exec(`UPDATE blobs SET ref_count =
+		(SELECT COUNT(*) FROM backups WHERE backups.blob_hash = blobs.hash) +
+		(SELECT COUNT(*) FROM backup_versions WHERE backup_versions.blob_hash = blobs.hash)`).Error
+	if err != nil {
+		return 0, err
+	}
+	result := database.DB.Where("ref_count <= 0 AND backend IN ?", []string{"", BackendDB}).Delete(&models.Blob{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	collected := result.RowsAffected
+
+	var orphans []models.Blob
+	if err := database.DB.Select("hash, backend").Where("ref_count <= 0").Find(&orphans).Error; err != nil {
+		return collected, err
+	}
+	for _, blob := range orphans {
+		backend, err := externalBackend(blob.Backend)
+		if err != nil {
+			log.Printf("[存储] 无法回收数据块 %s: %v", blob.Hash, err)
+			continue
+		}
+		// 在事务中先删除记录再删除对象：事务提交前并发上传无法复用该记录，删除对象失败时记录随事务恢复
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Where("hash = ? AND ref_count <= 0", blob.Hash).Delete(&models.Blob{})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			return backend.Delete(blob.Hash)
+		})
+		if err != nil {
+			log.Printf("[存储] 回收数据块 %s 失败: %v", blob.Hash, err)
+			continue
+		}
+		collected++
+	}
+
+	swept, err := sweepObjects(time.Now().Add(-orphanGrace))
+	return collected + swept, err
+}
+
+// sweepObjects 删除外部存储中修改时间早于 before 且没有数据块记录的对象
+func sweepObjects(before time.Time) (int64, error) {
+	var swept int64
+	for name, backend := range backends {
+		var keys []string
+		err := backend.List(func(key string, modTime time.Time) error {
+			if modTime.Before(before) {
+				keys = append(keys, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return swept, fmt.Errorf("列出存储后端 %s 的对象失败: %v", name, err)
+		}
+
+		for start := 0; start < len(keys); start += 500 {
+			batch := keys[start:min(start+500, len(keys))]
+			var known []string
+			if err := database.DB.Model(&models.Blob{}).Where("backend = ? AND hash IN ?", name, batch).
+				Pluck("hash", &known).Error; err != nil {
+				return swept, err
+			}
+			exists := make(map[string]bool, len(known))
+			for _, hash := range known {
+				exists[hash] = true
+			}
+			for _, key := range batch {
+				if exists[key] {
+					continue
+				}
+				if err := backend.Delete(key); err != nil {
+					log.Printf("[存储] 删除无记录的对象 %s 失败: %v", key, err)
+					continue
+				}
+				swept++
+			}
+		}
+	}
+	return swept, nil
+}
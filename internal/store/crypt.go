@@ -0,0 +1,275 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 备份数据采用信封加密：每个备份有一个随机生成的数据密钥，由主密钥以 AES-256-GCM 包装后保存在备份记录中，
+// 备份及其历史版本的数据块都以该数据密钥加密；轮换主密钥时只需重新包装各备份的数据密钥
+// 加密的数据块以数据密钥派生的 HMAC-SHA256 作为哈希（同时用作对象名称和附加认证数据），
+// 不会泄露明文的摘要，相同内容只在同一备份的各版本之间去重
+
+// MasterKeySize 主密钥长度（字节），数据密钥长度相同
+const MasterKeySize = 32
+
+// 主密钥错误
+var (
+	ErrMasterKeyMissing  = errors.New("存在已加密的备份数据，但未提供主密钥")
+	ErrMasterKeyMismatch = errors.New("存在由其他主密钥加密的备份数据")
+)
+
+// dataKeyAAD 包装数据密钥时的附加认证数据
+var dataKeyAAD = []byte("itab-backend data key")
+
+// hashKeyLabel 由数据密钥派生数据块哈希密钥时使用的标签
+const hashKeyLabel = "itab-backend blob hash"
+
+var (
+	masterAEAD  cipher.AEAD // 为 nil 时不加密新写入的数据
+	masterKeyID string
+
+	// dataKeys 已解开的数据密钥，以包装后的数据密钥为键，备份被删除后即使 ID 被复用也不会取错
+	dataKeys sync.Map
+)
+
+// dataKey 解开的备份数据密钥
+type dataKey struct {
+	aead    cipher.AEAD
+	hashKey []byte
+}
+
+// ParseMasterKey 解析主密钥：64 位十六进制字符串、Base64 编码或 32 字节的原始数据
+func ParseMasterKey(raw []byte) ([]byte, error) {
+	if len(raw) == MasterKeySize {
+		return raw, nil
+	}
+	s := strings.TrimSpace(string(raw))
+	if key, err := hex.DecodeString(s); err == nil && len(key) == MasterKeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == MasterKeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("主密钥须为 %d 字节，可用 64 位十六进制或 Base64 表示", MasterKeySize)
+}
+
+// KeyID 主密钥的标识（SHA-256 的前 8 字节），用于判断数据密钥由哪个主密钥包装，不泄露密钥本身
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// UseMasterKey 设置主密钥，此后新写入的数据块都会加密；key 为 nil 时不加密
+func UseMasterKey(key []byte) error {
+	if key == nil {
+		masterAEAD, masterKeyID = nil, ""
+		return nil
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	masterAEAD, masterKeyID = aead, KeyID(key)
+	return nil
+}
+
+// MasterKeyID 当前主密钥的标识，未设置主密钥时为空
+func MasterKeyID() string {
+	return masterKeyID
+}
+
+// CheckMasterKey 检查当前主密钥能否解开数据库中全部备份的数据密钥，启动时调用
+func CheckMasterKey() error {
+	var keyIDs []string
+	if err := database.DB.Unscoped().Model(&models.Backup{}).Where("data_key IS NOT NULL").Distinct().Pluck("key_id", &keyIDs).Error; err != nil {
+		return err
+	}
+	for _, id := range keyIDs {
+		if masterAEAD == nil {
+			return ErrMasterKeyMissing
+		}
+		if id != masterKeyID {
+			return fmt.Errorf("%w（%s），当前主密钥为 %s", ErrMasterKeyMismatch, id, masterKeyID)
+		}
+	}
+	return nil
+}
+
+// RotateMasterKey 用新主密钥重新包装全部备份的数据密钥，数据本身不需要重新加密，返回处理的备份数量
+// 已由新主密钥包装的备份会被跳过，中断后使用原主密钥再次执行即可继续；完成后新主密钥成为当前主密钥
+func RotateMasterKey(newKey []byte) (int, error) {
+	if masterAEAD == nil {
+		return 0, errors.New("未提供当前主密钥")
+	}
+	newAEADKey, err := newAEAD(newKey)
+	if err != nil {
+		return 0, err
+	}
+	newID := KeyID(newKey)
+
+	rotated := 0
+	var backups []models.Backup
+	err = database.DB.Unscoped().Select("id, data_key, key_id").Where("data_key IS NOT NULL AND key_id <> ?", newID).
+		FindInBatches(&backups, 100, func(_ *gorm.DB, _ int) error {
+			for _, b := range backups {
+				if b.KeyID != masterKeyID {
+					return fmt.Errorf("备份 %d 的数据密钥由未知的主密钥 %s 包装", b.ID, b.KeyID)
+				}
+				key, err := openSealed(masterAEAD, b.DataKey, dataKeyAAD)
+				if err != nil {
+					return fmt.Errorf("解开备份 %d 的数据密钥失败: %v", b.ID, err)
+				}
+				wrapped, err := seal(newAEADKey, key, dataKeyAAD)
+				if err != nil {
+					return err
+				}
+				err = database.DB.Unscoped().Model(&models.Backup{}).Where("id = ? AND key_id = ?", b.ID, masterKeyID).
+					UpdateColumns(map[string]interface{}{"data_key": wrapped, "key_id": newID}).Error
+				if err != nil {
+					return err
+				}
+				rotated++
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return rotated, err
+	}
+	masterAEAD, masterKeyID = newAEADKey, newID
+	return rotated, nil
+}
+
+// backupKey 返回备份的数据密钥，设置了主密钥而备份还没有数据密钥时生成并写入备份记录，未加密时返回 nil
+// backup 须已写入数据库；并发请求已为同一备份生成数据密钥时使用已保存的数据密钥
+func backupKey(tx *gorm.DB, backup *models.Backup) (*dataKey, error) {
+	if backup.DataKey == nil && masterAEAD != nil {
+		raw := make([]byte, MasterKeySize)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		wrapped, err := seal(masterAEAD, raw, dataKeyAAD)
+		if err != nil {
+			return nil, err
+		}
+		result := tx.Unscoped().Model(&models.Backup{}).Where("id = ? AND data_key IS NULL", backup.ID).
+			UpdateColumns(map[string]interface{}{"data_key": wrapped, "key_id": masterKeyID})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			var saved models.Backup
+			if err := tx.Unscoped().Select("id, data_key, key_id").First(&saved, backup.ID).Error; err != nil {
+				return nil, err
+			}
+			wrapped = saved.DataKey
+		}
+		backup.DataKey, backup.KeyID = wrapped, masterKeyID
+	}
+	if backup.DataKey == nil {
+		return nil, nil
+	}
+	return unwrapDataKey(backup.DataKey, backup.KeyID)
+}
+
+// ownerKey 返回加密数据块所属备份的数据密钥（包括回收站中的备份）
+func ownerKey(ownerID uint) (*dataKey, error) {
+	var owner models.Backup
+	if err := database.DB.Unscoped().Select("id, data_key, key_id").First(&owner, ownerID).Error; err != nil {
+		return nil, fmt.Errorf("读取备份 %d 的数据密钥失败: %v", ownerID, err)
+	}
+	if owner.DataKey == nil {
+		return nil, fmt.Errorf("备份 %d 没有数据密钥", ownerID)
+	}
+	return unwrapDataKey(owner.DataKey, owner.KeyID)
+}
+
+// unwrapDataKey 用主密钥解开数据密钥
+func unwrapDataKey(wrapped []byte, keyID string) (*dataKey, error) {
+	if v, ok := dataKeys.Load(string(wrapped)); ok {
+		return v.(*dataKey), nil
+	}
+	if masterAEAD == nil {
+		return nil, errors.New("备份数据已加密，但未提供主密钥")
+	}
+	if keyID != masterKeyID {
+		return nil, fmt.Errorf("数据密钥由其他主密钥（%s）包装", keyID)
+	}
+	raw, err := openSealed(masterAEAD, wrapped, dataKeyAAD)
+	if err != nil {
+		return nil, fmt.Errorf("解开数据密钥失败: %v", err)
+	}
+	aead, err := newAEAD(raw)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, raw)
+	mac.Write([]byte(hashKeyLabel))
+	key := &dataKey{aead: aead, hashKey: mac.Sum(nil)}
+	dataKeys.Store(string(wrapped), key)
+	return key, nil
+}
+
+// hash 计算数据在该数据密钥下的哈希
+func (k *dataKey) hash(data string) string {
+	mac := hmac.New(sha256.New, k.hashKey)
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// encrypt 加密编码后的数据，附加认证数据为数据块哈希，防止密文在数据块之间被替换
+func (k *dataKey) encrypt(hash string, plain []byte) ([]byte, error) {
+	return seal(k.aead, plain, []byte(hash))
+}
+
+// decrypt 解密数据块
+func (k *dataKey) decrypt(hash string, ciphertext []byte) ([]byte, error) {
+	plain, err := openSealed(k.aead, ciphertext, []byte(hash))
+	if err != nil {
+		return nil, fmt.Errorf("解密数据块 %s 失败: %v", hash, err)
+	}
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != MasterKeySize {
+		return nil, fmt.Errorf("密钥须为 %d 字节", MasterKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal 加密并在密文前附加随机 nonce
+func seal(aead cipher.AEAD, plain, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, aad), nil
+}
+
+// openSealed 解密 seal 生成的数据
+func openSealed(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("密文长度无效")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
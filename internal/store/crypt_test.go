@@ -0,0 +1,288 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, MasterKeySize)
+}
+
+// useTestKey 设置主密钥，测试结束后关闭加密并清空已解开的数据密钥
+func useTestKey(t *testing.T, key []byte) {
+	t.Helper()
+	if err := UseMasterKey(key); err != nil {
+		t.Fatalf("设置主密钥失败: %v", err)
+	}
+	t.Cleanup(func() {
+		UseMasterKey(nil)
+		forgetDataKeys()
+	})
+}
+
+// forgetDataKeys 清空已解开的数据密钥，模拟重启后重新解开
+func forgetDataKeys() {
+	dataKeys.Range(func(k, _ interface{}) bool {
+		dataKeys.Delete(k)
+		return true
+	})
+}
+
+func findBlob(t *testing.T, hash string) models.Blob {
+	t.Helper()
+	var blob models.Blob
+	if err := database.DB.Where("hash = ?", hash).First(&blob).Error; err != nil {
+		t.Fatalf("查询数据块 %s 失败: %v", hash, err)
+	}
+	return blob
+}
+
+func TestEncryptedBlobsUsePerBackupKeys(t *testing.T) {
+	setupTestDB(t)
+	useTestKey(t, testKey(1))
+	data := `{"shortcuts":[{"id":1,"name":"GitHub"}]}`
+
+	a := createBackup(t, "a", data)
+	b := createBackup(t, "b", data)
+
+	if a.DataKey == nil || b.DataKey == nil || bytes.Equal(a.DataKey, b.DataKey) {
+		t.Fatal("每个备份应有各自的数据密钥")
+	}
+	if a.BlobHash == b.BlobHash {
+		t.Fatal("加密时不同备份的相同内容不应共享数据块")
+	}
+	for _, backup := range []*models.Backup{a, b} {
+		if backup.BlobHash == HashData(data) {
+			t.Errorf("加密数据块的哈希不应为明文的 SHA-256")
+		}
+		blob := findBlob(t, backup.BlobHash)
+		if blob.OwnerID != backup.ID {
+			t.Errorf("数据块所属备份 = %d，应为 %d", blob.OwnerID, backup.ID)
+		}
+		if blob.Data != "" || bytes.Contains(blob.Payload, []byte("GitHub")) {
+			t.Errorf("数据块以明文存储")
+		}
+		if got := loadBackup(t, backup.ID); got != data {
+			t.Errorf("读取的数据 = %s", got)
+		}
+	}
+
+	// 同一备份内相同内容仍去重
+	saveRevision(t, a, `{"shortcuts":[]}`)
+	saveRevision(t, a, data)
+	if n := findBlob(t, a.BlobHash).RefCount; n != 3 {
+		t.Errorf("同一备份内相同内容的引用计数 = %d，应为 3", n)
+	}
+	if !Unchanged(a, data) || Unchanged(a, `{"shortcuts":[]}`) {
+		t.Error("Unchanged 应按备份的数据密钥比较")
+	}
+	checkRefCounts(t)
+}
+
+func TestEncryptedBlobCannotBeSwapped(t *testing.T) {
+	setupTestDB(t)
+	useTestKey(t, testKey(1))
+	a := createBackup(t, "a", `{"a":1}`)
+	b := createBackup(t, "b", `{"b":2}`)
+
+	// 将 b 的密文写入 a 的数据块，附加认证数据不匹配，解密失败
+	payload := findBlob(t, b.BlobHash).Payload
+	database.DB.Model(&models.Blob{}).Where("hash = ?", a.BlobHash).Update("payload", payload)
+	var backup models.Backup
+	database.DB.First(&backup, a.ID)
+	if err := Load(&backup); err == nil {
+		t.Fatal("替换后的密文不应解密成功")
+	}
+}
+
+func TestCopyReencryptsWithNewBackupKey(t *testing.T) {
+	setupTestDB(t)
+	useTestKey(t, testKey(1))
+	src := createBackup(t, "src", `{"v":1}`)
+	saveRevision(t, src, `{"v":2}`)
+
+	var copied *models.Backup
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		copied, err = Copy(tx, src, "copy", 1, true)
+		return err
+	}); err != nil {
+		t.Fatalf("复制失败: %v", err)
+	}
+
+	var versions []models.BackupVersion
+	database.DB.Where("backup_id = ?", copied.ID).Order("version").Find(&versions)
+	if len(versions) != 2 {
+		t.Fatalf("复制的版本数 = %d，应为 2", len(versions))
+	}
+	for i, v := range versions {
+		if owner := findBlob(t, v.BlobHash).OwnerID; owner != copied.ID {
+			t.Errorf("版本 %d 的数据块属于备份 %d，应属于副本 %d", v.Version, owner, copied.ID)
+		}
+		if err := LoadVersion(&v); err != nil || v.Data != []string{`{"v":1}`, `{"v":2}`}[i] {
+			t.Errorf("版本 %d 读取结果 = %q, %v", v.Version, v.Data, err)
+		}
+	}
+	if got := loadBackup(t, copied.ID); got != `{"v":2}` {
+		t.Errorf("副本数据 = %s", got)
+	}
+	checkRefCounts(t)
+}
+
+// 迁移存储后端时密文原样写入目标后端
+func TestMigrateStorageKeepsEncryption(t *testing.T) {
+	setupTestDB(t)
+	useTestKey(t, testKey(1))
+	data := `{"shortcuts":[{"id":1,"name":"GitHub"}]}`
+	a := createBackup(t, "a", data)
+
+	fs, err := storage.NewFSBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建存储后端失败: %v", err)
+	}
+	RegisterBackend(BackendFS, fs)
+	if err := UseBackend(BackendFS); err != nil {
+		t.Fatalf("切换存储后端失败: %v", err)
+	}
+	if migrated, err := MigrateStorage(); err != nil || migrated != 1 {
+		t.Fatalf("MigrateStorage = %d, %v，应迁移 1 个数据块", migrated, err)
+	}
+
+	raw, err := fs.Get(a.BlobHash)
+	if err != nil {
+		t.Fatalf("读取迁移后的对象失败: %v", err)
+	}
+	if bytes.Contains(raw, []byte("GitHub")) {
+		t.Error("迁移后的对象以明文存储")
+	}
+	if got := loadBackup(t, a.ID); got != data {
+		t.Errorf("迁移后读取的数据 = %s", got)
+	}
+}
+
+func TestRotateMasterKey(t *testing.T) {
+	setupTestDB(t)
+	oldKey, newKey := testKey(1), testKey(2)
+	useTestKey(t, oldKey)
+	a := createBackup(t, "a", `{"a":1}`)
+	createBackup(t, "b", `{"b":2}`)
+	hash := a.BlobHash
+
+	rotated, err := RotateMasterKey(newKey)
+	if err != nil || rotated != 2 {
+		t.Fatalf("RotateMasterKey = %d, %v，应重新包装 2 个备份", rotated, err)
+	}
+	if MasterKeyID() != KeyID(newKey) {
+		t.Error("轮换后新主密钥应成为当前主密钥")
+	}
+	// 再次执行时已由新主密钥包装的备份被跳过
+	if rotated, err := RotateMasterKey(newKey); err != nil || rotated != 0 {
+		t.Errorf("重复轮换 = %d, %v，应为 0", rotated, err)
+	}
+
+	// 重启后只用新主密钥即可读取，数据块本身不变
+	forgetDataKeys()
+	UseMasterKey(newKey)
+	if err := CheckMasterKey(); err != nil {
+		t.Fatalf("CheckMasterKey: %v", err)
+	}
+	if got := loadBackup(t, a.ID); got != `{"a":1}` {
+		t.Errorf("轮换后读取的数据 = %s", got)
+	}
+	if findBlob(t, hash).OwnerID != a.ID {
+		t.Error("轮换不应改变数据块")
+	}
+
+	forgetDataKeys()
+	UseMasterKey(oldKey)
+	if err := CheckMasterKey(); !errors.Is(err, ErrMasterKeyMismatch) {
+		t.Errorf("使用原主密钥时 CheckMasterKey = %v，应为 ErrMasterKeyMismatch", err)
+	}
+	var backup models.Backup
+	database.DB.First(&backup, a.ID)
+	if err := Load(&backup); err == nil {
+		t.Error("原主密钥不应再能读取数据")
+	}
+}
+
+func TestCheckMasterKeyMissing(t *testing.T) {
+	setupTestDB(t)
+	if err := CheckMasterKey(); err != nil {
+		t.Fatalf("没有加密数据时 CheckMasterKey = %v", err)
+	}
+
+	useTestKey(t, testKey(1))
+	a := createBackup(t, "a", `{"a":1}`)
+
+	forgetDataKeys()
+	UseMasterKey(nil)
+	if err := CheckMasterKey(); !errors.Is(err, ErrMasterKeyMissing) {
+		t.Errorf("CheckMasterKey = %v，应为 ErrMasterKeyMissing", err)
+	}
+	var backup models.Backup
+	database.DB.First(&backup, a.ID)
+	if err := Load(&backup); err == nil {
+		t.Error("未提供主密钥时不应能读取加密数据")
+	}
+
+	// 回收站中的备份同样需要主密钥
+	database.DB.Delete(&models.Backup{}, a.ID)
+	if err := CheckMasterKey(); !errors.Is(err, ErrMasterKeyMissing) {
+		t.Errorf("回收站中的备份 CheckMasterKey = %v，应为 ErrMasterKeyMissing", err)
+	}
+}
+
+func TestEncryptBackupsMigratesPlaintext(t *testing.T) {
+	setupTestDB(t)
+	data := `{"shortcuts":[{"id":1,"name":"GitHub"}]}`
+	a := createBackup(t, "a", data)
+	saveRevision(t, a, `{"shortcuts":[]}`)
+	b := createBackup(t, "b", data)
+	if a.DataKey != nil || refCount(t, data) != 3 {
+		t.Fatalf("未设置主密钥时应以明文共享数据块，引用计数 = %d", refCount(t, data))
+	}
+
+	useTestKey(t, testKey(1))
+	encrypted, err := encryptBackups()
+	if err != nil || encrypted != 2 {
+		t.Fatalf("encryptBackups = %d, %v，应加密 2 个备份", encrypted, err)
+	}
+	if refCount(t, data) != -1 {
+		t.Error("明文数据块应在加密后删除")
+	}
+
+	var blobs []models.Blob
+	database.DB.Find(&blobs)
+	for _, blob := range blobs {
+		if blob.OwnerID == 0 {
+			t.Errorf("数据块 %s 仍未加密", blob.Hash[:8])
+		}
+	}
+	if got := loadBackup(t, a.ID); got != `{"shortcuts":[]}` {
+		t.Errorf("备份 a 数据 = %s", got)
+	}
+	if got := loadBackup(t, b.ID); got != data {
+		t.Errorf("备份 b 数据 = %s", got)
+	}
+	var versions []models.BackupVersion
+	database.DB.Where("backup_id = ?", a.ID).Order("version").Find(&versions)
+	if len(versions) != 2 {
+		t.Fatalf("备份 a 的版本数 = %d", len(versions))
+	}
+	if err := LoadVersion(&versions[0]); err != nil || versions[0].Data != data {
+		t.Errorf("备份 a 版本 1 = %q, %v", versions[0].Data, err)
+	}
+	checkRefCounts(t)
+
+	if encrypted, err := encryptBackups(); err != nil || encrypted != 0 {
+		t.Errorf("再次执行 encryptBackups = %d, %v，应为 0", encrypted, err)
+	}
+}
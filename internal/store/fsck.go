@@ -0,0 +1,103 @@
+package store
+
+import (
+	"encoding/json"
+	"log"
+
+	"itab-backend/internal/backupdata"
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// FsckReport 单个备份的检查结果
+type FsckReport struct {
+	BackupID uint               `json:"backup_id"`
+	Name     string             `json:"name"`
+	UserID   uint               `json:"user_id"`
+	Issues   []backupdata.Issue `json:"issues"`          // 检查发现的问题
+	Changes  []string           `json:"changes"`         // 修复将要（或已经）做出的修改
+	Fixed    bool               `json:"fixed"`           // 是否已修复
+	Version  int                `json:"version"`         // 修复后的版本号
+	Error    string             `json:"error,omitempty"` // 无法解析或修复失败的原因
+}
+
+// Fsck 检查所有备份数据的一致性，只返回存在问题的备份
+// fix 为 true 时就地修复：先确保当前状态已有版本快照，再将修复结果保存为新版本
+func Fsck(fix bool) ([]FsckReport, error) {
+	var reports []FsckReport
+	var backups []models.Backup
+
+	err := database.DB.FindInBatches(&backups, 50, func(batch *gorm.DB, _ int) error {
+		for i := range backups {
+			if report := fsckBackup(&backups[i], fix); report != nil {
+				reports = append(reports, *report)
+			}
+		}
+		return nil
+	}).Error
+
+	return reports, err
+}
+
+// fsckBackup 检查并按需修复单个备份，数据无问题时返回 nil
+func fsckBackup(backup *models.Backup, fix bool) *FsckReport {
+	report := &FsckReport{
+		BackupID: backup.ID,
+		Name:     backup.Name,
+		UserID:   backup.UserID,
+		Version:  backup.Version,
+	}
+
+	if err := Load(backup); err != nil {
+		report.Error = "读取备份数据失败: " + err.Error()
+		return report
+	}
+
+	bd, err := backupdata.Parse(backup.Data)
+	if err != nil {
+		report.Error = "解析备份数据失败: " + err.Error()
+		return report
+	}
+
+	report.Issues = backupdata.Check(bd)
+	report.Changes = backupdata.Repair(bd)
+	if len(report.Issues) == 0 && len(report.Changes) == 0 {
+		return nil
+	}
+	if !fix || len(report.Changes) == 0 {
+		return report
+	}
+
+	data, err := json.Marshal(bd)
+	if err != nil {
+		report.Error = "序列化修复结果失败: " + err.Error()
+		return report
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := EnsureSnapshot(tx, backup, backup.UserID); err != nil {
+			return err
+		}
+		backup.Data = string(data)
+		backup.Size = int64(len(backup.Data))
+		if err := SaveRevision(tx, backup, backup.UserID, 0, ""); err != nil {
+			return err
+		}
+		return tx.Create(&models.SyncRecord{
+			BackupName: backup.Name,
+			TransType:  "repair",
+			UserID:     backup.UserID,
+		}).Error
+	})
+	if err != nil {
+		report.Error = "保存修复结果失败: " + err.Error()
+		return report
+	}
+
+	report.Fixed = true
+	report.Version = backup.Version
+	log.Printf("[修复] 备份「%s」已修复 %d 项，新版本 %d", backup.Name, len(report.Changes), backup.Version)
+	return report
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"testing"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+)
+
+func TestFsck(t *testing.T) {
+	setupTestDB(t)
+	// 排序从 1 开始的一致数据不需要修复
+	healthy := createBackup(t, "healthy", `{"partitions":[{"id":1,"name":"主页","order":1}],"folders":[],`+
+		`"shortcuts":[{"id":1,"name":"GitHub","url":"https://github.com","partitionId":1,"order":1},`+
+		`{"id":2,"name":"Go","url":"https://go.dev","partitionId":1,"order":2}],"searchEngines":[],"settings":{}}`)
+	broken := createBackup(t, "broken", `{"partitions":[{"id":1,"name":"主页","order":0}],"folders":[],`+
+		`"shortcuts":[{"id":1,"name":"孤立","url":"https://example.com","partitionId":1,"folderId":9,"order":0}],"searchEngines":[],"settings":{}}`)
+
+	reports, err := Fsck(false)
+	if err != nil {
+		t.Fatalf("Fsck: %v", err)
+	}
+	if len(reports) != 1 || reports[0].BackupID != broken.ID {
+		t.Fatalf("Fsck 报告 = %+v，应只报告备份 %d", reports, broken.ID)
+	}
+	if len(reports[0].Issues) == 0 || len(reports[0].Changes) == 0 || reports[0].Fixed {
+		t.Errorf("检查报告 = %+v", reports[0])
+	}
+	var stored models.Backup
+	database.DB.First(&stored, broken.ID)
+	if stored.Version != broken.Version {
+		t.Errorf("只检查时版本 = %d，不应修改", stored.Version)
+	}
+
+	reports, err = Fsck(true)
+	if err != nil {
+		t.Fatalf("Fsck(fix): %v", err)
+	}
+	if len(reports) != 1 || !reports[0].Fixed || reports[0].Version != broken.Version+1 {
+		t.Fatalf("修复报告 = %+v，应修复并生成版本 %d", reports, broken.Version+1)
+	}
+	database.DB.First(&stored, healthy.ID)
+	if stored.Version != healthy.Version {
+		t.Errorf("健康备份的版本 = %d，不应生成新版本", stored.Version)
+	}
+
+	if reports, err := Fsck(false); err != nil || len(reports) != 0 {
+		t.Errorf("修复后 Fsck = %+v, %v，应无问题", reports, err)
+	}
+	checkRefCounts(t)
+}
@@ -0,0 +1,271 @@
+package store
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// errSkipMigration 备份在迁移期间被修改，跳过本次迁移
+var errSkipMigration = errors.New("skip migration")
+
+// gcInterval 定期回收数据块的间隔
+const gcInterval = time.Hour
+
+// StartMaintenance 启动存储维护后台任务：先将早期内联存储的备份和历史版本迁移到数据块，
+// 按当前压缩设置重新编码存于数据库的数据块，设置了主密钥时以各备份的数据密钥加密尚未加密的数据，
+// 之后定期回收无引用的数据块
+func StartMaintenance() {
+	go func() {
+		start := time.Now()
+		backups, err := migrateBackups()
+		if err != nil {
+			log.Printf("[存储] 迁移备份数据失败: %v", err)
+		}
+		versions, err := migrateVersions()
+		if err != nil {
+			log.Printf("[存储] 迁移历史版本失败: %v", err)
+		}
+		encoded, err := reencodeBlobs()
+		if err != nil {
+			log.Printf("[存储] 重新编码数据块失败: %v", err)
+		}
+		encrypted, err := encryptBackups()
+		if err != nil {
+			log.Printf("[存储] 加密备份数据失败: %v", err)
+		}
+		if backups+versions+encoded+encrypted > 0 {
+			log.Printf("[存储] 已迁移 %d 个备份、%d 个历史版本，重新编码 %d 个数据块，加密 %d 个备份，耗时 %v",
+				backups, versions, encoded, encrypted, time.Since(start))
+		}
+
+		for {
+			if collected, err := CollectGarbage(); err != nil {
+				log.Printf("[存储] 回收数据块失败: %v", err)
+			} else if collected > 0 {
+				log.Printf("[存储] 已回收 %d 个数据块", collected)
+			}
+			time.Sleep(gcInterval)
+		}
+	}()
+}
+
+// migrateBackups 将内联存储的备份迁移到数据块，只在备份未被修改时写入
+func migrateBackups() (int, error) {
+	migrated := 0
+	var backups []models.Backup
+	err := database.DB.Unscoped().Where("blob_hash = ''").
+		FindInBatches(&backups, 20, func(_ *gorm.DB, _ int) error {
+			for i := range backups {
+				b := &backups[i]
+				if err := Load(b); err != nil {
+					log.Printf("[存储] 读取备份 %d 失败，跳过迁移: %v", b.ID, err)
+					continue
+				}
+				err := database.DB.Transaction(func(tx *gorm.DB) error {
+					blob, err := acquireBlob(tx, b, b.Data, 1)
+					if err != nil {
+						return err
+					}
+					result := tx.Unscoped().Model(&models.Backup{}).
+						Where("id = ? AND version = ? AND blob_hash = ''", b.ID, b.Version).
+						UpdateColumns(blobColumns(blob))
+					if result.Error == nil && result.RowsAffected == 0 {
+						return errSkipMigration
+					}
+					return result.Error
+				})
+				if errors.Is(err, errSkipMigration) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				migrated++
+			}
+			return nil
+		}).Error
+	return migrated, err
+}
+
+// migrateVersions 将内联存储的历史版本迁移到数据块
+func migrateVersions() (int, error) {
+	migrated := 0
+	var versions []models.BackupVersion
+	err := database.DB.Where("blob_hash = ''").
+		FindInBatches(&versions, 20, func(_ *gorm.DB, _ int) error {
+			for i := range versions {
+				v := &versions[i]
+				if err := LoadVersion(v); err != nil {
+					log.Printf("[存储] 读取历史版本 %d 失败，跳过迁移: %v", v.ID, err)
+					continue
+				}
+				var owner models.Backup
+				if err := database.DB.Unscoped().Select("id, data_key, key_id").First(&owner, v.BackupID).Error; err != nil {
+					log.Printf("[存储] 读取历史版本 %d 所属的备份失败，跳过迁移: %v", v.ID, err)
+					continue
+				}
+				err := database.DB.Transaction(func(tx *gorm.DB) error {
+					blob, err := acquireBlob(tx, &owner, v.Data, 1)
+					if err != nil {
+						return err
+					}
+					columns := blobColumns(blob)
+					columns["size"] = blob.Size
+					return tx.Model(&models.BackupVersion{}).Where("id = ?", v.ID).UpdateColumns(columns).Error
+				})
+				if err != nil {
+					return err
+				}
+				migrated++
+			}
+			return nil
+		}).Error
+	return migrated, err
+}
+
+// reencodeBlobs 按当前压缩设置重新压缩存于数据库的未加密明文数据块，数据块内容不变，只改变编码
+// 加密的数据块由 encryptBackups 处理；外部存储中的数据块无法与记录一起原子地改写，不在此处理
+func reencodeBlobs() (int, error) {
+	if Compression == EncodingPlain {
+		return 0, nil
+	}
+
+	encoded := 0
+	var blobs []models.Blob
+	err := database.DB.Where("owner_id = 0 AND encoding = ? AND size > 0 AND backend IN ?", EncodingPlain, []string{"", BackendDB}).
+		FindInBatches(&blobs, 20, func(_ *gorm.DB, _ int) error {
+			for i := range blobs {
+				b := &blobs[i]
+				p := compressPayload(b.Data)
+				if p.encoding == b.Encoding {
+					continue
+				}
+				err := database.DB.Transaction(func(tx *gorm.DB) error {
+					columns := p.columns()
+					columns["data"] = p.data
+					columns["payload"] = p.blob
+					err := tx.Model(&models.Blob{}).Where("hash = ? AND owner_id = 0 AND encoding = ? AND backend IN ?", b.Hash, EncodingPlain, []string{"", BackendDB}).
+						UpdateColumns(columns).Error
+					if err != nil {
+						return err
+					}
+					// 同步更新引用方记录的编码和存储大小
+					refs := map[string]interface{}{"encoding": p.encoding, "stored_size": p.storedSize}
+					if err := tx.Unscoped().Model(&models.Backup{}).Where("blob_hash = ?", b.Hash).UpdateColumns(refs).Error; err != nil {
+						return err
+					}
+					return tx.Model(&models.BackupVersion{}).Where("blob_hash = ?", b.Hash).UpdateColumns(refs).Error
+				})
+				if err != nil {
+					return err
+				}
+				encoded++
+			}
+			return nil
+		}).Error
+	return encoded, err
+}
+
+// encryptBackups 设置了主密钥时，将引用未加密（或属于其他备份）数据块的备份及其历史版本改为以备份自身的数据密钥加密，
+// 每个备份在一个事务中处理；期间被修改的记录保持不变，下次启动时再处理
+func encryptBackups() (int, error) {
+	if masterAEAD == nil {
+		return 0, nil
+	}
+
+	// 备份本身或其历史版本引用了不属于该备份的数据块
+	foreign := `EXISTS (SELECT 1 FROM blobs WHERE blobs.hash = backups.blob_hash AND blobs.owner_id <> backups.id)
+		OR EXISTS (SELECT 1 FROM backup_versions JOIN blobs ON blobs.hash = backup_versions.blob_hash
+			WHERE backup_versions.backup_id = backups.id AND blobs.owner_id <> backups.id)`
+
+	encrypted := 0
+	var backups []models.Backup
+	err := database.DB.Unscoped().Select("id, blob_hash, data_key, key_id").Where(foreign).
+		FindInBatches(&backups, 20, func(_ *gorm.DB, _ int) error {
+			for i := range backups {
+				b := &backups[i]
+				if err := database.DB.Transaction(func(tx *gorm.DB) error {
+					return encryptBackup(tx, b)
+				}); err != nil {
+					log.Printf("[存储] 加密备份 %d 失败: %v", b.ID, err)
+					continue
+				}
+				encrypted++
+			}
+			return nil
+		}).Error
+	return encrypted, err
+}
+
+// encryptBackup 以备份的数据密钥重新写入备份及其历史版本引用的数据块，并释放原数据块
+func encryptBackup(tx *gorm.DB, backup *models.Backup) error {
+	if _, err := backupKey(tx, backup); err != nil {
+		return err
+	}
+
+	// reencrypt 读取 hash 对应的数据并以备份的数据密钥写入新数据块，update 只在记录仍引用原数据块时改写
+	reencrypt := func(hash string, update func(columns map[string]interface{}) (int64, error)) error {
+		data, err := loadData(hash, "", nil, EncodingPlain)
+		if err != nil {
+			return err
+		}
+		blob, err := acquireBlob(tx, backup, data, 1)
+		if err != nil {
+			return err
+		}
+		if blob.Hash == hash {
+			return releaseBlob(tx, hash)
+		}
+		columns := blobColumns(blob)
+		columns["size"] = blob.Size
+		updated, err := update(columns)
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			return releaseBlob(tx, blob.Hash)
+		}
+		return releaseBlob(tx, hash)
+	}
+
+	var owner int64
+	if backup.BlobHash != "" {
+		if err := tx.Model(&models.Blob{}).Where("hash = ? AND owner_id = ?", backup.BlobHash, backup.ID).Count(&owner).Error; err != nil {
+			return err
+		}
+		if owner == 0 {
+			err := reencrypt(backup.BlobHash, func(columns map[string]interface{}) (int64, error) {
+				result := tx.Unscoped().Model(&models.Backup{}).Where("id = ? AND blob_hash = ?", backup.ID, backup.BlobHash).UpdateColumns(columns)
+				return result.RowsAffected, result.Error
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var versions []models.BackupVersion
+	err := tx.Select("backup_versions.id, backup_versions.blob_hash").
+		Joins("JOIN blobs ON blobs.hash = backup_versions.blob_hash").
+		Where("backup_versions.backup_id = ? AND blobs.owner_id <> ?", backup.ID, backup.ID).
+		Find(&versions).Error
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		err := reencrypt(v.BlobHash, func(columns map[string]interface{}) (int64, error) {
+			result := tx.Model(&models.BackupVersion{}).Where("id = ? AND blob_hash = ?", v.ID, v.BlobHash).UpdateColumns(columns)
+			return result.RowsAffected, result.Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
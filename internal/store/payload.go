@@ -0,0 +1,106 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// 备份数据的存储编码
+const (
+	EncodingPlain = ""     // 明文JSON，存于 data 列
+	EncodingGzip  = "gzip" // gzip压缩，存于 payload 列
+)
+
+// Compression 新写入数据使用的编码，由启动参数设置
+var Compression = EncodingGzip
+
+// payload 编码后的待存储数据
+type payload struct {
+	data       string // 明文编码且未加密时的数据
+	blob       []byte // 压缩或加密后的数据
+	encoding   string
+	encrypted  bool // blob 是否为以数据密钥加密的密文
+	storedSize int64
+}
+
+// encodePayload 按当前压缩设置编码数据，压缩后反而更大时保持明文；key 不为 nil 时再以数据密钥加密编码后的数据
+func encodePayload(key *dataKey, hash, data string) (payload, error) {
+	p := compressPayload(data)
+	if key == nil {
+		return p, nil
+	}
+
+	ciphertext, err := key.encrypt(hash, p.bytes())
+	if err != nil {
+		return payload{}, err
+	}
+	return payload{blob: ciphertext, encoding: p.encoding, encrypted: true, storedSize: int64(len(ciphertext))}, nil
+}
+
+// compressPayload 按当前压缩设置压缩数据
+func compressPayload(data string) payload {
+	plain := payload{data: data, encoding: EncodingPlain, storedSize: int64(len(data))}
+	if Compression != EncodingGzip || data == "" {
+		return plain
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(data)); err != nil {
+		return plain
+	}
+	if err := zw.Close(); err != nil {
+		return plain
+	}
+	if buf.Len() >= len(data) {
+		return plain
+	}
+
+	return payload{blob: buf.Bytes(), encoding: EncodingGzip, storedSize: int64(buf.Len())}
+}
+
+// bytes 编码后实际存储的字节，用于写入外部存储后端
+func (p payload) bytes() []byte {
+	if p.encoding == EncodingPlain && !p.encrypted {
+		return []byte(p.data)
+	}
+	return p.blob
+}
+
+// columns 写入数据块记录的编码相关列
+func (p payload) columns() map[string]interface{} {
+	return map[string]interface{}{
+		"encoding":    p.encoding,
+		"stored_size": p.storedSize,
+	}
+}
+
+// decodeBytes 按存储编码还原外部存储后端中的数据
+func decodeBytes(raw []byte, encoding string) (string, error) {
+	if encoding == EncodingPlain {
+		return string(raw), nil
+	}
+	return decodePayload("", raw, encoding)
+}
+
+// decodePayload 按存储编码还原JSON数据
+func decodePayload(data string, blob []byte, encoding string) (string, error) {
+	switch encoding {
+	case EncodingPlain:
+		return data, nil
+	case EncodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(blob))
+		if err != nil {
+			return "", err
+		}
+		defer zr.Close()
+		raw, err := io.ReadAll(zr)
+		if err != nil {
+			return "", err
+		}
+		return string(raw), nil
+	}
+	return "", fmt.Errorf("不支持的存储编码 %q", encoding)
+}
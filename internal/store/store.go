@@ -0,0 +1,261 @@
+// Package store 负责备份数据及其历史版本的持久化
+package store
+
+import (
+	"errors"
+	"time"
+
+	"itab-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrRevisionConflict 备份在读取之后已被其他请求修改
+var ErrRevisionConflict = errors.New("revision conflict")
+
+// newVersion 根据备份当前状态生成引用 blob 的历史版本快照
+func newVersion(backup *models.Backup, blob *models.Blob, userID, accessKeyID uint, accessKey string) *models.BackupVersion {
+	return &models.BackupVersion{
+		BackupID:           backup.ID,
+		Version:            backup.Version,
+		BlobHash:           blob.Hash,
+		Encoding:           blob.Encoding,
+		Size:               blob.Size,
+		StoredSize:         blob.StoredSize,
+		PasswordsEncrypted: backup.PasswordsEncrypted,
+		AccessKeyID:        accessKeyID,
+		AccessKey:          accessKey,
+		UserID:             userID,
+	}
+}
+
+// insertBackup 写入备份记录（不含内联数据），并回填 ID 和时间戳
+func insertBackup(tx *gorm.DB, backup *models.Backup) error {
+	row := *backup
+	row.Data, row.Payload = "", nil
+	if err := tx.Create(&row).Error; err != nil {
+		return err
+	}
+	// PasswordsEncrypted 带有 default:true，值为 false 时插入会被忽略，需单独写入
+	if !backup.PasswordsEncrypted && row.PasswordsEncrypted {
+		if err := tx.Model(&models.Backup{}).Where("id = ?", row.ID).UpdateColumn("passwords_encrypted", false).Error; err != nil {
+			return err
+		}
+	}
+	backup.ID = row.ID
+	backup.CreatedAt, backup.UpdatedAt = row.CreatedAt, row.UpdatedAt
+	return nil
+}
+
+// Create 创建新备份并生成第一个历史版本，backup.Data 为明文JSON
+func Create(tx *gorm.DB, backup *models.Backup, userID, accessKeyID uint, accessKey string) error {
+	backup.Version = 1
+	if err := insertBackup(tx, backup); err != nil {
+		return err
+	}
+	// 备份本身和第一个历史版本各持有一个引用
+	if err := attachBlob(tx, backup, backup.Data, 2); err != nil {
+		return err
+	}
+	blob := &models.Blob{Hash: backup.BlobHash, Encoding: backup.Encoding, Size: backup.Size, StoredSize: backup.StoredSize}
+	return tx.Create(newVersion(backup, blob, userID, accessKeyID, accessKey)).Error
+}
+
+// attachBlob 为刚写入的备份记录引用数据块，加密时数据密钥依赖备份ID，因此在写入备份记录之后进行
+func attachBlob(tx *gorm.DB, backup *models.Backup, data string, refs int) error {
+	blob, err := acquireBlob(tx, backup, data, refs)
+	if err != nil {
+		return err
+	}
+	columns := blobColumns(blob)
+	columns["size"] = blob.Size
+	if err := tx.Model(&models.Backup{}).Where("id = ?", backup.ID).UpdateColumns(columns).Error; err != nil {
+		return err
+	}
+	backup.BlobHash, backup.Encoding = blob.Hash, blob.Encoding
+	backup.Size, backup.StoredSize = blob.Size, blob.StoredSize
+	return nil
+}
+
+// SaveRevision 以乐观锁方式保存备份的新数据，并生成对应的历史版本
+// 调用前修改 backup 的 Data（明文JSON）/PasswordsEncrypted/SyncCount 等字段即可，
+// 大小、编码和版本号由此函数维护；若备份在读取之后已被其他请求修改，返回 ErrRevisionConflict
+func SaveRevision(tx *gorm.DB, backup *models.Backup, userID, accessKeyID uint, accessKey string) error {
+	oldHash := backup.BlobHash
+	blob, err := acquireBlob(tx, backup, backup.Data, 2)
+	if err != nil {
+		return err
+	}
+
+	baseVersion := backup.Version
+	backup.Version = baseVersion + 1
+	backup.UpdatedAt = time.Now()
+
+	columns := blobColumns(blob)
+	columns["size"] = blob.Size
+	columns["sync_count"] = backup.SyncCount
+	columns["version"] = backup.Version
+	columns["passwords_encrypted"] = backup.PasswordsEncrypted
+	columns["updated_at"] = backup.UpdatedAt
+
+	result := tx.Model(&models.Backup{}).Where("id = ? AND version = ?", backup.ID, baseVersion).Updates(columns)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = ErrRevisionConflict
+	}
+	if result.Error != nil {
+		backup.Version = baseVersion
+		return result.Error
+	}
+	if err := releaseBlob(tx, oldHash); err != nil {
+		return err
+	}
+	backup.BlobHash, backup.Encoding = blob.Hash, blob.Encoding
+	backup.Size, backup.StoredSize = blob.Size, blob.StoredSize
+
+	return tx.Create(newVersion(backup, blob, userID, accessKeyID, accessKey)).Error
+}
+
+// Unchanged 判断数据与备份当前内容是否相同，相同时上传无需产生新版本
+func Unchanged(backup *models.Backup, data string) bool {
+	if backup.BlobHash == "" {
+		return false
+	}
+	var key *dataKey
+	if backup.DataKey != nil {
+		var err error
+		if key, err = unwrapDataKey(backup.DataKey, backup.KeyID); err != nil {
+			return false
+		}
+	}
+	return backup.BlobHash == dataHash(key, data)
+}
+
+// EnsureSnapshot 确保备份当前状态已有历史版本快照（早期创建的备份可能没有），backup.Data 需已解码
+func EnsureSnapshot(tx *gorm.DB, backup *models.Backup, userID uint) error {
+	var count int64
+	if err := tx.Model(&models.BackupVersion{}).Where("backup_id = ? AND version = ?", backup.ID, backup.Version).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	blob, err := acquireBlob(tx, backup, backup.Data, 1)
+	if err != nil {
+		return err
+	}
+	return tx.Create(newVersion(backup, blob, userID, 0, "")).Error
+}
+
+// Copy 将备份复制为同一用户下名为 name 的新备份，src.Data 需已加载
+// withHistory 为 true 时一并复制全部历史版本并保留版本号，否则新备份从版本 1 开始
+func Copy(tx *gorm.DB, src *models.Backup, name string, userID uint, withHistory bool) (*models.Backup, error) {
+	backup := &models.Backup{
+		Name:               name,
+		Data:               src.Data,
+		PasswordsEncrypted: src.PasswordsEncrypted,
+		UserID:             src.UserID,
+	}
+	if !withHistory {
+		return backup, Create(tx, backup, userID, 0, "")
+	}
+
+	backup.Version = src.Version
+	if err := insertBackup(tx, backup); err != nil {
+		return nil, err
+	}
+	if err := attachBlob(tx, backup, src.Data, 1); err != nil {
+		return nil, err
+	}
+
+	var versions []models.BackupVersion
+	if err := tx.Where("backup_id = ?", src.ID).Order("version").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if v.BlobHash == "" || backup.DataKey != nil {
+			// 早期内联存储的版本，以及加密时以新备份的数据密钥重新加密的版本，复制时写入新的数据块
+			if err := LoadVersion(&v); err != nil {
+				return nil, err
+			}
+			blob, err := acquireBlob(tx, backup, v.Data, 1)
+			if err != nil {
+				return nil, err
+			}
+			v.BlobHash, v.Encoding = blob.Hash, blob.Encoding
+			v.Size, v.StoredSize = blob.Size, blob.StoredSize
+		} else if err := retainBlob(tx, v.BlobHash); err != nil {
+			return nil, err
+		}
+		v.ID = 0
+		v.BackupID = backup.ID
+		v.Data, v.Payload = "", nil
+		if err := tx.Create(&v).Error; err != nil {
+			return nil, err
+		}
+	}
+	return backup, nil
+}
+
+// Trash 将备份移至回收站，数据和历史版本保留到彻底删除
+func Trash(tx *gorm.DB, backup *models.Backup) error {
+	return tx.Delete(backup).Error
+}
+
+// Restore 从回收站恢复备份
+func Restore(tx *gorm.DB, backup *models.Backup) error {
+	if err := tx.Unscoped().Model(&models.Backup{}).Where("id = ?", backup.ID).UpdateColumn("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	backup.DeletedAt = gorm.DeletedAt{}
+	return nil
+}
+
+// Delete 彻底删除备份（包括回收站中的备份）及其全部历史版本，并释放它们引用的数据块
+func Delete(tx *gorm.DB, backup *models.Backup) error {
+	var hashes []string
+	if err := tx.Model(&models.BackupVersion{}).Where("backup_id = ?", backup.ID).Pluck("blob_hash", &hashes).Error; err != nil {
+		return err
+	}
+	for _, hash := range append(hashes, backup.BlobHash) {
+		if err := releaseBlob(tx, hash); err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("backup_id = ?", backup.ID).Delete(&models.BackupVersion{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("backup_id = ?", backup.ID).Delete(&models.RetentionPolicy{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("backup_id = ?", backup.ID).Delete(&models.BackupGrant{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("backup_id = ?", backup.ID).Delete(&models.ShareLink{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(backup).Error
+}
+
+// DeleteVersions 删除备份的指定历史版本并释放引用的数据块，备份的当前版本不会被删除
+func DeleteVersions(tx *gorm.DB, backupID uint, versions []int) (int, error) {
+	if len(versions) == 0 {
+		return 0, nil
+	}
+
+	var targets []models.BackupVersion
+	err := tx.Select("id, blob_hash").
+		Where("backup_id = ? AND version IN ? AND version <> (SELECT version FROM backups WHERE id = ?)", backupID, versions, backupID).
+		Find(&targets).Error
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range targets {
+		if err := releaseBlob(tx, v.BlobHash); err != nil {
+			return 0, err
+		}
+		if err := tx.Delete(&models.BackupVersion{}, v.ID).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(targets), nil
+}
@@ -0,0 +1,444 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// setupTestDB 为每个测试初始化独立的临时数据库，并恢复默认的存储设置
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	if err := database.InitDB(filepath.Join(t.TempDir(), "itab.db")); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+		activeBackend = BackendDB
+		Compression = EncodingGzip
+	})
+}
+
+func createBackup(t *testing.T, name, data string) *models.Backup {
+	t.Helper()
+	backup := &models.Backup{Name: name, Data: data, UserID: 1}
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return Create(tx, backup, 1, 0, "")
+	}); err != nil {
+		t.Fatalf("创建备份失败: %v", err)
+	}
+	return backup
+}
+
+func saveRevision(t *testing.T, backup *models.Backup, data string) {
+	t.Helper()
+	backup.Data = data
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return SaveRevision(tx, backup, 1, 0, "")
+	}); err != nil {
+		t.Fatalf("保存版本失败: %v", err)
+	}
+}
+
+func deleteVersions(t *testing.T, backupID uint, versions ...int) int {
+	t.Helper()
+	var deleted int
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		deleted, err = DeleteVersions(tx, backupID, versions)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("删除版本失败: %v", err)
+	}
+	return deleted
+}
+
+// refCount 返回数据块的引用计数，数据块不存在时返回 -1
+func refCount(t *testing.T, data string) int {
+	t.Helper()
+	var blobs []models.Blob
+	if err := database.DB.Where("hash = ?", HashData(data)).Find(&blobs).Error; err != nil {
+		t.Fatalf("查询数据块失败: %v", err)
+	}
+	if len(blobs) == 0 {
+		return -1
+	}
+	return blobs[0].RefCount
+}
+
+// checkRefCounts 校验每个数据块的引用计数与备份和历史版本的实际引用一致，且被引用的数据块都存在
+func checkRefCounts(t *testing.T) {
+	t.Helper()
+	refs := map[string]int{}
+	var hashes []string
+	database.DB.Unscoped().Model(&models.Backup{}).Where("blob_hash <> ''").Pluck("blob_hash", &hashes)
+	for _, h := range hashes {
+		refs[h]++
+	}
+	hashes = nil
+	database.DB.Model(&models.BackupVersion{}).Where("blob_hash <> ''").Pluck("blob_hash", &hashes)
+	for _, h := range hashes {
+		refs[h]++
+	}
+
+	var blobs []models.Blob
+	database.DB.Find(&blobs)
+	stored := map[string]int{}
+	for _, b := range blobs {
+		stored[b.Hash] = b.RefCount
+		if b.RefCount != refs[b.Hash] {
+			t.Errorf("数据块 %s 引用计数 = %d，实际引用 %d", b.Hash[:8], b.RefCount, refs[b.Hash])
+		}
+	}
+	for h, n := range refs {
+		if _, ok := stored[h]; !ok {
+			t.Errorf("数据块 %s 被引用 %d 次但不存在", h[:8], n)
+		}
+	}
+}
+
+func loadBackup(t *testing.T, id uint) string {
+	t.Helper()
+	var backup models.Backup
+	if err := database.DB.First(&backup, id).Error; err != nil {
+		t.Fatalf("读取备份失败: %v", err)
+	}
+	if err := Load(&backup); err != nil {
+		t.Fatalf("加载备份数据失败: %v", err)
+	}
+	return backup.Data
+}
+
+func TestCreateDeduplicatesIdenticalData(t *testing.T) {
+	setupTestDB(t)
+	data := `{"shortcuts":[{"id":1,"name":"GitHub"}]}`
+
+	a := createBackup(t, "a", data)
+	b := createBackup(t, "b", data)
+
+	if a.BlobHash != b.BlobHash {
+		t.Fatalf("相同内容的备份应引用同一数据块: %s / %s", a.BlobHash, b.BlobHash)
+	}
+	var count int64
+	database.DB.Model(&models.Blob{}).Count(&count)
+	if count != 1 {
+		t.Errorf("数据块数量 = %d，应为 1", count)
+	}
+	// 两个备份及各自的版本 1 各持有一个引用
+	if n := refCount(t, data); n != 4 {
+		t.Errorf("引用计数 = %d，应为 4", n)
+	}
+	if got := loadBackup(t, b.ID); got != data {
+		t.Errorf("读取的数据 = %s", got)
+	}
+	checkRefCounts(t)
+}
+
+func TestSaveRevisionMovesReference(t *testing.T) {
+	setupTestDB(t)
+	v1, v2 := `{"v":1}`, `{"v":2}`
+	backup := createBackup(t, "home", v1)
+
+	saveRevision(t, backup, v2)
+
+	// 版本 1 仍引用旧数据，备份本身和版本 2 引用新数据
+	if n := refCount(t, v1); n != 1 {
+		t.Errorf("旧数据块引用计数 = %d，应为 1", n)
+	}
+	if n := refCount(t, v2); n != 2 {
+		t.Errorf("新数据块引用计数 = %d，应为 2", n)
+	}
+
+	// 改回旧内容时复用已有数据块
+	saveRevision(t, backup, v1)
+	if n := refCount(t, v1); n != 3 {
+		t.Errorf("复用后引用计数 = %d，应为 3", n)
+	}
+	if n := refCount(t, v2); n != 1 {
+		t.Errorf("新数据块引用计数 = %d，应为 1", n)
+	}
+	checkRefCounts(t)
+}
+
+func TestDeleteVersionsReleasesBlobs(t *testing.T) {
+	setupTestDB(t)
+	v1, v2 := `{"v":1}`, `{"v":2}`
+	backup := createBackup(t, "home", v1)
+	other := createBackup(t, "other", v2)
+	saveRevision(t, backup, v2)
+
+	// 当前版本不会被删除
+	if n := deleteVersions(t, backup.ID, 2); n != 0 {
+		t.Errorf("删除了 %d 个版本，当前版本不应被删除", n)
+	}
+
+	// 只被版本 1 引用的数据块随之删除
+	if n := deleteVersions(t, backup.ID, 1); n != 1 {
+		t.Fatalf("删除了 %d 个版本，应为 1", n)
+	}
+	if n := refCount(t, v1); n != -1 {
+		t.Errorf("无引用的数据块应被删除，引用计数 = %d", n)
+	}
+
+	// 与其他备份共享的数据块只减少引用
+	saveRevision(t, backup, `{"v":3}`)
+	if n := deleteVersions(t, backup.ID, 2); n != 1 {
+		t.Fatalf("删除了 %d 个版本，应为 1", n)
+	}
+	if n := refCount(t, v2); n != 2 {
+		t.Errorf("共享数据块引用计数 = %d，应为 2", n)
+	}
+	if got := loadBackup(t, other.ID); got != v2 {
+		t.Errorf("其他备份的数据 = %s", got)
+	}
+	checkRefCounts(t)
+}
+
+func TestDeleteReleasesAllReferences(t *testing.T) {
+	setupTestDB(t)
+	backup := createBackup(t, "home", `{"v":1}`)
+	saveRevision(t, backup, `{"v":2}`)
+	keep := createBackup(t, "keep", `{"v":2}`)
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return Delete(tx, backup)
+	}); err != nil {
+		t.Fatalf("删除备份失败: %v", err)
+	}
+
+	if n := refCount(t, `{"v":1}`); n != -1 {
+		t.Errorf("无引用的数据块应被删除，引用计数 = %d", n)
+	}
+	if n := refCount(t, `{"v":2}`); n != 2 {
+		t.Errorf("共享数据块引用计数 = %d，应为 2", n)
+	}
+	if got := loadBackup(t, keep.ID); got != `{"v":2}` {
+		t.Errorf("其他备份的数据 = %s", got)
+	}
+	checkRefCounts(t)
+}
+
+func TestExternalBlobWaitsForGarbageCollection(t *testing.T) {
+	setupTestDB(t)
+	fs, err := storage.NewFSBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建存储后端失败: %v", err)
+	}
+	RegisterBackend(BackendFS, fs)
+	if err := UseBackend(BackendFS); err != nil {
+		t.Fatalf("切换存储后端失败: %v", err)
+	}
+
+	v1 := `{"v":1}`
+	backup := createBackup(t, "home", v1)
+	saveRevision(t, backup, `{"v":2}`)
+	deleteVersions(t, backup.ID, 1)
+
+	// 外部存储的数据块引用归零后保留记录，等待回收
+	if n := refCount(t, v1); n != 0 {
+		t.Fatalf("引用计数 = %d，应保留为 0", n)
+	}
+
+	// 回收前再次保存相同内容时复用并重新写入
+	other := createBackup(t, "other", v1)
+	if n := refCount(t, v1); n != 2 {
+		t.Errorf("复用后引用计数 = %d，应为 2", n)
+	}
+	if collected, err := CollectGarbage(); err != nil || collected != 0 {
+		t.Errorf("CollectGarbage = %d, %v，仍被引用的数据块不应回收", collected, err)
+	}
+	if got := loadBackup(t, other.ID); got != v1 {
+		t.Errorf("读取的数据 = %s", got)
+	}
+
+	// 删除引用后由垃圾回收删除记录和对象
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return Delete(tx, other)
+	}); err != nil {
+		t.Fatalf("删除备份失败: %v", err)
+	}
+	if collected, err := CollectGarbage(); err != nil || collected != 1 {
+		t.Errorf("CollectGarbage = %d, %v，应回收 1 个数据块", collected, err)
+	}
+	if _, err := fs.Get(HashData(v1)); err == nil {
+		t.Errorf("回收后对象应被删除")
+	}
+	checkRefCounts(t)
+}
+
+// 事务回滚后已写入外部存储的对象没有记录，超过保留时长后由垃圾回收删除
+func TestCollectGarbageSweepsRolledBackObjects(t *testing.T) {
+	setupTestDB(t)
+	fs, err := storage.NewFSBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建存储后端失败: %v", err)
+	}
+	RegisterBackend(BackendFS, fs)
+	if err := UseBackend(BackendFS); err != nil {
+		t.Fatalf("切换存储后端失败: %v", err)
+	}
+	kept := createBackup(t, "kept", `{"v":1}`)
+
+	rollback := fmt.Errorf("回滚")
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := Create(tx, &models.Backup{Name: "rolled", Data: `{"v":2}`, UserID: 1}, 1, 0, ""); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if err != rollback {
+		t.Fatalf("事务结果 = %v，应回滚", err)
+	}
+	orphan := HashData(`{"v":2}`)
+	if _, err := fs.Get(orphan); err != nil {
+		t.Fatalf("回滚前写入的对象应仍存在: %v", err)
+	}
+
+	// 新写入的对象可能属于进行中的事务，保留时长内不删除
+	if collected, err := CollectGarbage(); err != nil || collected != 0 {
+		t.Errorf("CollectGarbage = %d, %v，不应删除新写入的对象", collected, err)
+	}
+	if _, err := fs.Get(orphan); err != nil {
+		t.Errorf("保留时长内的对象被删除: %v", err)
+	}
+
+	if swept, err := sweepObjects(time.Now().Add(time.Minute)); err != nil || swept != 1 {
+		t.Fatalf("sweepObjects = %d, %v，应删除 1 个对象", swept, err)
+	}
+	if _, err := fs.Get(orphan); err != storage.ErrNotFound {
+		t.Errorf("无记录的对象应被删除: %v", err)
+	}
+	if got := loadBackup(t, kept.ID); got != `{"v":1}` {
+		t.Errorf("有记录的对象被删除，读取的数据 = %s", got)
+	}
+}
+
+// 保存读取到数据块之后、增加引用之前，该数据块因引用归零被并发删除
+func TestAcquireBlobDeletedAfterRead(t *testing.T) {
+	setupTestDB(t)
+	data := `{"v":1}`
+	backup := createBackup(t, "home", data)
+	saveRevision(t, backup, `{"v":2}`)
+
+	var once sync.Once
+	name := "test:delete_blob"
+	err := database.DB.Callback().Update().Before("gorm:update").Register(name, func(db *gorm.DB) {
+		if db.Statement.Table != "blobs" {
+			return
+		}
+		once.Do(func() {
+			// 模拟清理版本 1 的事务在此时提交
+			db.Session(&gorm.Session{NewDB: true}).Exec("DELETE FROM backup_versions WHERE backup_id = ? AND version = 1", backup.ID)
+			db.Session(&gorm.Session{NewDB: true}).Exec("DELETE FROM blobs WHERE hash = ?", HashData(data))
+		})
+	})
+	if err != nil {
+		t.Fatalf("注册回调失败: %v", err)
+	}
+	t.Cleanup(func() { database.DB.Callback().Update().Remove(name) })
+
+	other := createBackup(t, "other", data)
+
+	if n := refCount(t, data); n != 2 {
+		t.Errorf("引用计数 = %d，数据块应重新写入且有 2 个引用", n)
+	}
+	if got := loadBackup(t, other.ID); got != data {
+		t.Errorf("读取的数据 = %s", got)
+	}
+	checkRefCounts(t)
+}
+
+// 并发清理版本和保存相同内容时，引用计数始终与实际引用一致
+func TestConcurrentPruneAndSave(t *testing.T) {
+	setupTestDB(t)
+	const rounds = 20
+
+	// 先准备好全部备份，避免准备过程与上一轮的并发事务争用数据库锁
+	pruned := make([]*models.Backup, rounds)
+	for i := range pruned {
+		pruned[i] = createBackup(t, fmt.Sprintf("prune-%d", i), fmt.Sprintf(`{"round":%d}`, i))
+		saveRevision(t, pruned[i], `{"current":true}`)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*rounds)
+	for i, backup := range pruned {
+		data := fmt.Sprintf(`{"round":%d}`, i)
+		backup := backup
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- retryLocked(func() error {
+				return database.DB.Transaction(func(tx *gorm.DB) error {
+					_, err := DeleteVersions(tx, backup.ID, []int{1})
+					return err
+				})
+			})
+		}()
+		go func(i int) {
+			defer wg.Done()
+			errs <- retryLocked(func() error {
+				return database.DB.Transaction(func(tx *gorm.DB) error {
+					return Create(tx, &models.Backup{Name: fmt.Sprintf("save-%d", i), Data: data, UserID: 1}, 1, 0, "")
+				})
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("并发操作失败: %v", err)
+		}
+	}
+
+	checkRefCounts(t)
+	var backups []models.Backup
+	database.DB.Where("name LIKE ?", "save-%").Find(&backups)
+	if len(backups) != rounds {
+		t.Fatalf("保存了 %d 个备份，应为 %d", len(backups), rounds)
+	}
+	for _, b := range backups {
+		if err := Load(&b); err != nil {
+			t.Errorf("备份 %s 的数据块丢失: %v", b.Name, err)
+		}
+	}
+}
+
+// retryLocked 数据库被其他事务锁定时重试
+func retryLocked(fn func() error) error {
+	var err error
+	for i := 0; i < 200; i++ {
+		if err = fn(); err == nil || !isLocked(err) {
+			return err
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return err
+}
+
+func isLocked(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "SQLITE_BUSY")
+}
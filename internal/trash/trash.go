@@ -0,0 +1,105 @@
+// Package trash 管理回收站中的备份，按设置定期彻底删除过期的备份
+package trash
+
+import (
+	"log"
+	"time"
+
+	"itab-backend/internal/database"
+	"itab-backend/internal/models"
+	"itab-backend/internal/settings"
+	"itab-backend/internal/store"
+
+	"gorm.io/gorm"
+)
+
+// settingKey 回收站设置在 settings 表中的键
+const settingKey = "trash"
+
+// defaultPurgeDays 未设置时回收站保留的天数
+const defaultPurgeDays = 30
+
+// Config 回收站设置
+type Config struct {
+	PurgeDays int `json:"purge_days"` // 备份在回收站中保留的天数，超过后自动彻底删除，0 表示不自动删除
+}
+
+// GetConfig 获取回收站设置
+func GetConfig() (Config, error) {
+	config := Config{PurgeDays: defaultPurgeDays}
+	_, err := settings.Get(settingKey, &config)
+	return config, err
+}
+
+// SetConfig 保存回收站设置
+func SetConfig(config Config) error {
+	return settings.Set(settingKey, config)
+}
+
+// PurgeAt 计算回收站中的备份将被自动删除的时间，不会自动删除时返回 nil
+func (c Config) PurgeAt(deletedAt time.Time) *time.Time {
+	if c.PurgeDays <= 0 {
+		return nil
+	}
+	t := deletedAt.AddDate(0, 0, c.PurgeDays)
+	return &t
+}
+
+// Purge 彻底删除在回收站中超过保留天数的备份，返回删除的数量
+func Purge() (int, error) {
+	config, err := GetConfig()
+	if err != nil || config.PurgeDays <= 0 {
+		return 0, err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -config.PurgeDays)
+	var backups []models.Backup
+	if err := database.DB.Unscoped().Select("id").
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Find(&backups).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, b := range backups {
+		deleted := false
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			// 在事务中重新读取，跳过查询之后已被恢复的备份
+			var backup models.Backup
+			result := tx.Unscoped().Select("id, blob_hash").
+				Where("id = ? AND deleted_at IS NOT NULL AND deleted_at < ?", b.ID, cutoff).Limit(1).Find(&backup)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			deleted = true
+			return store.Delete(tx, &backup)
+		})
+		if err != nil {
+			log.Printf("[回收站] 删除备份 %d 失败: %v", b.ID, err)
+			continue
+		}
+		if deleted {
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// StartAutoPurge 启动回收站自动清理定时任务，每天凌晨执行一次
+func StartAutoPurge() {
+	go func() {
+		for {
+			now := time.Now()
+			next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 20, 0, 0, now.Location())
+			time.Sleep(next.Sub(now))
+
+			purged, err := Purge()
+			if err != nil {
+				log.Printf("[回收站] 自动清理失败: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("[回收站] 已彻底删除 %d 个过期备份", purged)
+			}
+		}
+	}()
+}
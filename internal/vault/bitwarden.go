@@ -0,0 +1,175 @@
+package vault
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"itab-backend/internal/models"
+)
+
+// bitwardenTypeLogin Bitwarden 的登录条目类型，其余类型（安全笔记、银行卡、身份）不导入
+const bitwardenTypeLogin = 1
+
+// bitwardenCSVHeader Bitwarden 个人密码库 CSV 导出的表头
+var bitwardenCSVHeader = []string{"folder", "favorite", "type", "name", "notes", "fields", "reprompt", "login_uri", "login_username", "login_password", "login_totp"}
+
+// bitwardenExport Bitwarden JSON 导出文件
+type bitwardenExport struct {
+	Encrypted         bool              `json:"encrypted"`
+	PasswordProtected bool              `json:"passwordProtected,omitempty"`
+	Folders           []json.RawMessage `json:"folders"`
+	Items             []bitwardenItem   `json:"items"`
+}
+
+type bitwardenItem struct {
+	ID             *string         `json:"id"`
+	OrganizationID *string         `json:"organizationId"`
+	FolderID       *string         `json:"folderId"`
+	Type           int             `json:"type"`
+	Reprompt       int             `json:"reprompt"`
+	Name           string          `json:"name"`
+	Notes          *string         `json:"notes"`
+	Favorite       bool            `json:"favorite"`
+	Login          *bitwardenLogin `json:"login,omitempty"`
+	CollectionIDs  []string        `json:"collectionIds"`
+	CreationDate   string          `json:"creationDate,omitempty"`
+	RevisionDate   string          `json:"revisionDate,omitempty"`
+}
+
+type bitwardenLogin struct {
+	URIs     []bitwardenURI `json:"uris"`
+	Username *string        `json:"username"`
+	Password *string        `json:"password"`
+	TOTP     *string        `json:"totp"`
+}
+
+type bitwardenURI struct {
+	Match *int   `json:"match"`
+	URI   string `json:"uri"`
+}
+
+// WriteBitwardenJSON 写出 Bitwarden 未加密的 JSON 导出文件，可在 Bitwarden 中以「Bitwarden (json)」格式导入
+func WriteBitwardenJSON(w io.Writer, entries []models.Password) error {
+	export := bitwardenExport{Folders: []json.RawMessage{}, Items: make([]bitwardenItem, 0, len(entries))}
+	for _, p := range entries {
+		login := &bitwardenLogin{URIs: []bitwardenURI{}, Username: optional(p.Username), Password: optional(p.Password)}
+		if p.URL != "" {
+			login.URIs = append(login.URIs, bitwardenURI{URI: p.URL})
+		}
+		updated := p.UpdatedAt
+		if updated <= 0 {
+			updated = p.CreatedAt
+		}
+		export.Items = append(export.Items, bitwardenItem{
+			Type:         bitwardenTypeLogin,
+			Name:         p.Name,
+			Notes:        optional(p.Notes),
+			Login:        login,
+			CreationDate: formatTime(p.CreatedAt),
+			RevisionDate: formatTime(updated),
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(export)
+}
+
+// ParseBitwardenJSON 解析 Bitwarden 的 JSON 导出文件，只导入登录条目；加密的导出文件无法解析
+func ParseBitwardenJSON(data []byte) ([]models.Password, int, error) {
+	var export bitwardenExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, 0, ErrInvalidFile
+	}
+	if export.Encrypted || export.PasswordProtected {
+		return nil, 0, ErrEncryptedFile
+	}
+
+	var entries []models.Password
+	skipped := 0
+	for _, item := range export.Items {
+		if item.Type != bitwardenTypeLogin || item.Login == nil {
+			skipped++
+			continue
+		}
+		uri := ""
+		if len(item.Login.URIs) > 0 {
+			uri = item.Login.URIs[0].URI
+		}
+		p, ok := newEntry(item.Name, uri, deref(item.Login.Username), deref(item.Login.Password), deref(item.Notes),
+			parseTime(item.CreationDate), parseTime(item.RevisionDate))
+		if !ok {
+			skipped++
+			continue
+		}
+		entries = append(entries, p)
+	}
+	return entries, skipped, nil
+}
+
+// WriteBitwardenCSV 写出 Bitwarden 个人密码库的 CSV 导出文件，可在 Bitwarden 中以「Bitwarden (csv)」格式导入
+// 密码原样写出，不做电子表格公式转义，以免改变密码内容
+func WriteBitwardenCSV(w io.Writer, entries []models.Password) error {
+	cw := csv.NewWriter(w)
+	cw.Write(bitwardenCSVHeader)
+	for _, p := range entries {
+		cw.Write([]string{"", "", "login", p.Name, p.Notes, "", "0", p.URL, p.Username, p.Password, ""})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ParseBitwardenCSV 解析 Bitwarden 的 CSV 导出文件，只导入 type 为 login 的行，login_uri 有多个地址（每行一个）时取第一个
+func ParseBitwardenCSV(data []byte) ([]models.Password, int, error) {
+	columns, rows, err := readCSV(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, ok := columns["login_password"]; !ok {
+		return nil, 0, fmt.Errorf("%w: 缺少 login_password 列", ErrInvalidFile)
+	}
+
+	var entries []models.Password
+	skipped := 0
+	for _, row := range rows {
+		if t := column(columns, row, "type"); t != "" && t != "login" {
+			skipped++
+			continue
+		}
+		p, ok := newEntry(column(columns, row, "name"), firstURI(column(columns, row, "login_uri")), column(columns, row, "login_username"),
+			column(columns, row, "login_password"), column(columns, row, "notes"), 0, 0)
+		if !ok {
+			skipped++
+			continue
+		}
+		entries = append(entries, p)
+	}
+	return entries, skipped, nil
+}
+
+// firstURI 返回 Bitwarden CSV 中 login_uri 列的第一个地址，多个地址以换行分隔，地址本身可以包含逗号
+func firstURI(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// optional 空字符串输出为 null，与 Bitwarden 自身的导出一致
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
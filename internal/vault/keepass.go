@@ -0,0 +1,72 @@
+package vault
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"itab-backend/internal/models"
+)
+
+// keepassCSVHeader KeePassXC CSV 导出的表头，KeePass 2 的 CSV 导入向导也可按列名对应
+var keepassCSVHeader = []string{"Group", "Title", "Username", "Password", "URL", "Notes", "TOTP", "Icon", "Last Modified", "Created"}
+
+// keepassRootGroup 导出条目所在的分组，与 KeePassXC 的根分组同名
+const keepassRootGroup = "Root"
+
+// keepassRecycleBin KeePass 回收站分组的名称，其中的条目不导入
+const keepassRecycleBin = "recycle bin"
+
+// WriteKeePassCSV 写出 KeePassXC 格式的 CSV 文件，所有条目放在根分组中
+// 密码原样写出，不做电子表格公式转义，以免改变密码内容
+func WriteKeePassCSV(w io.Writer, entries []models.Password) error {
+	cw := csv.NewWriter(w)
+	cw.Write(keepassCSVHeader)
+	for _, p := range entries {
+		updated := p.UpdatedAt
+		if updated <= 0 {
+			updated = p.CreatedAt
+		}
+		cw.Write([]string{keepassRootGroup, p.Name, p.Username, p.Password, p.URL, p.Notes, "", "0",
+			formatTime(updated), formatTime(p.CreatedAt)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ParseKeePassCSV 解析 KeePassXC 或 KeePass 2 导出的 CSV 文件，按列名识别字段，回收站中的条目被跳过
+func ParseKeePassCSV(data []byte) ([]models.Password, int, error) {
+	columns, rows, err := readCSV(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, ok := columns["password"]; !ok {
+		return nil, 0, fmt.Errorf("%w: 缺少 Password 列", ErrInvalidFile)
+	}
+
+	var entries []models.Password
+	skipped := 0
+	for _, row := range rows {
+		group := strings.ToLower(column(columns, row, "group"))
+		if group == keepassRecycleBin || strings.HasSuffix(group, "/"+keepassRecycleBin) {
+			skipped++
+			continue
+		}
+		p, ok := newEntry(
+			column(columns, row, "title", "account"),
+			column(columns, row, "url", "web site"),
+			column(columns, row, "username", "login name", "user name"),
+			column(columns, row, "password"),
+			column(columns, row, "notes", "comments"),
+			parseTime(column(columns, row, "created", "creation time")),
+			parseTime(column(columns, row, "last modified", "last modification time")),
+		)
+		if !ok {
+			skipped++
+			continue
+		}
+		entries = append(entries, p)
+	}
+	return entries, skipped, nil
+}
@@ -0,0 +1,216 @@
+// Package vault 提供备份中的密码条目（models.Password）与密码管理器导入导出文件（Bitwarden、KeePass）之间的转换
+// 服务端不持有客户端的加密密钥，已加密的密码只能原样保存，不能导出为明文，也不能把明文导入到已加密的密码库中
+package vault
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"itab-backend/internal/models"
+)
+
+// 支持的密码文件格式
+const (
+	FormatBitwardenJSON = "bitwarden-json" // Bitwarden 未加密的 JSON 导出
+	FormatBitwardenCSV  = "bitwarden-csv"  // Bitwarden CSV 导出
+	FormatKeePassCSV    = "keepass-csv"    // KeePass/KeePassXC CSV 导出
+)
+
+// 转换错误
+var (
+	ErrUnsupportedFormat = errors.New("不支持的密码文件格式")
+	ErrInvalidFile       = errors.New("无法识别的密码文件")
+	ErrEncryptedFile     = errors.New("密码文件已加密，请导出为未加密的文件后再导入")
+	ErrEncrypted         = errors.New("密码已加密")
+)
+
+// Stats 导入统计
+type Stats struct {
+	Imported   int `json:"imported"`   // 导入的密码条目数
+	Duplicates int `json:"duplicates"` // 已有相同名称、地址、用户名和密码而跳过的条目数
+	Skipped    int `json:"skipped"`    // 不是登录信息（如安全笔记、银行卡）或内容为空而跳过的条目数
+}
+
+// Format 密码文件格式
+type Format struct {
+	Ext         string // 文件扩展名
+	ContentType string
+	write       func(w io.Writer, entries []models.Password) error
+	parse       func(data []byte) ([]models.Password, int, error)
+}
+
+// formats 支持的格式
+var formats = map[string]*Format{
+	FormatBitwardenJSON: {Ext: ".json", ContentType: "application/json; charset=utf-8", write: WriteBitwardenJSON, parse: ParseBitwardenJSON},
+	FormatBitwardenCSV:  {Ext: ".csv", ContentType: "text/csv; charset=utf-8", write: WriteBitwardenCSV, parse: ParseBitwardenCSV},
+	FormatKeePassCSV:    {Ext: ".csv", ContentType: "text/csv; charset=utf-8", write: WriteKeePassCSV, parse: ParseKeePassCSV},
+}
+
+// Lookup 查找密码文件格式，不支持时返回 nil 和 false
+func Lookup(format string) (*Format, bool) {
+	f, ok := formats[format]
+	return f, ok
+}
+
+// Detect 根据文件内容判断密码文件格式：JSON 为 Bitwarden，CSV 表头含 login_password 为 Bitwarden，其余按 KeePass 处理
+func Detect(data []byte) string {
+	trimmed := bytes.TrimLeft(data, " \t\r\n\xef\xbb\xbf")
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return FormatBitwardenJSON
+	}
+	header, _, _ := bytes.Cut(trimmed, []byte("\n"))
+	if bytes.Contains(bytes.ToLower(header), []byte("login_password")) {
+		return FormatBitwardenCSV
+	}
+	return FormatKeePassCSV
+}
+
+// Parse 按格式解析密码文件，返回其中的登录条目和跳过的条目数，format 为空时根据文件内容自动判断
+// 返回的条目均为明文，ID 未分配
+func Parse(format string, data []byte) ([]models.Password, int, error) {
+	if format == "" {
+		format = Detect(data)
+	}
+	f, ok := formats[format]
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	return f.parse(data)
+}
+
+// Write 将密码条目按此格式写出，条目须为明文，调用方应先用 Plaintext 筛选
+func (f *Format) Write(w io.Writer, entries []models.Password) error {
+	return f.write(w, entries)
+}
+
+// IsEncrypted 判断密码条目是否为密文：备份整体标记为加密，或条目自身带有 _encrypted 标记
+func IsEncrypted(backupEncrypted bool, p models.Password) bool {
+	return backupEncrypted || p.Encrypted
+}
+
+// Plaintext 返回可以导出为明文的条目和被排除的已加密条目数
+func Plaintext(backupEncrypted bool, entries []models.Password) ([]models.Password, int) {
+	plain := make([]models.Password, 0, len(entries))
+	encrypted := 0
+	for _, p := range entries {
+		if IsEncrypted(backupEncrypted, p) {
+			encrypted++
+			continue
+		}
+		plain = append(plain, p)
+	}
+	return plain, encrypted
+}
+
+// Import 将明文密码条目追加到备份数据中，分配新的ID；与已有明文条目的名称、地址、用户名和密码都相同时视为重复并跳过
+// backupEncrypted 为 true 时客户端认为所有密码都是密文，此时返回 ErrEncrypted，不修改备份数据
+func Import(bd *models.BackupData, backupEncrypted bool, entries []models.Password) (Stats, error) {
+	var stats Stats
+	if backupEncrypted {
+		return stats, fmt.Errorf("%w，服务端无法将明文密码导入到加密的密码库，请在客户端中导入", ErrEncrypted)
+	}
+
+	var nextID int64
+	existing := make(map[string]bool, len(bd.Passwords))
+	for _, p := range bd.Passwords {
+		nextID = max(nextID, p.ID)
+		if !p.Encrypted {
+			existing[entryKey(p)] = true
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	for _, p := range entries {
+		key := entryKey(p)
+		if existing[key] {
+			stats.Duplicates++
+			continue
+		}
+		existing[key] = true
+
+		nextID++
+		p.ID = nextID
+		p.Encrypted = false
+		if p.CreatedAt <= 0 {
+			p.CreatedAt = now
+		}
+		bd.Passwords = append(bd.Passwords, p)
+		stats.Imported++
+	}
+	return stats, nil
+}
+
+func entryKey(p models.Password) string {
+	return strings.Join([]string{p.Name, p.URL, p.Username, p.Password}, "\x00")
+}
+
+// newEntry 由导入文件中的字段生成密码条目，没有地址、用户名和密码时返回 false
+func newEntry(name, uri, username, password, notes string, created, updated int64) (models.Password, bool) {
+	uri = strings.TrimSpace(uri)
+	if uri == "" && username == "" && password == "" {
+		return models.Password{}, false
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = uri
+	}
+	if name == "" {
+		name = username
+	}
+	return models.Password{
+		Name:      name,
+		URL:       uri,
+		Username:  username,
+		Password:  password,
+		Notes:     notes,
+		CreatedAt: created,
+		UpdatedAt: updated,
+	}, true
+}
+
+// readCSV 读取带表头的 CSV 文件，返回小写的列名到列序号的映射和数据行
+func readCSV(data []byte) (map[string]int, [][]string, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil || len(rows) == 0 {
+		return nil, nil, ErrInvalidFile
+	}
+	columns := make(map[string]int, len(rows[0]))
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	return columns, rows[1:], nil
+}
+
+// column 返回行中第一个存在的列的值
+func column(columns map[string]int, row []string, names ...string) string {
+	for _, name := range names {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+	}
+	return ""
+}
+
+// parseTime 解析 RFC 3339 时间为毫秒时间戳，无效时返回 0
+func parseTime(s string) int64 {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+	if err != nil {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// formatTime 将毫秒时间戳格式化为 RFC 3339 时间，未知时返回空字符串
+func formatTime(ms int64) string {
+	if ms <= 0 {
+		return ""
+	}
+	return time.UnixMilli(ms).UTC().Format("2006-01-02T15:04:05.000Z")
+}
@@ -0,0 +1,184 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"itab-backend/internal/models"
+)
+
+// testEntries 含逗号、引号、换行、公式前缀和非 ASCII 字符的明文条目
+func testEntries() []models.Password {
+	return []models.Password{
+		{
+			Name:      "GitHub",
+			URL:       "https://github.com/login?a=1,2",
+			Username:  "alice",
+			Password:  `=1+1,"quoted"`,
+			Notes:     "第一行\n第二行",
+			CreatedAt: 1700000000123,
+			UpdatedAt: 1700000100456,
+		},
+		{
+			Name:      "邮箱",
+			URL:       "https://mail.example.com",
+			Username:  "bob@example.com",
+			Password:  "pässwörd",
+			CreatedAt: 1700000000000,
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		format    string
+		keepTimes bool // 格式是否保留创建和修改时间
+	}{
+		{FormatBitwardenJSON, true},
+		{FormatBitwardenCSV, false},
+		{FormatKeePassCSV, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			f, ok := Lookup(tt.format)
+			if !ok {
+				t.Fatalf("不支持的格式 %s", tt.format)
+			}
+			var buf bytes.Buffer
+			if err := f.Write(&buf, testEntries()); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if got := Detect(buf.Bytes()); got != tt.format {
+				t.Errorf("Detect = %s，应为 %s", got, tt.format)
+			}
+
+			entries, skipped, err := Parse("", buf.Bytes())
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if skipped != 0 {
+				t.Errorf("skipped = %d", skipped)
+			}
+			want := testEntries()
+			for i := range want {
+				if !tt.keepTimes {
+					want[i].CreatedAt, want[i].UpdatedAt = 0, 0
+				} else if want[i].UpdatedAt == 0 {
+					// 没有修改时间时以创建时间导出
+					want[i].UpdatedAt = want[i].CreatedAt
+				}
+			}
+			if !reflect.DeepEqual(entries, want) {
+				t.Errorf("往返结果 =\n%+v\n应为\n%+v", entries, want)
+			}
+		})
+	}
+}
+
+func TestParseBitwardenJSONSkipsNonLogin(t *testing.T) {
+	data := []byte(`{
+		"encrypted": false,
+		"folders": [],
+		"items": [
+			{"type": 1, "name": "Login", "login": {"uris": [{"match": null, "uri": "https://a.example.com"}, {"uri": "https://b.example.com"}], "username": "u", "password": "p"}},
+			{"type": 2, "name": "安全笔记", "notes": "secret", "secureNote": {"type": 0}},
+			{"type": 3, "name": "银行卡", "card": {"number": "4111"}},
+			{"type": 4, "name": "身份", "identity": {}},
+			{"type": 1, "name": "空登录", "login": {"uris": [], "username": null, "password": null}}
+		]
+	}`)
+	entries, skipped, err := ParseBitwardenJSON(data)
+	if err != nil {
+		t.Fatalf("ParseBitwardenJSON: %v", err)
+	}
+	if skipped != 4 {
+		t.Errorf("skipped = %d，应为 4", skipped)
+	}
+	if len(entries) != 1 || entries[0].URL != "https://a.example.com" || entries[0].Username != "u" {
+		t.Errorf("entries = %+v", entries)
+	}
+}
+
+func TestParseBitwardenJSONEncrypted(t *testing.T) {
+	tests := []string{
+		`{"encrypted": true, "encKeyValidation_DO_NOT_EDIT": "2.abc", "folders": [], "items": []}`,
+		`{"encrypted": true, "passwordProtected": true, "salt": "x", "kdfType": 0, "data": "2.abc"}`,
+	}
+	for _, data := range tests {
+		if _, _, err := ParseBitwardenJSON([]byte(data)); !errors.Is(err, ErrEncryptedFile) {
+			t.Errorf("err = %v，应为 ErrEncryptedFile", err)
+		}
+	}
+}
+
+func TestParseBitwardenCSV(t *testing.T) {
+	data := []byte("folder,favorite,type,name,notes,fields,reprompt,login_uri,login_username,login_password,login_totp\n" +
+		",,login,多地址,,,0,\"https://a.example.com/?q=1,2\nhttps://b.example.com\",alice,secret,\n" +
+		",,note,笔记,secret note,,0,,,,\n" +
+		",,login,,,,0,,,,\n")
+	entries, skipped, err := ParseBitwardenCSV(data)
+	if err != nil {
+		t.Fatalf("ParseBitwardenCSV: %v", err)
+	}
+	if skipped != 2 {
+		t.Errorf("skipped = %d，应为 2", skipped)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	if got := entries[0].URL; got != "https://a.example.com/?q=1,2" {
+		t.Errorf("URL = %q，应为第一行的完整地址", got)
+	}
+}
+
+func TestParseKeePassCSV(t *testing.T) {
+	// KeePass 2 的列名，回收站中的条目跳过
+	data := []byte("\ufeff\"Account\",\"Login Name\",\"Password\",\"Web Site\",\"Comments\",\"Group\"\n" +
+		"\"Example\",\"alice\",\"secret\",\"https://example.com\",\"note\",\"Root\"\n" +
+		"\"Deleted\",\"bob\",\"old\",\"https://old.example.com\",\"\",\"Root/Recycle Bin\"\n")
+	entries, skipped, err := ParseKeePassCSV(data)
+	if err != nil {
+		t.Fatalf("ParseKeePassCSV: %v", err)
+	}
+	if skipped != 1 {
+		t.Errorf("skipped = %d，应为 1", skipped)
+	}
+	want := []models.Password{{Name: "Example", URL: "https://example.com", Username: "alice", Password: "secret", Notes: "note"}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries = %+v", entries)
+	}
+}
+
+func TestImport(t *testing.T) {
+	bd := &models.BackupData{Passwords: []models.Password{
+		{ID: 5, Name: "GitHub", URL: "https://github.com/login?a=1,2", Username: "alice", Password: `=1+1,"quoted"`},
+		{ID: 7, Name: "密文", Password: "x", Encrypted: true},
+	}}
+	stats, err := Import(bd, false, testEntries())
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if stats.Imported != 1 || stats.Duplicates != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if last := bd.Passwords[len(bd.Passwords)-1]; last.ID != 8 || last.Name != "邮箱" {
+		t.Errorf("新条目 = %+v，ID 应接在已有条目之后", last)
+	}
+
+	if _, err := Import(bd, true, testEntries()); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("err = %v，应为 ErrEncrypted", err)
+	}
+}
+
+func TestPlaintext(t *testing.T) {
+	entries := []models.Password{{Name: "a"}, {Name: "b", Encrypted: true}}
+	plain, encrypted := Plaintext(false, entries)
+	if len(plain) != 1 || plain[0].Name != "a" || encrypted != 1 {
+		t.Errorf("Plaintext = %+v, %d", plain, encrypted)
+	}
+	if plain, encrypted := Plaintext(true, entries); len(plain) != 0 || encrypted != 2 {
+		t.Errorf("备份整体加密时 Plaintext = %+v, %d", plain, encrypted)
+	}
+}